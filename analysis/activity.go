@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"slices"
+	"strings"
+	"time"
+)
+
+type ActivityType string
+
+const (
+	ActivityHike ActivityType = "hike"
+	ActivityRun  ActivityType = "run"
+	ActivityBike ActivityType = "bike"
+	ActivitySki  ActivityType = "ski"
+)
+
+var ActivityTypes = []ActivityType{ActivityHike, ActivityRun, ActivityBike, ActivitySki}
+
+// Where the activity type of a track came from
+const (
+	ActivityTypeFromFile     = "file"
+	ActivityTypeFromInferred = "inferred"
+	ActivityTypeFromUser     = "user"
+)
+
+const (
+	// Running is roughly this fraction of the time estimated for walking
+	runEstimateFactor = 0.6
+
+	// Median moving speeds above which we guess a faster activity
+	minRunSpeed  = 2.3 // m/s, about 8 km/h
+	minBikeSpeed = 4.5 // m/s, about 16 km/h
+	minSkiSpeed  = 3.0 // m/s
+	// Fraction of moving distance that must be downhill to guess skiing
+	minSkiDescentFraction = 0.7
+	// Grade below which a segment counts as downhill for skiing
+	skiDescentGrade = -0.05
+)
+
+var sourceActivityTypes = map[string]ActivityType{
+	"hike":                 ActivityHike,
+	"hiking":               ActivityHike,
+	"walk":                 ActivityHike,
+	"walking":              ActivityHike,
+	"mountaineering":       ActivityHike,
+	"run":                  ActivityRun,
+	"running":              ActivityRun,
+	"trail_running":        ActivityRun,
+	"trailrunning":         ActivityRun,
+	"bike":                 ActivityBike,
+	"biking":               ActivityBike,
+	"ride":                 ActivityBike,
+	"cycling":              ActivityBike,
+	"road_biking":          ActivityBike,
+	"mountain_biking":      ActivityBike,
+	"gravel_cycling":       ActivityBike,
+	"ski":                  ActivitySki,
+	"skiing":               ActivitySki,
+	"alpine_skiing":        ActivitySki,
+	"backcountry_skiing":   ActivitySki,
+	"cross_country_skiing": ActivitySki,
+	"ski_touring":          ActivitySki,
+	"snowboarding":         ActivitySki,
+}
+
+// ParseActivityType understands both our own activity types and the names
+// used by source files, such as the GPX type element or FIT sport.
+func ParseActivityType(s string) (ActivityType, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer(" ", "_", "-", "_").Replace(s)
+	t, ok := sourceActivityTypes[s]
+	return t, ok
+}
+
+// DetectActivityType uses the activity type recorded in the source file if
+// present, and otherwise tries to infer it.
+func DetectActivityType(f geojson.Feature) (ActivityType, string, bool) {
+	for _, key := range []string{"type", "sport"} {
+		if v, ok := f.Properties[key].(string); ok {
+			if t, ok := ParseActivityType(v); ok {
+				return t, ActivityTypeFromFile, true
+			}
+		}
+	}
+	if t, ok := ClassifyActivity(f); ok {
+		return t, ActivityTypeFromInferred, true
+	}
+	return "", "", false
+}
+
+// ClassifyActivity guesses the activity type from the distribution of speed
+// and grade while moving. It requires times.
+func ClassifyActivity(f geojson.Feature) (ActivityType, bool) {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok {
+		return "", false
+	}
+	coordProps := f.Properties.CoordinateProperties()
+	times, ok := coordProps["times"].([]interface{})
+	if !ok || len(times) != len(line) {
+		return "", false
+	}
+	elevations, hasElevations := floatsFromCoordinateProperty(coordProps["elevationMeters"])
+	hasElevations = hasElevations && len(elevations) == len(line)
+
+	var speeds []float64
+	var movingDist, descentDist float64
+	var prev time.Time
+	prevI := -1
+	for i := range times {
+		t, ok := ParseSloppyRecentTime(times[i])
+		if !ok {
+			continue
+		}
+		if prevI >= 0 {
+			secs := t.Sub(prev).Seconds()
+			dist := geo.DistanceHaversine(line[prevI], line[i])
+			if secs > 0 && secs <= maxMovingGapSecs && dist/secs >= minMovingSpeed {
+				speeds = append(speeds, dist/secs)
+				movingDist += dist
+				if hasElevations && dist > 0 && (elevations[i]-elevations[prevI])/dist < skiDescentGrade {
+					descentDist += dist
+				}
+			}
+		}
+		prev = t
+		prevI = i
+	}
+	if len(speeds) == 0 || movingDist == 0 {
+		return "", false
+	}
+
+	slices.Sort(speeds)
+	median := speeds[len(speeds)/2]
+
+	switch {
+	case hasElevations && median >= minSkiSpeed && descentDist/movingDist >= minSkiDescentFraction:
+		return ActivitySki, true
+	case median >= minBikeSpeed:
+		return ActivityBike, true
+	case median >= minRunSpeed:
+		return ActivityRun, true
+	default:
+		return ActivityHike, true
+	}
+}
+
+func trackActivityType(f geojson.Feature) (ActivityType, bool) {
+	v, ok := f.Properties["activityType"].(string)
+	if !ok {
+		return "", false
+	}
+	return ParseActivityType(v)
+}
+
+// SetActivityType records the activity type of the track and recomputes the
+// stats that depend on it.
+func SetActivityType(f geojson.Feature, t ActivityType, source string) {
+	f.Properties["activityType"] = string(t)
+	f.Properties["activityTypeSource"] = source
+	RecomputeStats(f)
+}
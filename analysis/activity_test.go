@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// steadyFeature moves north at a constant speed with the given change in
+// elevation between points
+func steadyFeature(speed float64, rise float64) geojson.Feature {
+	const n = 20
+	const stepSecs = 10
+	var line orb.LineString
+	var times []interface{}
+	var elevations []float64
+	start := time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC)
+	// about 111km per degree of latitude
+	stepDegrees := speed * stepSecs / 111_195
+	for i := 0; i < n; i++ {
+		line = append(line, orb.Point{-4, 56 + float64(i)*stepDegrees})
+		times = append(times, start.Add(time.Duration(i*stepSecs)*time.Second).Format(time.RFC3339))
+		elevations = append(elevations, 1000+float64(i)*rise)
+	}
+	f := geojson.NewFeature(line)
+	f.Properties.CoordinateProperties()["times"] = times
+	f.Properties.CoordinateProperties()["elevationMeters"] = elevations
+	return *f
+}
+
+func TestParseActivityType(t *testing.T) {
+	cases := map[string]ActivityType{
+		"hiking":          ActivityHike,
+		"Trail Running":   ActivityRun,
+		"mountain-biking": ActivityBike,
+		"alpine_skiing":   ActivitySki,
+		"ski":             ActivitySki,
+	}
+	for input, expected := range cases {
+		got, ok := ParseActivityType(input)
+		assert.True(t, ok, input)
+		assert.Equal(t, expected, got, input)
+	}
+
+	_, ok := ParseActivityType("knitting")
+	assert.False(t, ok)
+}
+
+func TestDetectActivityTypePrefersFile(t *testing.T) {
+	f := steadyFeature(1.2, 0)
+	f.Properties["type"] = "cycling"
+	got, source, ok := DetectActivityType(f)
+	require.True(t, ok)
+	assert.Equal(t, ActivityBike, got)
+	assert.Equal(t, ActivityTypeFromFile, source)
+}
+
+func TestClassifyActivity(t *testing.T) {
+	cases := []struct {
+		name     string
+		speed    float64
+		rise     float64
+		expected ActivityType
+	}{
+		{"walking", 1.2, 2, ActivityHike},
+		{"running", 3, 0, ActivityRun},
+		{"cycling", 7, 0, ActivityBike},
+		{"skiing downhill", 8, -10, ActivitySki},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := ClassifyActivity(steadyFeature(c.speed, c.rise))
+			require.True(t, ok)
+			assert.Equal(t, c.expected, got)
+		})
+	}
+}
+
+func TestClassifyActivityWithoutTimes(t *testing.T) {
+	_, ok := ClassifyActivity(*geojson.NewFeature(orb.LineString{{0, 0}, {1, 1}}))
+	assert.False(t, ok)
+}
+
+func TestSetActivityTypeAdaptsStats(t *testing.T) {
+	f := steadyFeature(1.2, 0)
+
+	SetActivityType(f, ActivityHike, ActivityTypeFromUser)
+	hikeEstimate := f.Properties["naismithSecs"].(float64)
+	assert.Contains(t, f.Properties, "paceSecsPerKm")
+	assert.Equal(t, ActivityTypeFromUser, f.Properties["activityTypeSource"])
+
+	SetActivityType(f, ActivityRun, ActivityTypeFromUser)
+	assert.Less(t, f.Properties["naismithSecs"].(float64), hikeEstimate)
+
+	SetActivityType(f, ActivityBike, ActivityTypeFromUser)
+	assert.NotContains(t, f.Properties, "naismithSecs")
+	assert.NotContains(t, f.Properties, "paceSecsPerKm")
+	assert.Contains(t, f.Properties, "movingSpeedKmh")
+}
@@ -11,14 +11,17 @@ import (
 
 type Analyzer struct {
 	elevation ElevationQuerier
+	places    PlaceQuerier
 }
 
 type ElevationQuerier interface {
 	QueryElevations(ctx context.Context, points orb.LineString) ([]float64, error)
 }
 
-func NewAnalyzer(elevation ElevationQuerier) *Analyzer {
-	return &Analyzer{elevation: elevation}
+// NewAnalyzer creates an analyzer. If places is nil tracks aren't labelled
+// with place names.
+func NewAnalyzer(elevation ElevationQuerier, places PlaceQuerier) *Analyzer {
+	return &Analyzer{elevation: elevation, places: places}
 }
 
 func (a *Analyzer) HydrateTrack(ctx context.Context, f geojson.Feature) (geojson.Feature, error) {
@@ -27,8 +30,36 @@ func (a *Analyzer) HydrateTrack(ctx context.Context, f geojson.Feature) (geojson
 		return geojson.Feature{}, fmt.Errorf("expected LineString, got %s", f.Geometry.GeoJSONType())
 	}
 
+	elevations, err := a.elevation.QueryElevations(ctx, geom)
+	if err != nil {
+		return geojson.Feature{}, fmt.Errorf("query elevations: %w", err)
+	}
+	f.Properties.CoordinateProperties()["elevationMeters"] = elevations
+
+	if a.places != nil {
+		if err := LabelPlaces(ctx, a.places, f); err != nil {
+			return geojson.Feature{}, fmt.Errorf("label places: %w", err)
+		}
+	}
+
+	if activityType, source, ok := DetectActivityType(f); ok {
+		SetActivityType(f, activityType, source)
+	} else {
+		RecomputeStats(f)
+	}
+
+	return f, nil
+}
+
+// RecomputeStats updates the stats stored in the properties of a track that
+// has already been hydrated, for example after its geometry was changed.
+func RecomputeStats(f geojson.Feature) {
+	geom, ok := f.Geometry.(orb.LineString)
+	if !ok {
+		return
+	}
+
 	props := f.Properties
-	coordProps := props.CoordinateProperties()
 
 	length := roundPlaces(geo.LengthHaversine(geom), 6)
 	props["lengthMeters"] = length
@@ -36,15 +67,47 @@ func (a *Analyzer) HydrateTrack(ctx context.Context, f geojson.Feature) (geojson
 	durationSecs, ok := TrackDuration(f)
 	if ok {
 		props["durationSecs"] = durationSecs
+	} else {
+		delete(props, "durationSecs")
 	}
 
-	elevations, err := a.elevation.QueryElevations(ctx, geom)
-	if err != nil {
-		return geojson.Feature{}, fmt.Errorf("query elevations: %w", err)
+	activityType, _ := trackActivityType(f)
+	onFoot := activityType == "" || activityType == ActivityHike || activityType == ActivityRun
+
+	movingSecs, ok := TrackMovingTime(f)
+	if ok {
+		props["movingSecs"] = movingSecs
+	} else {
+		delete(props, "movingSecs")
 	}
-	coordProps["elevationMeters"] = elevations
 
-	return f, nil
+	delete(props, "movingSpeedKmh")
+	delete(props, "paceSecsPerKm")
+	if movingSecs > 0 && length > 0 {
+		props["movingSpeedKmh"] = roundPlaces(length/1000/(float64(movingSecs)/3600), 2)
+		if onFoot {
+			props["paceSecsPerKm"] = math.Round(float64(movingSecs) / (length / 1000))
+		}
+	}
+
+	// The estimates are for travelling on foot
+	elevations, hasElevations := floatsFromCoordinateProperty(props.CoordinateProperties()["elevationMeters"])
+	for _, method := range []EstimateMethod{Naismith, Tobler} {
+		key := string(method) + "Secs"
+		if !hasElevations || !onFoot {
+			delete(props, key)
+			continue
+		}
+		estimate, ok := EstimateMovingTime(method, geom, elevations)
+		if !ok {
+			delete(props, key)
+			continue
+		}
+		if activityType == ActivityRun {
+			estimate = math.Round(estimate * runEstimateFactor)
+		}
+		props[key] = estimate
+	}
 }
 
 func roundPlaces(f float64, places int) float64 {
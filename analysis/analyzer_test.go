@@ -19,7 +19,7 @@ func (m *MockElevationQuerier) QueryElevations(_ context.Context, points orb.Lin
 }
 
 func TestAnalyzer_HydrateTrack(t *testing.T) {
-	subject := NewAnalyzer(&MockElevationQuerier{})
+	subject := NewAnalyzer(&MockElevationQuerier{}, nil)
 
 	input := *geojson.NewFeature(orb.LineString{{0, 0}, {1, 1}})
 
@@ -29,4 +29,7 @@ func TestAnalyzer_HydrateTrack(t *testing.T) {
 	props := got.Properties
 	require.Equal(t, 157425.537108, props["lengthMeters"])
 	require.Equal(t, []float64{42.0, 42.0}, props.CoordinateProperties()["elevationMeters"])
+	require.Equal(t, 113346.0, props["naismithSecs"])
+	require.Contains(t, props, "toblerSecs")
+	require.NotContains(t, props, "movingSecs")
 }
@@ -0,0 +1,151 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"math"
+	"time"
+)
+
+const (
+	// DuplicateMaxStartDiff is how far apart the starts of two recordings of
+	// the same activity can be, allowing for one app starting earlier or
+	// trimming differently
+	DuplicateMaxStartDiff = 15 * time.Minute
+	// The bounding boxes must overlap by this fraction of their union
+	duplicateMinBoundOverlap = 0.75
+	// and the shapes be within this Fréchet distance
+	duplicateMaxFrechetMeters = 75
+	// Lines are resampled to this many points before comparing so that the
+	// comparison is independent of recording interval and affordable
+	duplicateResamplePoints = 128
+)
+
+// LikelyDuplicate reports whether two tracks are probably recordings of the
+// same activity, such as one exported from two different apps, along with
+// the Fréchet distance between them in meters.
+func LikelyDuplicate(a, b geojson.Feature) (float64, bool) {
+	lineA, okA := a.Geometry.(orb.LineString)
+	lineB, okB := b.Geometry.(orb.LineString)
+	if !okA || !okB || len(lineA) < 2 || len(lineB) < 2 {
+		return 0, false
+	}
+
+	startA, _, hasTimeA := TrackTimeRange(a)
+	startB, _, hasTimeB := TrackTimeRange(b)
+	if hasTimeA && hasTimeB && absDuration(startA.Sub(startB)) > DuplicateMaxStartDiff {
+		return 0, false
+	}
+
+	if boundOverlap(lineA.Bound(), lineB.Bound()) < duplicateMinBoundOverlap {
+		return 0, false
+	}
+
+	dist := FrechetDistance(
+		resampleLine(lineA, duplicateResamplePoints),
+		resampleLine(lineB, duplicateResamplePoints),
+	)
+	return math.Round(dist), dist <= duplicateMaxFrechetMeters
+}
+
+// FrechetDistance computes the discrete Fréchet distance in meters between
+// two lines, which is the shortest leash that lets a walker on each line
+// travel from start to end without either going backwards.
+func FrechetDistance(a, b orb.LineString) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return math.Inf(1)
+	}
+
+	// Only the previous row of the dynamic programming table is needed
+	prev := make([]float64, len(b))
+	curr := make([]float64, len(b))
+	for i := range a {
+		for j := range b {
+			d := geo.DistanceHaversine(a[i], b[j])
+			switch {
+			case i == 0 && j == 0:
+				curr[j] = d
+			case i == 0:
+				curr[j] = math.Max(curr[j-1], d)
+			case j == 0:
+				curr[j] = math.Max(prev[j], d)
+			default:
+				curr[j] = math.Max(math.Min(prev[j], math.Min(prev[j-1], curr[j-1])), d)
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)-1]
+}
+
+// resampleLine returns n points evenly spaced by distance along the line.
+func resampleLine(line orb.LineString, n int) orb.LineString {
+	if len(line) < 2 || n < 2 {
+		return line
+	}
+
+	cumulative := make([]float64, len(line))
+	for i := 1; i < len(line); i++ {
+		cumulative[i] = cumulative[i-1] + geo.DistanceHaversine(line[i-1], line[i])
+	}
+	total := cumulative[len(line)-1]
+	if total == 0 {
+		return orb.LineString{line[0]}
+	}
+
+	out := make(orb.LineString, 0, n)
+	seg := 1
+	for k := 0; k < n; k++ {
+		target := total * float64(k) / float64(n-1)
+		for seg < len(line)-1 && cumulative[seg] < target {
+			seg++
+		}
+		segLen := cumulative[seg] - cumulative[seg-1]
+		frac := 0.0
+		if segLen > 0 {
+			frac = (target - cumulative[seg-1]) / segLen
+		}
+		from, to := line[seg-1], line[seg]
+		out = append(out, orb.Point{
+			from[0] + (to[0]-from[0])*frac,
+			from[1] + (to[1]-from[1])*frac,
+		})
+	}
+	return out
+}
+
+// boundOverlap is the area of the intersection of the bounds over the area
+// of their union. Bounds with no area, such as of a line due north, are
+// compared by their extent instead.
+func boundOverlap(a, b orb.Bound) float64 {
+	if !a.Intersects(b) {
+		return 0
+	}
+	intersection := orb.Bound{
+		Min: orb.Point{math.Max(a.Min[0], b.Min[0]), math.Max(a.Min[1], b.Min[1])},
+		Max: orb.Point{math.Min(a.Max[0], b.Max[0]), math.Min(a.Max[1], b.Max[1])},
+	}
+	union := a.Union(b)
+
+	unionArea := boundArea(union)
+	if unionArea == 0 {
+		return boundExtent(intersection) / math.Max(boundExtent(union), 1e-12)
+	}
+	return boundArea(intersection) / unionArea
+}
+
+func boundArea(b orb.Bound) float64 {
+	return (b.Max[0] - b.Min[0]) * (b.Max[1] - b.Min[1])
+}
+
+func boundExtent(b orb.Bound) float64 {
+	return (b.Max[0] - b.Min[0]) + (b.Max[1] - b.Min[1])
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
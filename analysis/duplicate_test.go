@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func duplicateTestFeature(line orb.LineString, times ...interface{}) geojson.Feature {
+	f := *geojson.NewFeature(line)
+	if len(times) > 0 {
+		f.Properties.CoordinateProperties()["times"] = times
+	}
+	return f
+}
+
+func TestFrechetDistance(t *testing.T) {
+	a := orb.LineString{{-4.0, 56.0}, {-4.0, 56.01}}
+	assert.Equal(t, 0.0, FrechetDistance(a, a))
+
+	// Offset by about 111m north
+	b := orb.LineString{{-4.0, 56.001}, {-4.0, 56.011}}
+	assert.InDelta(t, 111, FrechetDistance(a, b), 1)
+
+	// The same route walked in reverse is as far apart as its ends
+	reversed := orb.LineString{{-4.0, 56.01}, {-4.0, 56.0}}
+	assert.InDelta(t, 1113, FrechetDistance(a, reversed), 1)
+}
+
+func TestLikelyDuplicate(t *testing.T) {
+	original := duplicateTestFeature(
+		orb.LineString{{-4.0, 56.0}, {-3.99, 56.005}, {-3.98, 56.01}, {-3.97, 56.02}},
+		"2024-06-12T09:00:00Z", "2024-06-12T09:10:00Z", "2024-06-12T09:20:00Z", "2024-06-12T09:30:00Z",
+	)
+
+	// Recorded by a second device with more points and a little GPS noise
+	otherDevice := duplicateTestFeature(
+		orb.LineString{{-4.0001, 56.0001}, {-3.995, 56.0025}, {-3.99, 56.0051}, {-3.985, 56.0075},
+			{-3.9801, 56.0099}, {-3.975, 56.015}, {-3.9701, 56.0201}},
+		"2024-06-12T09:02:00Z", "2024-06-12T09:07:00Z", "2024-06-12T09:12:00Z", "2024-06-12T09:17:00Z",
+		"2024-06-12T09:22:00Z", "2024-06-12T09:27:00Z", "2024-06-12T09:32:00Z",
+	)
+	dist, ok := LikelyDuplicate(original, otherDevice)
+	assert.True(t, ok)
+	assert.Less(t, dist, 30.0)
+
+	// The same route on another day
+	anotherDay := duplicateTestFeature(otherDevice.Geometry.(orb.LineString),
+		"2024-06-13T09:02:00Z", "2024-06-13T09:07:00Z", "2024-06-13T09:12:00Z", "2024-06-13T09:17:00Z",
+		"2024-06-13T09:22:00Z", "2024-06-13T09:27:00Z", "2024-06-13T09:32:00Z",
+	)
+	_, ok = LikelyDuplicate(original, anotherDay)
+	assert.False(t, ok)
+
+	// Without times only the shape is compared
+	_, ok = LikelyDuplicate(original, duplicateTestFeature(otherDevice.Geometry.(orb.LineString)))
+	assert.True(t, ok)
+
+	// Starting at the same time and place but going somewhere else
+	elsewhere := duplicateTestFeature(
+		orb.LineString{{-4.0, 56.0}, {-4.01, 56.005}, {-4.02, 56.01}, {-4.03, 56.02}},
+		"2024-06-12T09:00:00Z", "2024-06-12T09:10:00Z", "2024-06-12T09:20:00Z", "2024-06-12T09:30:00Z",
+	)
+	_, ok = LikelyDuplicate(original, elsewhere)
+	assert.False(t, ok)
+
+	// Only half of the route
+	half := duplicateTestFeature(
+		orb.LineString{{-4.0, 56.0}, {-3.99, 56.005}, {-3.98, 56.01}},
+		"2024-06-12T09:00:00Z", "2024-06-12T09:10:00Z", "2024-06-12T09:20:00Z",
+	)
+	_, ok = LikelyDuplicate(original, half)
+	assert.False(t, ok)
+}
+
+func TestResampleLine(t *testing.T) {
+	got := resampleLine(orb.LineString{{0, 0}, {0, 1}, {0, 3}}, 4)
+	assert.Len(t, got, 4)
+	assert.Equal(t, orb.Point{0, 0}, got[0])
+	assert.InDelta(t, 1, got[1][1], 1e-9)
+	assert.InDelta(t, 2, got[2][1], 1e-9)
+	assert.Equal(t, orb.Point{0, 3}, got[3])
+}
@@ -7,9 +7,7 @@ import (
 
 // TrackDuration calculates the duration of a track in seconds.
 func TrackDuration(feature geojson.Feature) (int, bool) {
-	coordProps := feature.Properties.CoordinateProperties()
-
-	times, ok := coordProps["times"].([]interface{})
+	times, ok := feature.Properties.CoordinateProperties()["times"].([]interface{})
 	if !ok {
 		return 0, false
 	}
@@ -17,6 +15,25 @@ func TrackDuration(feature geojson.Feature) (int, bool) {
 		return 0, true
 	}
 
+	first, last, ok := TrackTimeRange(feature)
+	if !ok {
+		return 0, false
+	}
+	return int(last.Sub(first).Seconds()), true
+}
+
+// TrackTimeRange finds the first and last times recorded along the track.
+func TrackTimeRange(feature geojson.Feature) (time.Time, time.Time, bool) {
+	if feature.Properties == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	coordProps := feature.Properties.CoordinateProperties()
+
+	times, ok := coordProps["times"].([]interface{})
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
 	var foundFirst bool
 	var first time.Time
 	for i := range times {
@@ -38,12 +55,12 @@ func TrackDuration(feature geojson.Feature) (int, bool) {
 		}
 	}
 	if !foundFirst || !foundLast {
-		return 0, false
+		return time.Time{}, time.Time{}, false
 	}
 
 	if first.After(last) {
-		return 0, false
+		return time.Time{}, time.Time{}, false
 	}
 
-	return int(last.Sub(first).Seconds()), true
+	return first, last, true
 }
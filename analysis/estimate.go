@@ -0,0 +1,220 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"math"
+	"time"
+)
+
+type EstimateMethod string
+
+const (
+	Naismith EstimateMethod = "naismith"
+	Tobler   EstimateMethod = "tobler"
+)
+
+const (
+	// Naismith's rule: 5 km/h on the flat plus 1 hour per 600 m of ascent
+	naismithSecsPerMeter       = 3600.0 / 5000.0
+	naismithSecsPerMeterAscent = 3600.0 / 600.0
+	// Langmuir's correction: 10 minutes per 300 m of descent, subtracted
+	// between 5 and 12 degrees and added for anything steeper
+	langmuirSecsPerMeterDescent = 600.0 / 300.0
+	langmuirGentleMinDegrees    = 5.0
+	langmuirSteepMinDegrees     = 12.0
+
+	// Segments slower than this are treated as stopped when computing moving
+	// time
+	minMovingSpeed = 0.3 // m/s
+	// Gaps longer than this are treated as paused recording
+	maxMovingGapSecs = 5 * 60
+)
+
+// Calibration holds personal multipliers applied to the raw estimates,
+// computed by comparing actual moving time to predicted time.
+type Calibration struct {
+	Naismith float64 `json:"naismith"`
+	Tobler   float64 `json:"tobler"`
+	Samples  int     `json:"samples"`
+}
+
+// CalibrationSample is a single historical track used to calibrate.
+type CalibrationSample struct {
+	MovingSecs   float64
+	NaismithSecs float64
+	ToblerSecs   float64
+}
+
+const (
+	minCalibrationSamples = 3
+	minCalibrationFactor  = 0.33
+	maxCalibrationFactor  = 3
+)
+
+// DefaultCalibration applies the raw estimates unchanged.
+var DefaultCalibration = Calibration{Naismith: 1, Tobler: 1}
+
+// Apply scales the estimate for the given method by the personal factor.
+func (c Calibration) Apply(method EstimateMethod, secs float64) float64 {
+	switch method {
+	case Naismith:
+		return math.Round(secs * c.Naismith)
+	case Tobler:
+		return math.Round(secs * c.Tobler)
+	default:
+		return secs
+	}
+}
+
+// TrackEstimates are the calibrated moving time estimates for a track.
+type TrackEstimates struct {
+	NaismithSecs float64 `json:"naismithSecs"`
+	ToblerSecs   float64 `json:"toblerSecs"`
+}
+
+// Estimates applies the calibration to the estimates stored in the
+// properties of a hydrated track. They are stored uncalibrated, both so that
+// they can be used as calibration samples and so that they stay valid as the
+// calibration changes.
+func (c Calibration) Estimates(f geojson.Feature) (TrackEstimates, bool) {
+	naismith, ok := f.Properties["naismithSecs"].(float64)
+	if !ok {
+		return TrackEstimates{}, false
+	}
+	tobler, ok := f.Properties["toblerSecs"].(float64)
+	if !ok {
+		return TrackEstimates{}, false
+	}
+	return TrackEstimates{
+		NaismithSecs: c.Apply(Naismith, naismith),
+		ToblerSecs:   c.Apply(Tobler, tobler),
+	}, true
+}
+
+// Calibrate computes personal factors from historical tracks. Each factor is
+// the ratio of total actual moving time to total predicted time, so longer
+// tracks carry more weight. If there are too few samples the default
+// calibration is returned.
+func Calibrate(samples []CalibrationSample) Calibration {
+	var moving, naismith, tobler float64
+	var n int
+	for _, s := range samples {
+		if s.MovingSecs <= 0 || s.NaismithSecs <= 0 || s.ToblerSecs <= 0 {
+			continue
+		}
+		moving += s.MovingSecs
+		naismith += s.NaismithSecs
+		tobler += s.ToblerSecs
+		n++
+	}
+	if n < minCalibrationSamples {
+		c := DefaultCalibration
+		c.Samples = n
+		return c
+	}
+	return Calibration{
+		Naismith: clampCalibrationFactor(moving / naismith),
+		Tobler:   clampCalibrationFactor(moving / tobler),
+		Samples:  n,
+	}
+}
+
+func clampCalibrationFactor(f float64) float64 {
+	return roundPlaces(math.Max(minCalibrationFactor, math.Min(maxCalibrationFactor, f)), 3)
+}
+
+// EstimateMovingTime predicts the moving time in seconds to walk the line.
+//
+// The elevations must correspond to the points of the line.
+func EstimateMovingTime(method EstimateMethod, line orb.LineString, elevations []float64) (float64, bool) {
+	if len(line) != len(elevations) {
+		return 0, false
+	}
+
+	var segmentTime func(dist, rise float64) float64
+	switch method {
+	case Naismith:
+		segmentTime = naismithSegmentTime
+	case Tobler:
+		segmentTime = toblerSegmentTime
+	default:
+		return 0, false
+	}
+
+	var total float64
+	for i := 1; i < len(line); i++ {
+		dist := geo.DistanceHaversine(line[i-1], line[i])
+		rise := elevations[i] - elevations[i-1]
+		total += segmentTime(dist, rise)
+	}
+	return math.Round(total), true
+}
+
+func naismithSegmentTime(dist, rise float64) float64 {
+	t := dist * naismithSecsPerMeter
+	if rise > 0 {
+		t += rise * naismithSecsPerMeterAscent
+	} else if rise < 0 && dist > 0 {
+		descent := -rise
+		degrees := math.Atan(descent/dist) * 180 / math.Pi
+		if degrees > langmuirSteepMinDegrees {
+			t += descent * langmuirSecsPerMeterDescent
+		} else if degrees >= langmuirGentleMinDegrees {
+			t -= descent * langmuirSecsPerMeterDescent
+		}
+	}
+	return math.Max(t, 0)
+}
+
+// toblerSegmentTime uses Tobler's hiking function, where walking speed in km/h
+// is 6 * exp(-3.5 * |slope + 0.05|).
+func toblerSegmentTime(dist, rise float64) float64 {
+	if dist == 0 {
+		return 0
+	}
+	slope := rise / dist
+	kmh := 6 * math.Exp(-3.5*math.Abs(slope+0.05))
+	return dist / (kmh * 1000 / 3600)
+}
+
+// TrackMovingTime calculates the time in seconds spent moving along a track,
+// excluding stops and pauses in recording.
+func TrackMovingTime(feature geojson.Feature) (int, bool) {
+	line, ok := feature.Geometry.(orb.LineString)
+	if !ok {
+		return 0, false
+	}
+
+	coordProps := feature.Properties.CoordinateProperties()
+	times, ok := coordProps["times"].([]interface{})
+	if !ok || len(times) != len(line) {
+		return 0, false
+	}
+
+	var total float64
+	var prev time.Time
+	prevI := -1
+	for i := range times {
+		t, ok := ParseSloppyRecentTime(times[i])
+		if !ok {
+			continue
+		}
+		if prevI >= 0 {
+			secs := t.Sub(prev).Seconds()
+			if secs > 0 && secs <= maxMovingGapSecs {
+				dist := geo.DistanceHaversine(line[prevI], line[i])
+				if dist/secs >= minMovingSpeed {
+					total += secs
+				}
+			}
+		}
+		prev = t
+		prevI = i
+	}
+	if prevI < 0 {
+		return 0, false
+	}
+	return int(total), true
+}
@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// roughly 1km due north
+var kmLine = orb.LineString{{-4, 56}, {-4, 56.008993}}
+
+func TestEstimateMovingTimeNaismith(t *testing.T) {
+	cases := []struct {
+		name       string
+		elevations []float64
+		expected   float64
+	}{
+		{"flat", []float64{100, 100}, 720},
+		{"ascent", []float64{100, 400}, 720 + 1800},
+		// ~5.7 degrees, so Langmuir subtracts time
+		{"gentle descent", []float64{200, 100}, 720 - 200},
+		// ~16.7 degrees, so Langmuir adds time
+		{"steep descent", []float64{400, 100}, 720 + 600},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := EstimateMovingTime(Naismith, kmLine, c.elevations)
+			require.True(t, ok)
+			assert.InDelta(t, c.expected, got, 1)
+		})
+	}
+}
+
+func TestEstimateMovingTimeTobler(t *testing.T) {
+	flat, ok := EstimateMovingTime(Tobler, kmLine, []float64{100, 100})
+	require.True(t, ok)
+	// 5.04 km/h on the flat
+	assert.InDelta(t, 715, flat, 1)
+
+	// Tobler is fastest on a slight descent
+	descent, ok := EstimateMovingTime(Tobler, kmLine, []float64{150, 100})
+	require.True(t, ok)
+	assert.Less(t, descent, flat)
+
+	ascent, ok := EstimateMovingTime(Tobler, kmLine, []float64{100, 200})
+	require.True(t, ok)
+	assert.Greater(t, ascent, flat)
+}
+
+func TestEstimateMovingTimeMismatchedElevations(t *testing.T) {
+	_, ok := EstimateMovingTime(Naismith, kmLine, []float64{100})
+	assert.False(t, ok)
+}
+
+func TestCalibrate(t *testing.T) {
+	got := Calibrate([]CalibrationSample{
+		{MovingSecs: 1200, NaismithSecs: 1000, ToblerSecs: 1500},
+		{MovingSecs: 2400, NaismithSecs: 2000, ToblerSecs: 3000},
+		{MovingSecs: 3600, NaismithSecs: 3000, ToblerSecs: 4500},
+		{MovingSecs: 0, NaismithSecs: 3000, ToblerSecs: 4500},
+	})
+	assert.Equal(t, Calibration{Naismith: 1.2, Tobler: 0.8, Samples: 3}, got)
+	assert.Equal(t, float64(1200), got.Apply(Naismith, 1000))
+}
+
+func TestCalibrateTooFewSamples(t *testing.T) {
+	got := Calibrate([]CalibrationSample{
+		{MovingSecs: 1200, NaismithSecs: 1000, ToblerSecs: 1500},
+	})
+	assert.Equal(t, Calibration{Naismith: 1, Tobler: 1, Samples: 1}, got)
+}
+
+func TestCalibrationEstimates(t *testing.T) {
+	f := geojson.NewFeature(kmLine)
+	f.Properties["naismithSecs"] = float64(1000)
+	f.Properties["toblerSecs"] = float64(1500)
+
+	got, ok := Calibration{Naismith: 1.2, Tobler: 0.8}.Estimates(*f)
+	require.True(t, ok)
+	assert.Equal(t, TrackEstimates{NaismithSecs: 1200, ToblerSecs: 1200}, got)
+
+	_, ok = DefaultCalibration.Estimates(*geojson.NewFeature(kmLine))
+	assert.False(t, ok)
+}
+
+func TestTrackMovingTime(t *testing.T) {
+	f := sampleFeature()
+	got, ok := TrackMovingTime(f)
+	require.True(t, ok)
+	assert.Equal(t, 7, got)
+}
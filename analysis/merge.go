@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"reflect"
+)
+
+// MergeTracks concatenates the tracks in the order given into a single track
+// and recomputes its stats. The properties of the first track are kept.
+//
+// Coordinate properties are concatenated so they stay aligned with the points.
+// Where a track lacks a coordinate property present in another the gap is
+// filled with nulls.
+func MergeTracks(features []geojson.Feature) (geojson.Feature, error) {
+	if len(features) == 0 {
+		return geojson.Feature{}, fmt.Errorf("no tracks to merge")
+	}
+
+	var lines []orb.LineString
+	var total int
+	keys := make(map[string]struct{})
+	for i, f := range features {
+		line, ok := f.Geometry.(orb.LineString)
+		if !ok {
+			return geojson.Feature{}, fmt.Errorf("track %d: expected LineString, got %s", i, f.Geometry.GeoJSONType())
+		}
+		lines = append(lines, line)
+		total += len(line)
+
+		for k, v := range f.Properties.CoordinateProperties() {
+			rv := reflect.ValueOf(v)
+			if rv.Kind() == reflect.Slice && rv.Len() == len(line) {
+				keys[k] = struct{}{}
+			}
+		}
+	}
+
+	merged := make(orb.LineString, 0, total)
+	for _, line := range lines {
+		merged = append(merged, line...)
+	}
+
+	mergedCoordProps := make(map[string]interface{}, len(keys))
+	for k := range keys {
+		values := make([]interface{}, 0, total)
+		for i, f := range features {
+			rv := reflect.ValueOf(f.Properties.CoordinateProperties()[k])
+			if rv.Kind() == reflect.Slice && rv.Len() == len(lines[i]) {
+				for j := 0; j < rv.Len(); j++ {
+					values = append(values, rv.Index(j).Interface())
+				}
+			} else {
+				values = append(values, make([]interface{}, len(lines[i]))...)
+			}
+		}
+		mergedCoordProps[k] = values
+	}
+
+	out := *geojson.NewFeature(merged)
+	out.Properties = features[0].Properties.Clone()
+	out.Properties["coordinateProperties"] = mergedCoordProps
+	RecomputeStats(out)
+	return out, nil
+}
@@ -0,0 +1,38 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMergeTracks(t *testing.T) {
+	first, second, err := SplitTrack(idleFeature(), 4)
+	require.NoError(t, err)
+
+	got, err := MergeTracks([]geojson.Feature{first, second})
+	require.NoError(t, err)
+
+	require.Len(t, got.Geometry, 9)
+	coordProps := got.Properties.CoordinateProperties()
+	assert.Len(t, coordProps["times"], 9)
+	assert.Equal(t, []interface{}{1.0, 2.0, 3.0, 4.0, 5.0, 5.0, 6.0, 7.0, 8.0}, coordProps["elevationMeters"])
+	assert.Equal(t, 15*60, got.Properties["durationSecs"])
+	assert.Contains(t, got.Properties, "naismithSecs")
+}
+
+func TestMergeTracksFillsMissingCoordinateProperties(t *testing.T) {
+	withTimes := sampleFeature()
+	withoutTimes := *geojson.NewFeature(orb.LineString{{-4, 56}, {-4, 56.001}})
+
+	got, err := MergeTracks([]geojson.Feature{withTimes, withoutTimes})
+	require.NoError(t, err)
+
+	times := got.Properties.CoordinateProperties()["times"].([]interface{})
+	require.Len(t, times, 5)
+	assert.Equal(t, "2024-06-12T09:04:06Z", times[2])
+	assert.Nil(t, times[3])
+	assert.Nil(t, times[4])
+}
@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"context"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+const (
+	PlaceKindPlace = "place"
+	PlaceKindPeak  = "peak"
+)
+
+const (
+	// Start and end points are labelled with anything nearby, such as the
+	// village or car park the track set off from
+	maxEndpointPlaceMeters = 3000
+	// The highest point is only labelled as a peak if the track was actually
+	// close to it, otherwise it falls back to the nearest place
+	maxSummitPeakMeters  = 300
+	maxSummitPlaceMeters = 3000
+)
+
+// PlaceQuerier looks up named places from a gazetteer.
+type PlaceQuerier interface {
+	// NearestPlace returns the place of the kind closest to the point and
+	// within the distance, or false if there is none. An empty kind matches
+	// any place.
+	NearestPlace(ctx context.Context, point orb.Point, kind string, maxMeters float64) (Place, bool, error)
+}
+
+type Place struct {
+	Name  string
+	Kind  string
+	Point orb.Point
+}
+
+// LabelPlaces stores the names of the places nearest the start, end and
+// highest point of a hydrated track in the startPlaceName, endPlaceName and
+// summitPlaceName properties.
+func LabelPlaces(ctx context.Context, places PlaceQuerier, f geojson.Feature) error {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok || len(line) == 0 {
+		return nil
+	}
+	props := f.Properties
+
+	start, err := nearestPlaceName(ctx, places, line[0], "", maxEndpointPlaceMeters)
+	if err != nil {
+		return err
+	}
+	setOrDeleteProperty(props, "startPlaceName", start)
+
+	end, err := nearestPlaceName(ctx, places, line[len(line)-1], "", maxEndpointPlaceMeters)
+	if err != nil {
+		return err
+	}
+	setOrDeleteProperty(props, "endPlaceName", end)
+
+	var summit string
+	if i, ok := highestPointIndex(props.CoordinateProperties()["elevationMeters"]); ok {
+		summit, err = nearestPlaceName(ctx, places, line[i], PlaceKindPeak, maxSummitPeakMeters)
+		if err != nil {
+			return err
+		}
+		if summit == "" {
+			summit, err = nearestPlaceName(ctx, places, line[i], "", maxSummitPlaceMeters)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	setOrDeleteProperty(props, "summitPlaceName", summit)
+
+	return nil
+}
+
+// PlacesName suggests a name for a track from its place labels, or returns
+// false if it has none.
+func PlacesName(f geojson.Feature) (string, bool) {
+	start, _ := f.Properties["startPlaceName"].(string)
+	end, _ := f.Properties["endPlaceName"].(string)
+	summit, _ := f.Properties["summitPlaceName"].(string)
+
+	switch {
+	case summit != "" && start != "" && summit != start:
+		return summit + " from " + start, true
+	case start != "" && end != "" && start != end:
+		return start + " to " + end, true
+	case start != "":
+		return start, true
+	case summit != "":
+		return summit, true
+	case end != "":
+		return end, true
+	default:
+		return "", false
+	}
+}
+
+func nearestPlaceName(ctx context.Context, places PlaceQuerier, pt orb.Point, kind string, maxMeters float64) (string, error) {
+	place, ok, err := places.NearestPlace(ctx, pt, kind, maxMeters)
+	if err != nil || !ok {
+		return "", err
+	}
+	return place.Name, nil
+}
+
+func highestPointIndex(prop interface{}) (int, bool) {
+	elevations, ok := floatsFromCoordinateProperty(prop)
+	if !ok || len(elevations) == 0 {
+		return 0, false
+	}
+	highest := 0
+	for i, e := range elevations {
+		if e > elevations[highest] {
+			highest = i
+		}
+	}
+	return highest, true
+}
+
+func setOrDeleteProperty(props geojson.Properties, key string, value string) {
+	if value == "" {
+		delete(props, key)
+	} else {
+		props[key] = value
+	}
+}
@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"context"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type mockPlaceQuerier []Place
+
+func (m mockPlaceQuerier) NearestPlace(_ context.Context, pt orb.Point, kind string, maxMeters float64) (Place, bool, error) {
+	var best Place
+	bestDist := maxMeters
+	found := false
+	for _, p := range m {
+		if kind != "" && p.Kind != kind {
+			continue
+		}
+		if d := geo.DistanceHaversine(pt, p.Point); d <= bestDist {
+			best, bestDist, found = p, d, true
+		}
+	}
+	return best, found, nil
+}
+
+func TestLabelPlaces(t *testing.T) {
+	places := mockPlaceQuerier{
+		{Name: "Lawers", Kind: PlaceKindPlace, Point: orb.Point{-4.2, 56.52}},
+		{Name: "Ben Lawers", Kind: PlaceKindPeak, Point: orb.Point{-4.2214, 56.5449}},
+		{Name: "Beinn Ghlas", Kind: PlaceKindPeak, Point: orb.Point{-4.2372, 56.5372}},
+	}
+
+	f := *geojson.NewFeature(orb.LineString{{-4.2001, 56.5201}, {-4.2213, 56.5448}, {-4.2, 56.52}})
+	f.Properties.CoordinateProperties()["elevationMeters"] = []float64{200, 1210, 200}
+
+	require.NoError(t, LabelPlaces(context.Background(), places, f))
+	assert.Equal(t, "Lawers", f.Properties["startPlaceName"])
+	assert.Equal(t, "Lawers", f.Properties["endPlaceName"])
+	assert.Equal(t, "Ben Lawers", f.Properties["summitPlaceName"])
+
+	name, ok := PlacesName(f)
+	require.True(t, ok)
+	assert.Equal(t, "Ben Lawers from Lawers", name)
+}
+
+func TestLabelPlacesNothingNearby(t *testing.T) {
+	f := *geojson.NewFeature(orb.LineString{{0, 0}, {0, 0.001}})
+	f.Properties["startPlaceName"] = "Stale"
+
+	require.NoError(t, LabelPlaces(context.Background(), mockPlaceQuerier{}, f))
+	assert.NotContains(t, f.Properties, "startPlaceName")
+	assert.NotContains(t, f.Properties, "summitPlaceName")
+
+	_, ok := PlacesName(f)
+	assert.False(t, ok)
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"reflect"
+	"slices"
+)
+
+// PrivacyZone is a circle, such as around the user's home, where their tracks
+// shouldn't be shown to others.
+type PrivacyZone struct {
+	Center       orb.Point
+	RadiusMeters float64
+}
+
+// MaskPrivacyZones returns a copy of the track with the points inside any of
+// the zones removed, along with the matching coordinate properties. Where the
+// track passes through a zone it is split into the parts of a
+// MultiLineString, so that nothing is drawn across the zone. It returns
+// false if nothing is left to show.
+//
+// The stats of a masked track are recomputed over what remains and so are
+// marked approximate. Place labels of points inside a zone are dropped.
+func MaskPrivacyZones(f geojson.Feature, zones []PrivacyZone) (geojson.Feature, bool) {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok || len(zones) == 0 {
+		return f, true
+	}
+
+	// Runs of consecutive points outside the zones
+	hidden := make([]bool, len(line))
+	var parts [][]int
+	var part []int
+	for i, pt := range line {
+		if inAnyPrivacyZone(pt, zones) {
+			hidden[i] = true
+			if len(part) > 0 {
+				parts = append(parts, part)
+				part = nil
+			}
+			continue
+		}
+		part = append(part, i)
+	}
+	if len(part) > 0 {
+		parts = append(parts, part)
+	}
+	if len(parts) == 1 && len(parts[0]) == len(line) {
+		return f, true
+	}
+	// A lone point between zones can't be drawn as a line
+	parts = slices.DeleteFunc(parts, func(part []int) bool { return len(part) < 2 })
+	if len(parts) == 0 {
+		return geojson.Feature{}, false
+	}
+
+	var keep []int
+	for _, part := range parts {
+		keep = append(keep, part...)
+	}
+
+	// The stats are computed as if the parts were joined
+	out := *geojson.NewFeature(selectPoints(line, keep))
+	out.ID = f.ID
+	out.Properties = f.Properties.Clone()
+	coordProps := f.Properties.CoordinateProperties()
+	maskedCoordProps := selectCoordinateProperties(coordProps, len(line), keep)
+	out.Properties["coordinateProperties"] = maskedCoordProps
+
+	// The time of the first point may have been within a zone
+	delete(out.Properties, "time")
+	if times, ok := maskedCoordProps["times"].([]interface{}); ok && len(times) > 0 {
+		out.Properties["time"] = times[0]
+	}
+
+	// The labels would give away where the hidden points are
+	if hidden[0] {
+		delete(out.Properties, "startPlaceName")
+	}
+	if hidden[len(line)-1] {
+		delete(out.Properties, "endPlaceName")
+	}
+	if i, ok := highestPointIndex(coordProps["elevationMeters"]); !ok || i >= len(line) || hidden[i] {
+		delete(out.Properties, "summitPlaceName")
+	}
+
+	RecomputeStats(out)
+	out.Properties["masked"] = true
+	out.Properties["statsApproximate"] = true
+
+	if len(parts) > 1 {
+		multi := make(orb.MultiLineString, 0, len(parts))
+		// Coordinate properties of a MultiLineString have an array per part
+		multiCoordProps := make(map[string]interface{}, len(maskedCoordProps))
+		for _, part := range parts {
+			multi = append(multi, selectPoints(line, part))
+			for k, v := range selectCoordinateProperties(coordProps, len(line), part) {
+				values, _ := multiCoordProps[k].([]interface{})
+				multiCoordProps[k] = append(values, v)
+			}
+		}
+		out.Geometry = multi
+		out.Properties["coordinateProperties"] = multiCoordProps
+	}
+
+	return out, true
+}
+
+func selectPoints(line orb.LineString, indices []int) orb.LineString {
+	out := make(orb.LineString, 0, len(indices))
+	for _, i := range indices {
+		out = append(out, line[i])
+	}
+	return out
+}
+
+// selectCoordinateProperties picks the values at the indices from each
+// coordinate property with a value per point, dropping any others.
+func selectCoordinateProperties(coordProps map[string]interface{}, n int, indices []int) map[string]interface{} {
+	out := make(map[string]interface{}, len(coordProps))
+	for k, v := range coordProps {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice || rv.Len() != n {
+			continue
+		}
+		values := reflect.MakeSlice(rv.Type(), 0, len(indices))
+		for _, i := range indices {
+			values = reflect.Append(values, rv.Index(i))
+		}
+		out[k] = values.Interface()
+	}
+	return out
+}
+
+func inAnyPrivacyZone(pt orb.Point, zones []PrivacyZone) bool {
+	for _, zone := range zones {
+		if geo.DistanceHaversine(zone.Center, pt) <= zone.RadiusMeters {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMaskPrivacyZones(t *testing.T) {
+	input := idleFeature()
+	home := PrivacyZone{Center: orb.Point{-4.0, 56.0}, RadiusMeters: 50}
+
+	got, ok := MaskPrivacyZones(input, []PrivacyZone{home})
+	require.True(t, ok)
+
+	require.Equal(t, orb.LineString{
+		{-4.0, 56.001},
+		{-4.0, 56.002},
+		{-4.0, 56.003},
+		{-4.00001, 56.00301},
+		{-4.0, 56.003},
+	}, got.Geometry)
+	coordProps := got.Properties.CoordinateProperties()
+	assert.Equal(t, []float64{4, 5, 6, 7, 8}, coordProps["elevationMeters"])
+	assert.Equal(t, "2024-06-12T09:05:00Z", got.Properties["time"])
+	assert.Equal(t, 10*60, got.Properties["durationSecs"])
+	assert.Equal(t, true, got.Properties["statsApproximate"])
+
+	// The input is left untouched
+	assert.Len(t, input.Geometry, 8)
+}
+
+func TestMaskPrivacyZonesDropsPlaceLabels(t *testing.T) {
+	input := idleFeature()
+	input.Properties["startPlaceName"] = "Home"
+	input.Properties["endPlaceName"] = "Lawers"
+	input.Properties["summitPlaceName"] = "Ben Lawers"
+	home := PrivacyZone{Center: orb.Point{-4.0, 56.0}, RadiusMeters: 50}
+
+	got, ok := MaskPrivacyZones(input, []PrivacyZone{home})
+	require.True(t, ok)
+	assert.NotContains(t, got.Properties, "startPlaceName")
+	assert.Equal(t, "Lawers", got.Properties["endPlaceName"])
+	assert.Equal(t, "Ben Lawers", got.Properties["summitPlaceName"])
+
+	// The summit is the last point
+	end := PrivacyZone{Center: orb.Point{-4.0, 56.003}, RadiusMeters: 50}
+	got, ok = MaskPrivacyZones(input, []PrivacyZone{end})
+	require.True(t, ok)
+	assert.Equal(t, "Home", got.Properties["startPlaceName"])
+	assert.NotContains(t, got.Properties, "endPlaceName")
+	assert.NotContains(t, got.Properties, "summitPlaceName")
+}
+
+func TestMaskPrivacyZonesOutsideZones(t *testing.T) {
+	input := idleFeature()
+	elsewhere := PrivacyZone{Center: orb.Point{0, 0}, RadiusMeters: 1000}
+
+	got, ok := MaskPrivacyZones(input, []PrivacyZone{elsewhere})
+	assert.True(t, ok)
+	assert.Len(t, got.Geometry, 8)
+	assert.NotContains(t, got.Properties, "statsApproximate")
+}
+
+func TestMaskPrivacyZonesSplitsAtZones(t *testing.T) {
+	input := idleFeature()
+	// Around the middle of the walk
+	zone := PrivacyZone{Center: orb.Point{-4.0, 56.002}, RadiusMeters: 50}
+
+	got, ok := MaskPrivacyZones(input, []PrivacyZone{zone})
+	require.True(t, ok)
+
+	require.Equal(t, orb.MultiLineString{
+		{{-4.0, 56.0}, {-4.00001, 56.00001}, {-4.00002, 56.0}, {-4.0, 56.001}},
+		{{-4.0, 56.003}, {-4.00001, 56.00301}, {-4.0, 56.003}},
+	}, got.Geometry)
+	coordProps := got.Properties.CoordinateProperties()
+	assert.Equal(t, []interface{}{[]float64{1, 2, 3, 4}, []float64{6, 7, 8}}, coordProps["elevationMeters"])
+	assert.Equal(t, "2024-06-12T09:00:00Z", got.Properties["time"])
+	assert.Equal(t, true, got.Properties["statsApproximate"])
+}
+
+func TestMaskPrivacyZonesEntirelyInside(t *testing.T) {
+	input := idleFeature()
+	zone := PrivacyZone{Center: orb.Point{-4.0, 56.0015}, RadiusMeters: 1000}
+
+	_, ok := MaskPrivacyZones(input, []PrivacyZone{zone})
+	assert.False(t, ok)
+}
+
+func TestMaskPrivacyZonesDropsLonePoints(t *testing.T) {
+	input := idleFeature()
+	zones := []PrivacyZone{
+		{Center: orb.Point{-4.0, 56.0}, RadiusMeters: 50},
+		// Leaves only {-4.0, 56.001} between the zones
+		{Center: orb.Point{-4.0, 56.0025}, RadiusMeters: 100},
+	}
+
+	_, ok := MaskPrivacyZones(input, zones)
+	assert.False(t, ok)
+}
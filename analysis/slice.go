@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"reflect"
+	"time"
+)
+
+// SliceTrack returns a copy of the track containing the points in the range
+// [start, end). Coordinate properties with one value per point are sliced to
+// match, and the time property is moved to the new first point if known. The
+// stats are not recomputed.
+func SliceTrack(f geojson.Feature, start, end int) (geojson.Feature, error) {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok {
+		return geojson.Feature{}, fmt.Errorf("expected LineString, got %s", f.Geometry.GeoJSONType())
+	}
+	if start < 0 || end > len(line) || start >= end {
+		return geojson.Feature{}, fmt.Errorf("invalid slice [%d, %d) of %d points", start, end, len(line))
+	}
+
+	sliced := make(orb.LineString, end-start)
+	copy(sliced, line[start:end])
+
+	out := *geojson.NewFeature(sliced)
+	out.ID = f.ID
+	out.BBox = nil
+	out.Properties = f.Properties.Clone()
+
+	coordProps := f.Properties.CoordinateProperties()
+	slicedCoordProps := make(map[string]interface{}, len(coordProps))
+	for k, v := range coordProps {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Slice && rv.Len() == len(line) {
+			slicedCoordProps[k] = rv.Slice(start, end).Interface()
+		} else {
+			slicedCoordProps[k] = v
+		}
+	}
+	out.Properties["coordinateProperties"] = slicedCoordProps
+
+	if times, ok := slicedCoordProps["times"].([]interface{}); ok && start > 0 {
+		for _, t := range times {
+			if _, ok := ParseSloppyRecentTime(t); ok {
+				out.Properties["time"] = t
+				break
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// SliceOffset finds where the points of sliced start in f, if they are a
+// contiguous run of its points as SliceTrack returns.
+func SliceOffset(f geojson.Feature, sliced geojson.Feature) (int, bool) {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok {
+		return 0, false
+	}
+	part, ok := sliced.Geometry.(orb.LineString)
+	if !ok || len(part) == 0 {
+		return 0, false
+	}
+	for start := 0; start+len(part) <= len(line); start++ {
+		if line[start : start+len(part)].Equal(part) {
+			return start, true
+		}
+	}
+	return 0, false
+}
+
+// SplitTrack splits the track in two at the point index, which is included in
+// both parts. Both parts have their stats recomputed.
+func SplitTrack(f geojson.Feature, index int) (geojson.Feature, geojson.Feature, error) {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok {
+		return geojson.Feature{}, geojson.Feature{}, fmt.Errorf("expected LineString, got %s", f.Geometry.GeoJSONType())
+	}
+	if index < 1 || index >= len(line)-1 {
+		return geojson.Feature{}, geojson.Feature{}, fmt.Errorf("invalid split index %d of %d points", index, len(line))
+	}
+
+	first, err := SliceTrack(f, 0, index+1)
+	if err != nil {
+		return geojson.Feature{}, geojson.Feature{}, err
+	}
+	second, err := SliceTrack(f, index, len(line))
+	if err != nil {
+		return geojson.Feature{}, geojson.Feature{}, err
+	}
+	RecomputeStats(first)
+	RecomputeStats(second)
+	return first, second, nil
+}
+
+// IndexAtTime finds the index of the first point recorded at or after t.
+func IndexAtTime(f geojson.Feature, t time.Time) (int, bool) {
+	times, ok := f.Properties.CoordinateProperties()["times"].([]interface{})
+	if !ok {
+		return 0, false
+	}
+	for i := range times {
+		pt, ok := ParseSloppyRecentTime(times[i])
+		if ok && !pt.Before(t) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// floatsFromCoordinateProperty reads a numeric coordinate property, which is
+// a []float64 if set by the analyzer or a []interface{} if decoded from JSON.
+func floatsFromCoordinateProperty(v interface{}) ([]float64, bool) {
+	switch v := v.(type) {
+	case []float64:
+		return v, true
+	case []interface{}:
+		out := make([]float64, len(v))
+		for i := range v {
+			n, ok := v[i].(float64)
+			if !ok {
+				return nil, false
+			}
+			out[i] = n
+		}
+		return out, true
+	default:
+		return nil, false
+	}
+}
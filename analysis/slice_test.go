@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSliceTrack(t *testing.T) {
+	input := sampleFeature()
+	input.Properties.CoordinateProperties()["elevationMeters"] = []float64{1, 2, 3}
+
+	got, err := SliceTrack(input, 1, 3)
+	require.NoError(t, err)
+
+	require.Len(t, got.Geometry, 2)
+	assert.Equal(t, input.Geometry.(orb.LineString)[1], got.Geometry.(orb.LineString)[0])
+	coordProps := got.Properties.CoordinateProperties()
+	assert.Equal(t, []interface{}{"2024-06-12T09:04:00Z", "2024-06-12T09:04:06Z"}, coordProps["times"])
+	assert.Equal(t, []float64{2, 3}, coordProps["elevationMeters"])
+	assert.Equal(t, "6/12/2024", got.Properties["name"])
+}
+
+func TestSliceTrackInvalidRange(t *testing.T) {
+	input := sampleFeature()
+	_, err := SliceTrack(input, 2, 2)
+	assert.Error(t, err)
+	_, err = SliceTrack(input, 0, 4)
+	assert.Error(t, err)
+}
+
+func TestSliceOffset(t *testing.T) {
+	input := idleFeature()
+	sliced, err := SliceTrack(input, 2, 6)
+	require.NoError(t, err)
+
+	got, ok := SliceOffset(input, sliced)
+	require.True(t, ok)
+	assert.Equal(t, 2, got)
+
+	_, ok = SliceOffset(sliced, input)
+	assert.False(t, ok)
+}
+
+func TestSplitTrack(t *testing.T) {
+	input := idleFeature()
+
+	first, second, err := SplitTrack(input, 4)
+	require.NoError(t, err)
+
+	require.Len(t, first.Geometry, 5)
+	require.Len(t, second.Geometry, 4)
+	assert.Equal(t, first.Geometry.(orb.LineString)[4], second.Geometry.(orb.LineString)[0])
+	assert.Equal(t, []float64{5, 6, 7, 8}, second.Properties.CoordinateProperties()["elevationMeters"])
+	assert.Equal(t, "2024-06-12T09:06:00Z", second.Properties["time"])
+	assert.Equal(t, 6*60, first.Properties["durationSecs"])
+	assert.Equal(t, 9*60, second.Properties["durationSecs"])
+}
+
+func TestSplitTrackAtEnds(t *testing.T) {
+	input := idleFeature()
+	_, _, err := SplitTrack(input, 0)
+	assert.Error(t, err)
+	_, _, err = SplitTrack(input, 7)
+	assert.Error(t, err)
+}
+
+func TestIndexAtTime(t *testing.T) {
+	input := idleFeature()
+	got, ok := IndexAtTime(input, time.Date(2024, 6, 12, 9, 5, 30, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, 4, got)
+
+	_, ok = IndexAtTime(input, time.Date(2024, 6, 13, 0, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+}
@@ -1,53 +1,176 @@
 package analysis
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
 
+var (
+	ErrTimeUnsupportedType = fmt.Errorf("unsupported time type")
+	ErrTimeInvalidFormat   = fmt.Errorf("invalid time format")
+	ErrTimeAmbiguousZone   = fmt.Errorf("ambiguous time zone abbreviation")
+	ErrTimeNotRecent       = fmt.Errorf("time not recent")
+)
+
+var (
+	// EarliestRecentTime is the GPS epoch, before which no track could have
+	// been recorded
+	EarliestRecentTime = time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC)
+	// MaxFutureSkew allows for devices with clocks set slightly ahead
+	MaxFutureSkew = 48 * time.Hour
+)
+
+// Numbers up to these magnitudes are treated as Unix times in the unit. The
+// ranges don't overlap for any time between the GPS epoch and the year 5000.
+const (
+	maxUnixSecs   = 1e11
+	maxUnixMillis = 1e14
+	maxUnixMicros = 1e17
+)
+
+// Layouts tried in order. Fractional seconds are accepted after the seconds
+// of any layout.
+var timeLayouts = []string{
+	// ISO 8601 extended format, with each form of offset
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05Z07",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04Z0700",
+	// ISO 8601 basic format
+	"20060102T150405Z0700",
+	"20060102T150405Z07:00",
+	"20060102T150405Z07",
+	time.RFC1123Z, time.RFC822Z, time.RubyDate,
+	// Without a zone, taken to be UTC
+	"2006-01-02T15:04:05", "2006-01-02T15:04", time.ANSIC,
+}
+
+// Layouts with a zone abbreviation, which time.Parse can only resolve for
+// UTC and the local zone
+var timeLayoutsWithAbbreviation = []string{
+	time.RFC1123, time.RFC822, time.RFC850, time.UnixDate,
+}
+
+// ParseSloppyRecentTime parses a time in any of the formats found in track
+// files, returning false if it can't be parsed or isn't recent.
 func ParseSloppyRecentTime(v interface{}) (time.Time, bool) {
-	if n, ok := v.(int64); ok {
-		return parseSloppyRecentTimeInt(n)
-	}
-	if n, ok := v.(int32); ok {
-		return parseSloppyRecentTimeInt(int64(n))
+	t, err := ParseRecentTime(v)
+	return t, err == nil
+}
+
+// ParseRecentTime parses a time in any of the formats found in track files:
+//
+//   - A Unix time in seconds, milliseconds or microseconds, as a number or a
+//     numeric string. The unit is detected from the magnitude.
+//   - An ISO 8601 time, with optional fractional seconds and offset. A time
+//     without an offset is taken to be UTC.
+//   - An RFC 822, RFC 1123 or RFC 850 time, or the output of date.
+//
+// The time must be between EarliestRecentTime and MaxFutureSkew from now.
+// Times with an offset keep it, and all other times are in UTC.
+func ParseRecentTime(v interface{}) (time.Time, error) {
+	var t time.Time
+	var err error
+	switch v := v.(type) {
+	case int64:
+		t, err = parseUnixTime(float64(v))
+	case int32:
+		t, err = parseUnixTime(float64(v))
+	case int:
+		t, err = parseUnixTime(float64(v))
+	case float64:
+		t, err = parseUnixTime(v)
+	case float32:
+		t, err = parseUnixTime(float64(v))
+	case string:
+		t, err = parseTimeString(v)
+	default:
+		return time.Time{}, fmt.Errorf("%w: %T", ErrTimeUnsupportedType, v)
 	}
-	if n, ok := v.(int); ok {
-		return parseSloppyRecentTimeInt(int64(n))
+	if err != nil {
+		return time.Time{}, err
 	}
-	if n, ok := v.(float64); ok {
-		return parseSloppyRecentTimeInt(int64(n))
+
+	if t.Before(EarliestRecentTime) || t.After(time.Now().Add(MaxFutureSkew)) {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrTimeNotRecent, t.Format(time.RFC3339))
 	}
-	if n, ok := v.(float32); ok {
-		return parseSloppyRecentTimeInt(int64(n))
+	return t, nil
+}
+
+func parseUnixTime(n float64) (time.Time, error) {
+	if math.IsNaN(n) || math.IsInf(n, 0) || n <= 0 {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrTimeNotRecent, n)
 	}
-	if s, ok := v.(string); ok {
-		return parseSloppyRecentTimeString(s)
+
+	var nanosPerUnit float64
+	switch {
+	case n < maxUnixSecs:
+		nanosPerUnit = 1e9
+	case n < maxUnixMillis:
+		nanosPerUnit = 1e6
+	case n < maxUnixMicros:
+		nanosPerUnit = 1e3
+	default:
+		return time.Time{}, fmt.Errorf("%w: %v", ErrTimeNotRecent, n)
 	}
-	return time.Time{}, false
+
+	whole, frac := math.Modf(n)
+	unitsPerSec := 1e9 / nanosPerUnit
+	secs := math.Floor(whole / unitsPerSec)
+	nanos := (whole-secs*unitsPerSec)*nanosPerUnit + math.Round(frac*nanosPerUnit)
+	return time.Unix(int64(secs), int64(nanos)).UTC(), nil
 }
 
-func parseSloppyRecentTimeInt(v int64) (time.Time, bool) {
-	t := time.Unix(v, 0).UTC()
-	if isRecent(t) {
-		return t, true
-	} else {
-		return time.Time{}, false
+func parseTimeString(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("%w: empty", ErrTimeInvalidFormat)
+	}
+
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return parseUnixTime(n)
 	}
-}
 
-func parseSloppyRecentTimeString(v string) (time.Time, bool) {
-	layouts := []string{
-		time.RFC3339, time.RFC3339Nano, time.RFC1123, time.RFC1123Z, time.RFC822, time.RFC822Z, time.RFC850,
-		time.UnixDate, time.RubyDate, time.DateTime, time.ANSIC,
+	normalized := normalizeISOTime(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t, nil
+		}
 	}
-	for _, layout := range layouts {
-		t, err := time.Parse(layout, v)
-		if err == nil && isRecent(t) {
-			return t, true
+	for _, layout := range timeLayoutsWithAbbreviation {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		// Otherwise time.Parse invents a zone with no offset
+		if name, offset := t.Zone(); offset == 0 && name != "UTC" && name != "GMT" {
+			return time.Time{}, fmt.Errorf("%w: %s", ErrTimeAmbiguousZone, name)
 		}
+		return t, nil
 	}
-	return time.Time{}, false
+	return time.Time{}, fmt.Errorf("%w: %q", ErrTimeInvalidFormat, s)
 }
 
-func isRecent(t time.Time) bool {
-	currentYear := time.Now().Year()
-	return t.Year() > currentYear-200 && t.Year() < currentYear+200
+// normalizeISOTime accepts the variations ISO 8601 allows that time.Parse
+// doesn't: a lowercase t or z, a space between the date and time, and a comma
+// before fractional seconds.
+func normalizeISOTime(s string) string {
+	if len(s) < 11 || s[4] != '-' || s[7] != '-' {
+		return s
+	}
+	b := []byte(s)
+	if b[10] == ' ' || b[10] == 't' {
+		b[10] = 'T'
+	}
+	if b[len(b)-1] == 'z' {
+		b[len(b)-1] = 'Z'
+	}
+	if len(b) > 19 && b[19] == ',' {
+		b[19] = '.'
+	}
+	return string(b)
 }
@@ -2,6 +2,8 @@ package analysis
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -14,9 +16,32 @@ func TestParseSloppyRecentTime(t *testing.T) {
 	}{
 		{"int", 1718545009, "2024-06-16T13:36:49Z"},
 		{"float", 1718545009.0, "2024-06-16T13:36:49Z"},
+		{"fractional seconds", 1718545009.25, "2024-06-16T13:36:49.25Z"},
+		{"millis", int64(1718545009123), "2024-06-16T13:36:49.123Z"},
+		{"millis float", 1718545009123.0, "2024-06-16T13:36:49.123Z"},
+		{"micros", int64(1718545009123456), "2024-06-16T13:36:49.123456Z"},
+		{"numeric string", "1718545009123", "2024-06-16T13:36:49.123Z"},
+		{"RFC3339", "2024-06-16T13:36:49Z", "2024-06-16T13:36:49Z"},
+		{"RFC3339 nano", "2024-06-16T13:36:49.123456789Z", "2024-06-16T13:36:49.123456789Z"},
+		{"offset", "2024-06-16T14:36:49+01:00", "2024-06-16T14:36:49+01:00"},
+		{"offset without colon", "2024-06-16T14:36:49.5+0100", "2024-06-16T14:36:49.5+01:00"},
+		{"offset hours", "2024-06-16T08:36:49-05", "2024-06-16T08:36:49-05:00"},
+		{"lowercase", "2024-06-16t13:36:49z", "2024-06-16T13:36:49Z"},
+		{"space separator", "2024-06-16 13:36:49Z", "2024-06-16T13:36:49Z"},
+		{"comma fraction", "2024-06-16T13:36:49,5Z", "2024-06-16T13:36:49.5Z"},
+		{"no seconds", "2024-06-16T13:36Z", "2024-06-16T13:36:00Z"},
+		{"no offset", "2024-06-16T13:36:49", "2024-06-16T13:36:49Z"},
+		{"basic", "20240616T133649Z", "2024-06-16T13:36:49Z"},
+		{"basic offset", "20240616T143649+0100", "2024-06-16T14:36:49+01:00"},
 		{"RFC822", "16 Jun 24 13:36 UTC", "2024-06-16T13:36:00Z"},
+		{"RFC1123Z", "Sun, 16 Jun 2024 14:36:49 +0100", "2024-06-16T14:36:49+01:00"},
 		{"nil", nil, ""},
 		{"bool", true, ""},
+		{"zero", 0, ""},
+		{"before GPS", "1970-01-02T00:00:00Z", ""},
+		{"far future", "2999-01-01T00:00:00Z", ""},
+		{"nanos", int64(1718545009123456789), ""},
+		{"garbage", "yesterday", ""},
 	}
 
 	for _, c := range cases {
@@ -27,7 +52,72 @@ func TestParseSloppyRecentTime(t *testing.T) {
 				return
 			}
 			assert.True(t, ok, "expected to succeed")
-			assert.Equal(t, c.expected, got.Format(time.RFC3339))
+			assert.Equal(t, c.expected, got.Format(time.RFC3339Nano))
 		})
 	}
 }
+
+func TestParseRecentTimeReasons(t *testing.T) {
+	_, err := ParseRecentTime(true)
+	assert.ErrorIs(t, err, ErrTimeUnsupportedType)
+
+	_, err = ParseRecentTime("yesterday")
+	assert.ErrorIs(t, err, ErrTimeInvalidFormat)
+
+	_, err = ParseRecentTime("Sun, 16 Jun 2024 14:36:49 XYZ")
+	assert.ErrorIs(t, err, ErrTimeAmbiguousZone)
+
+	_, err = ParseRecentTime(86400)
+	assert.ErrorIs(t, err, ErrTimeNotRecent)
+}
+
+func FuzzParseRecentTimeString(f *testing.F) {
+	for _, seed := range []string{
+		"2024-06-16T13:36:49Z",
+		"2024-06-16T14:36:49.123+01:00",
+		"20240616T133649Z",
+		"16 Jun 24 13:36 UTC",
+		"1718545009123",
+		"",
+		"NaN",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		got, err := ParseRecentTime(s)
+		if err != nil {
+			return
+		}
+		assert.False(t, got.Before(EarliestRecentTime), "%q parsed before earliest: %s", s, got)
+		assert.False(t, got.After(time.Now().Add(MaxFutureSkew)), "%q parsed in future: %s", s, got)
+	})
+}
+
+func FuzzParseRecentTimeRoundTrip(f *testing.F) {
+	f.Add(int64(1718545009123456))
+	f.Add(int64(315964800000000))
+	f.Fuzz(func(t *testing.T, micros int64) {
+		want := time.UnixMicro(micros).UTC()
+		if want.Before(EarliestRecentTime) || want.After(time.Now()) {
+			return
+		}
+
+		got, err := ParseRecentTime(want.Format(time.RFC3339Nano))
+		require.NoError(t, err)
+		assert.True(t, want.Equal(got), "RFC3339: want %s got %s", want, got)
+
+		got, err = ParseRecentTime(micros)
+		require.NoError(t, err)
+		assert.True(t, want.Equal(got), "micros: want %s got %s", want, got)
+
+		millis := want.Truncate(time.Millisecond)
+		got, err = ParseRecentTime(strconv.FormatInt(millis.UnixMilli(), 10))
+		require.NoError(t, err)
+		assert.True(t, millis.Equal(got), "millis: want %s got %s", millis, got)
+
+		secs := want.Truncate(time.Second)
+		got, err = ParseRecentTime(float64(secs.Unix()))
+		require.NoError(t, err)
+		assert.True(t, secs.Equal(got), "secs: want %s got %s", secs, got)
+	})
+}
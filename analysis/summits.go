@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"sort"
+	"time"
+)
+
+// MetersPerDegreeLat is the approximate length of a degree of latitude, for
+// padding bounding boxes by a distance.
+const MetersPerDegreeLat = 111_320.0
+
+const (
+	// A track must come this close to a peak to count as visiting it,
+	// allowing for GPS error and sparse recording
+	SummitMaxDistanceMeters = 75
+	// and, where both elevations are known, be no further than this below
+	// the summit. Elevation models smooth out summits so this is generous.
+	summitMaxBelowMeters = 40
+)
+
+// SummitCandidate is a peak that a track may have visited.
+type SummitCandidate struct {
+	ID              int64
+	Point           orb.Point
+	ElevationMeters *float64
+}
+
+// SummitVisit is a peak visited by a track.
+type SummitVisit struct {
+	ID int64
+	// Index is the point of the track closest to the summit
+	Index int
+	// Time is the time recorded at Index, if known
+	Time time.Time
+}
+
+// DetectSummits returns the candidates the track visited, in the order they
+// were first reached.
+func DetectSummits(f geojson.Feature, candidates []SummitCandidate) []SummitVisit {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok || len(line) == 0 {
+		return nil
+	}
+	coordProps := f.Properties.CoordinateProperties()
+	elevations, hasElevations := floatsFromCoordinateProperty(coordProps["elevationMeters"])
+	if len(elevations) != len(line) {
+		hasElevations = false
+	}
+	times, _ := coordProps["times"].([]interface{})
+
+	var visits []SummitVisit
+	for _, peak := range candidates {
+		closest := -1
+		closestDist := float64(SummitMaxDistanceMeters)
+		for i, pt := range line {
+			dist := geo.DistanceHaversine(pt, peak.Point)
+			if dist > closestDist {
+				continue
+			}
+			if hasElevations && peak.ElevationMeters != nil &&
+				elevations[i] < *peak.ElevationMeters-summitMaxBelowMeters {
+				continue
+			}
+			closest = i
+			closestDist = dist
+		}
+		if closest < 0 {
+			continue
+		}
+
+		visit := SummitVisit{ID: peak.ID, Index: closest}
+		if len(times) == len(line) {
+			visit.Time, _ = ParseSloppyRecentTime(times[closest])
+		}
+		visits = append(visits, visit)
+	}
+
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].Index < visits[j].Index
+	})
+	return visits
+}
@@ -0,0 +1,52 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDetectSummits(t *testing.T) {
+	f := *geojson.NewFeature(orb.LineString{
+		{-4.2372, 56.5370},
+		{-4.2300, 56.5410},
+		{-4.2214, 56.5448},
+	})
+	f.Properties.CoordinateProperties()["elevationMeters"] = []float64{1100, 1000, 1200}
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T11:00:00Z",
+		"2024-06-12T11:30:00Z",
+		"2024-06-12T12:00:00Z",
+	}
+
+	benLawers := 1214.0
+	beinnGhlas := 1103.0
+	meallCorranaich := 1069.0
+	got := DetectSummits(f, []SummitCandidate{
+		{ID: 1, Point: orb.Point{-4.2214, 56.5449}, ElevationMeters: &benLawers},
+		{ID: 2, Point: orb.Point{-4.2372, 56.5372}, ElevationMeters: &beinnGhlas},
+		// Passed nearby horizontally but far below
+		{ID: 3, Point: orb.Point{-4.2300, 56.5411}, ElevationMeters: &meallCorranaich},
+		// Not passed
+		{ID: 4, Point: orb.Point{-4.2700, 56.5500}},
+	})
+
+	require.Len(t, got, 2)
+	assert.Equal(t, int64(2), got[0].ID)
+	assert.Equal(t, 0, got[0].Index)
+	assert.Equal(t, time.Date(2024, 6, 12, 11, 0, 0, 0, time.UTC), got[0].Time)
+	assert.Equal(t, int64(1), got[1].ID)
+	assert.Equal(t, 2, got[1].Index)
+}
+
+func TestDetectSummitsWithoutElevations(t *testing.T) {
+	f := *geojson.NewFeature(orb.LineString{{-4.2214, 56.5448}, {-4.2, 56.52}})
+	peak := 1214.0
+
+	got := DetectSummits(f, []SummitCandidate{{ID: 1, Point: orb.Point{-4.2214, 56.5449}, ElevationMeters: &peak}})
+	require.Len(t, got, 1)
+	assert.True(t, got[0].Time.IsZero())
+}
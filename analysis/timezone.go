@@ -0,0 +1,34 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/ringsaturn/tzf"
+	"sync"
+)
+
+// The finder embeds a simplified copy of the timezone-boundary-builder
+// dataset, which takes a while to load, so it is only loaded when first used
+var timezoneFinder = sync.OnceValues(func() (tzf.F, error) {
+	return tzf.NewDefaultFinder()
+})
+
+// TrackTimezone resolves the IANA timezone at the start of the track. At sea
+// this is one of the Etc/GMT zones.
+func TrackTimezone(f geojson.Feature) (string, bool) {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok || len(line) == 0 {
+		return "", false
+	}
+	return PointTimezone(line[0])
+}
+
+// PointTimezone resolves the IANA timezone at the point.
+func PointTimezone(pt orb.Point) (string, bool) {
+	finder, err := timezoneFinder()
+	if err != nil {
+		return "", false
+	}
+	name := finder.GetTimezoneName(pt.Lon(), pt.Lat())
+	return name, name != ""
+}
@@ -0,0 +1,33 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTrackTimezone(t *testing.T) {
+	cases := []struct {
+		name     string
+		start    orb.Point
+		expected string
+	}{
+		{"scotland", orb.Point{-4.2214, 56.5449}, "Europe/London"},
+		{"alps", orb.Point{7.7491, 46.0207}, "Europe/Zurich"},
+		{"colorado", orb.Point{-105.6836, 40.2549}, "America/Denver"},
+		{"ocean", orb.Point{-30, 40}, "Etc/GMT+2"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := *geojson.NewFeature(orb.LineString{c.start, {0, 0}})
+			got, ok := TrackTimezone(f)
+			require.True(t, ok)
+			assert.Equal(t, c.expected, got)
+		})
+	}
+
+	_, ok := TrackTimezone(*geojson.NewFeature(orb.LineString{}))
+	assert.False(t, ok)
+}
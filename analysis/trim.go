@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"time"
+)
+
+const (
+	// Points within this distance of the first or last point are considered
+	// part of a stationary cluster, allowing for GPS jitter
+	idleRadiusMeters = 25
+	// Clusters shorter than this aren't worth trimming
+	minIdleSecs = 60
+)
+
+// TrimIdle removes the stationary periods at the start and end of a track,
+// such as standing around at the car park. It returns false if there is
+// nothing to trim.
+//
+// The stats of the trimmed track are recomputed.
+func TrimIdle(f geojson.Feature) (geojson.Feature, bool) {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok || len(line) < 3 {
+		return geojson.Feature{}, false
+	}
+
+	coordProps := f.Properties.CoordinateProperties()
+	rawTimes, ok := coordProps["times"].([]interface{})
+	if !ok || len(rawTimes) != len(line) {
+		return geojson.Feature{}, false
+	}
+	times := make([]*time.Time, len(rawTimes))
+	for i := range rawTimes {
+		if t, ok := ParseSloppyRecentTime(rawTimes[i]); ok {
+			times[i] = &t
+		}
+	}
+
+	start := idleClusterEnd(line, times, 0, 1)
+	end := idleClusterEnd(line, times, len(line)-1, -1)
+	if start == 0 && end == len(line)-1 {
+		return geojson.Feature{}, false
+	}
+	if end-start < 1 {
+		// The whole track is stationary
+		return geojson.Feature{}, false
+	}
+
+	trimmed, err := SliceTrack(f, start, end+1)
+	if err != nil {
+		return geojson.Feature{}, false
+	}
+	RecomputeStats(trimmed)
+	return trimmed, true
+}
+
+// idleClusterEnd walks from the anchor in the direction step while points stay
+// within idleRadiusMeters of it, returning the last such index if the cluster
+// lasted long enough to trim and the anchor otherwise.
+func idleClusterEnd(line orb.LineString, times []*time.Time, anchor int, step int) int {
+	last := anchor
+	for i := anchor + step; i >= 0 && i < len(line); i += step {
+		if geo.DistanceHaversine(line[anchor], line[i]) > idleRadiusMeters {
+			break
+		}
+		last = i
+	}
+	if last == anchor || times[anchor] == nil || times[last] == nil {
+		return anchor
+	}
+
+	idle := times[last].Sub(*times[anchor])
+	if idle < 0 {
+		idle = -idle
+	}
+	if idle < minIdleSecs*time.Second {
+		return anchor
+	}
+	return last
+}
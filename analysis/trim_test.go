@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func idleFeature() geojson.Feature {
+	f := geojson.NewFeature(orb.LineString{
+		// standing at the car park
+		{-4.0, 56.0},
+		{-4.00001, 56.00001},
+		{-4.00002, 56.0},
+		// walking
+		{-4.0, 56.001},
+		{-4.0, 56.002},
+		{-4.0, 56.003},
+		// standing at the end
+		{-4.00001, 56.00301},
+		{-4.0, 56.003},
+	})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:02:00Z",
+		"2024-06-12T09:04:00Z",
+		"2024-06-12T09:05:00Z",
+		"2024-06-12T09:06:00Z",
+		"2024-06-12T09:07:00Z",
+		"2024-06-12T09:10:00Z",
+		"2024-06-12T09:15:00Z",
+	}
+	f.Properties.CoordinateProperties()["elevationMeters"] = []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	RecomputeStats(*f)
+	return *f
+}
+
+func TestTrimIdle(t *testing.T) {
+	input := idleFeature()
+	require.Equal(t, 15*60, input.Properties["durationSecs"])
+
+	got, ok := TrimIdle(input)
+	require.True(t, ok)
+
+	require.Equal(t, orb.LineString{
+		{-4.00002, 56.0},
+		{-4.0, 56.001},
+		{-4.0, 56.002},
+		{-4.0, 56.003},
+	}, got.Geometry)
+
+	coordProps := got.Properties.CoordinateProperties()
+	assert.Equal(t, []interface{}{
+		"2024-06-12T09:04:00Z",
+		"2024-06-12T09:05:00Z",
+		"2024-06-12T09:06:00Z",
+		"2024-06-12T09:07:00Z",
+	}, coordProps["times"])
+	assert.Equal(t, []float64{3, 4, 5, 6}, coordProps["elevationMeters"])
+	assert.Equal(t, 3*60, got.Properties["durationSecs"])
+
+	// The input is left untouched
+	assert.Len(t, input.Geometry, 8)
+	assert.Equal(t, 15*60, input.Properties["durationSecs"])
+}
+
+func TestTrimIdleNothingToTrim(t *testing.T) {
+	_, ok := TrimIdle(sampleFeature())
+	assert.False(t, ok)
+}
+
+func TestTrimIdleWithoutTimes(t *testing.T) {
+	f := geojson.NewFeature(orb.LineString{{0, 0}, {0, 0}, {0, 0}, {1, 1}})
+	_, ok := TrimIdle(*f)
+	assert.False(t, ok)
+}
@@ -0,0 +1,22 @@
+// Package blobs stores files too large to keep in Postgres, like the
+// originals of imported tracks.
+package blobs
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+var ErrNotFound = fmt.Errorf("blob not found")
+var ErrInvalidKey = fmt.Errorf("invalid blob key")
+
+// validateKey accepts slash separated keys like track-imports/abc, so that
+// they map onto both filesystem paths and object names.
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") ||
+		path.Clean(key) != key || strings.HasPrefix(key, "..") || strings.Contains(key, "\\") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package blobs
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// testStore checks the behaviour every implementation shares.
+func testStore(t *testing.T, s store) {
+	ctx := context.Background()
+
+	_, err := s.Get(ctx, "test/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Put(ctx, "test/a", []byte("first")))
+	require.NoError(t, s.Put(ctx, "test/a", []byte("second")))
+	got, err := s.Get(ctx, "test/a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), got)
+
+	require.NoError(t, s.Put(ctx, "test/empty", nil))
+	got, err = s.Get(ctx, "test/empty")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	require.NoError(t, s.Delete(ctx, "test/a"))
+	_, err = s.Get(ctx, "test/a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, s.Delete(ctx, "test/a"))
+
+	assert.ErrorIs(t, s.Put(ctx, "../escape", []byte("x")), ErrInvalidKey)
+}
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+	testStore(t, s)
+}
+
+func TestValidateKey(t *testing.T) {
+	valid := []string{"a", "track-imports/abc", "a/b/c.gpx"}
+	for _, key := range valid {
+		assert.NoError(t, validateKey(key), key)
+	}
+
+	invalid := []string{"", "/a", "a/", "../a", "a/../../b", "a//b", "./a", "..", `a\b`}
+	for _, key := range invalid {
+		assert.ErrorIs(t, validateKey(key), ErrInvalidKey, key)
+	}
+}
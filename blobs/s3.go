@@ -0,0 +1,80 @@
+package blobs
+
+import (
+	"bytes"
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+)
+
+type S3Config struct {
+	// Endpoint is the host and optional port, like s3.eu-west-2.amazonaws.com
+	// or localhost:9000 for MinIO
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Insecure uses plain HTTP, for a local stand-in
+	Insecure bool
+}
+
+// S3 stores blobs as objects in a bucket of any S3 compatible service.
+type S3 struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
+		Secure: !config.Insecure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3{client: client, bucket: config.Bucket}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	defer obj.Close()
+	// The request is only made once the object is read
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	return data, nil
+}
+
+// Delete removes the blob. Deleting a blob that doesn't exist isn't an error.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func mapS3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
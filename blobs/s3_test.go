@@ -0,0 +1,35 @@
+package blobs
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// TestS3 runs against the MinIO from docker-compose.test.yaml.
+func TestS3(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION_TESTS") == "" {
+		t.Skip("--- Skipping integration tests as RUN_INTEGRATION_TESTS environment variable not set ---")
+	}
+
+	s, err := NewS3(S3Config{
+		Endpoint:        "localhost:9002",
+		Region:          "us-east-1",
+		Bucket:          "test",
+		AccessKeyID:     "minioadmin",
+		SecretAccessKey: "minioadmin",
+		Insecure:        true,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	require.NoError(t, err)
+	if !exists {
+		require.NoError(t, s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{}))
+	}
+
+	testStore(t, s)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/gazetteer"
+	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"os"
+)
+
+// runCommand runs an admin command instead of the server, for example
+//
+//	plantopo-api load-geonames GB.txt
+//	plantopo-api load-peaks munros munros.csv
+//	plantopo-api backfill-timezones
+//	plantopo-api backfill-estimates
+func runCommand(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	switch args[0] {
+	case "load-geonames":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: load-geonames <file>")
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, err := gazetteer.New(pool).LoadGeoNames(ctx, f)
+		if err != nil {
+			return fmt.Errorf("load geonames: %w", err)
+		}
+		slog.Info("loaded geonames", "places", n)
+		return nil
+	case "load-peaks":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: load-peaks <dataset> <file>")
+		}
+		f, err := os.Open(args[2])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, err := tracks.NewRepo(pool, nil, nil).LoadPeaks(ctx, args[1], args[2], f)
+		if err != nil {
+			return fmt.Errorf("load peaks: %w", err)
+		}
+		slog.Info("loaded peaks", "dataset", args[1], "peaks", n)
+		return nil
+	case "backfill-timezones":
+		n, err := tracks.NewRepo(pool, nil, nil).BackfillTimezones(ctx)
+		if err != nil {
+			return fmt.Errorf("backfill timezones: %w", err)
+		}
+		slog.Info("backfilled timezones", "tracks", n)
+		return nil
+	case "backfill-estimates":
+		n, err := tracks.NewRepo(pool, nil, nil).BackfillEstimates(ctx)
+		if err != nil {
+			return fmt.Errorf("backfill estimates: %w", err)
+		}
+		slog.Info("backfilled estimates", "tracks", n)
+		return nil
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
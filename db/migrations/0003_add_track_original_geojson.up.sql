@@ -0,0 +1,2 @@
+ALTER TABLE tracks
+    ADD COLUMN original_geojson JSONB;
@@ -0,0 +1 @@
+DROP TABLE privacy_zones;
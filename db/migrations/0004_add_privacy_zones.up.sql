@@ -0,0 +1,11 @@
+CREATE TABLE privacy_zones
+(
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       TEXT             NOT NULL,
+    name          TEXT             NOT NULL,
+    lng           DOUBLE PRECISION NOT NULL,
+    lat           DOUBLE PRECISION NOT NULL,
+    radius_meters DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX privacy_zones_user_id_idx ON privacy_zones (user_id);
@@ -0,0 +1,13 @@
+CREATE TABLE track_shares
+(
+    id         BIGSERIAL PRIMARY KEY,
+    token      TEXT                        NOT NULL,
+    track_id   BIGINT                      NOT NULL REFERENCES tracks (id) ON DELETE CASCADE,
+    owner_id   TEXT                        NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITHOUT TIME ZONE,
+    revoked_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE UNIQUE INDEX track_shares_token_idx ON track_shares (token);
+CREATE INDEX track_shares_owner_id_idx ON track_shares (owner_id);
@@ -0,0 +1,2 @@
+DROP TABLE collection_tracks;
+DROP TABLE collections;
@@ -0,0 +1,21 @@
+CREATE TABLE collections
+(
+    id             BIGSERIAL PRIMARY KEY,
+    owner_id       TEXT                        NOT NULL,
+    name           TEXT                        NOT NULL,
+    description    TEXT                        NOT NULL DEFAULT '',
+    cover_track_id BIGINT                      REFERENCES tracks (id) ON DELETE SET NULL,
+    created_at     TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX collections_owner_id_idx ON collections (owner_id);
+
+CREATE TABLE collection_tracks
+(
+    collection_id BIGINT  NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    track_id      BIGINT  NOT NULL REFERENCES tracks (id) ON DELETE CASCADE,
+    position      INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, track_id)
+);
+
+CREATE INDEX collection_tracks_track_id_idx ON collection_tracks (track_id);
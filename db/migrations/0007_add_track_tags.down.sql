@@ -0,0 +1 @@
+DROP TABLE track_tags;
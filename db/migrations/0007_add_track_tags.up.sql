@@ -0,0 +1,9 @@
+CREATE TABLE track_tags
+(
+    track_id BIGINT NOT NULL REFERENCES tracks (id) ON DELETE CASCADE,
+    owner_id TEXT   NOT NULL,
+    tag      TEXT   NOT NULL,
+    PRIMARY KEY (track_id, tag)
+);
+
+CREATE INDEX track_tags_owner_id_tag_idx ON track_tags (owner_id, tag);
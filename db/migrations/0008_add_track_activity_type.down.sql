@@ -0,0 +1,2 @@
+ALTER TABLE tracks
+    DROP COLUMN activity_type;
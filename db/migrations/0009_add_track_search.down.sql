@@ -0,0 +1,6 @@
+DROP TRIGGER track_tags_refresh_search ON track_tags;
+DROP FUNCTION track_tags_refresh_search();
+DROP TRIGGER tracks_refresh_search ON tracks;
+DROP FUNCTION tracks_refresh_search();
+DROP FUNCTION refresh_track_search(BIGINT);
+DROP TABLE track_search;
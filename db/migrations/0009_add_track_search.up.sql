@@ -0,0 +1,63 @@
+CREATE TABLE track_search
+(
+    track_id BIGINT PRIMARY KEY REFERENCES tracks (id) ON DELETE CASCADE,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX track_search_document_idx ON track_search USING GIN (document);
+
+-- Place names are set by analysis as properties of the track geojson
+CREATE FUNCTION refresh_track_search(tid BIGINT) RETURNS VOID AS
+$$
+INSERT INTO track_search (track_id, document)
+SELECT t.id,
+       setweight(to_tsvector('english', coalesce(t.name, '')), 'A') ||
+       setweight(to_tsvector('english', coalesce((SELECT string_agg(tt.tag, ' ')
+                                                  FROM track_tags tt
+                                                  WHERE tt.track_id = t.id), '')), 'B') ||
+       setweight(to_tsvector('english', concat_ws(' ',
+                                                  t.geojson -> 'properties' ->> 'startPlaceName',
+                                                  t.geojson -> 'properties' ->> 'endPlaceName',
+                                                  t.geojson -> 'properties' ->> 'summitPlaceName')), 'B') ||
+       setweight(to_tsvector('english', coalesce(t.geojson -> 'properties' ->> 'desc', '')), 'C') ||
+       setweight(to_tsvector('english', regexp_replace(coalesce(ti.filename, ''), '[_.-]', ' ', 'g')), 'C')
+FROM tracks t
+         LEFT JOIN track_imports ti ON ti.id = t.import_id
+WHERE t.id = tid
+ON CONFLICT (track_id) DO UPDATE SET document = excluded.document;
+$$ LANGUAGE sql;
+
+CREATE FUNCTION tracks_refresh_search() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM refresh_track_search(new.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tracks_refresh_search
+    AFTER INSERT OR UPDATE OF name, geojson, import_id
+    ON tracks
+    FOR EACH ROW
+EXECUTE FUNCTION tracks_refresh_search();
+
+CREATE FUNCTION track_tags_refresh_search() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF tg_op = 'DELETE' THEN
+        PERFORM refresh_track_search(old.track_id);
+    ELSE
+        PERFORM refresh_track_search(new.track_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER track_tags_refresh_search
+    AFTER INSERT OR DELETE
+    ON track_tags
+    FOR EACH ROW
+EXECUTE FUNCTION track_tags_refresh_search();
+
+SELECT refresh_track_search(id)
+FROM tracks;
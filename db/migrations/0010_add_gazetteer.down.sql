@@ -0,0 +1 @@
+DROP TABLE gazetteer_places;
@@ -0,0 +1,13 @@
+CREATE TABLE gazetteer_places
+(
+    id               BIGSERIAL PRIMARY KEY,
+    source           TEXT             NOT NULL,
+    name             TEXT             NOT NULL,
+    kind             TEXT             NOT NULL,
+    lng              DOUBLE PRECISION NOT NULL,
+    lat              DOUBLE PRECISION NOT NULL,
+    elevation_meters DOUBLE PRECISION
+);
+
+CREATE INDEX gazetteer_places_source_idx ON gazetteer_places (source);
+CREATE INDEX gazetteer_places_lat_lng_idx ON gazetteer_places (lat, lng);
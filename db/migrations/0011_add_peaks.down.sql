@@ -0,0 +1,2 @@
+DROP TABLE track_summits;
+DROP TABLE peaks;
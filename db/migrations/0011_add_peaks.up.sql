@@ -0,0 +1,22 @@
+CREATE TABLE peaks
+(
+    id               BIGSERIAL PRIMARY KEY,
+    dataset          TEXT             NOT NULL,
+    name             TEXT             NOT NULL,
+    lng              DOUBLE PRECISION NOT NULL,
+    lat              DOUBLE PRECISION NOT NULL,
+    elevation_meters DOUBLE PRECISION
+);
+
+CREATE INDEX peaks_dataset_idx ON peaks (dataset);
+CREATE INDEX peaks_lat_lng_idx ON peaks (lat, lng);
+
+CREATE TABLE track_summits
+(
+    track_id   BIGINT                      NOT NULL REFERENCES tracks (id) ON DELETE CASCADE,
+    peak_id    BIGINT                      NOT NULL REFERENCES peaks (id) ON DELETE CASCADE,
+    visited_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    PRIMARY KEY (track_id, peak_id)
+);
+
+CREATE INDEX track_summits_peak_id_idx ON track_summits (peak_id);
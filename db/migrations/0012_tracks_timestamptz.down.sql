@@ -0,0 +1,7 @@
+ALTER TABLE track_summits
+    ALTER COLUMN visited_at TYPE TIMESTAMP WITHOUT TIME ZONE USING visited_at AT TIME ZONE 'UTC';
+
+ALTER TABLE tracks
+    DROP COLUMN timezone,
+    ALTER COLUMN time TYPE TIMESTAMP WITHOUT TIME ZONE USING time AT TIME ZONE 'UTC',
+    ALTER COLUMN upload_time TYPE TIMESTAMP WITHOUT TIME ZONE USING upload_time AT TIME ZONE 'UTC';
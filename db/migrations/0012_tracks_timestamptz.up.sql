@@ -0,0 +1,8 @@
+-- Existing times were all stored in UTC
+ALTER TABLE tracks
+    ALTER COLUMN upload_time TYPE TIMESTAMPTZ USING upload_time AT TIME ZONE 'UTC',
+    ALTER COLUMN time TYPE TIMESTAMPTZ USING time AT TIME ZONE 'UTC',
+    ADD COLUMN timezone TEXT;
+
+ALTER TABLE track_summits
+    ALTER COLUMN visited_at TYPE TIMESTAMPTZ USING visited_at AT TIME ZONE 'UTC';
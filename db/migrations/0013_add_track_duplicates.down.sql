@@ -0,0 +1,4 @@
+DROP TABLE track_duplicates;
+
+ALTER TABLE track_imports
+    DROP COLUMN skip_duplicates;
@@ -0,0 +1,16 @@
+ALTER TABLE track_imports
+    ADD COLUMN skip_duplicates BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE track_duplicates
+(
+    track_id        BIGINT                      NOT NULL REFERENCES tracks (id) ON DELETE CASCADE,
+    duplicate_of_id BIGINT                      NOT NULL REFERENCES tracks (id) ON DELETE CASCADE,
+    owner_id        TEXT                        NOT NULL,
+    frechet_meters  DOUBLE PRECISION            NOT NULL,
+    detected_at     TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW(),
+    dismissed_at    TIMESTAMP WITHOUT TIME ZONE,
+    PRIMARY KEY (track_id, duplicate_of_id)
+);
+
+CREATE INDEX track_duplicates_owner_id_idx ON track_duplicates (owner_id);
+CREATE INDEX track_duplicates_duplicate_of_id_idx ON track_duplicates (duplicate_of_id);
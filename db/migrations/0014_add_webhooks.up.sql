@@ -0,0 +1,27 @@
+CREATE TABLE webhooks
+(
+    id         BIGSERIAL PRIMARY KEY,
+    owner_id   TEXT                        NOT NULL,
+    url        TEXT                        NOT NULL,
+    secret     TEXT                        NOT NULL,
+    events     TEXT[]                      NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhooks_owner_id_idx ON webhooks (owner_id);
+
+CREATE TABLE webhook_deliveries
+(
+    id           BIGSERIAL PRIMARY KEY,
+    webhook_id   BIGINT                      NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id     TEXT                        NOT NULL,
+    event        TEXT                        NOT NULL,
+    attempt      INT                         NOT NULL,
+    status_code  INT,
+    error        TEXT,
+    duration_ms  INT                         NOT NULL,
+    succeeded    BOOLEAN                     NOT NULL,
+    attempted_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, attempted_at);
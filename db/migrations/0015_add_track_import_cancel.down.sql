@@ -0,0 +1,3 @@
+ALTER TABLE track_imports
+    DROP COLUMN job_id,
+    DROP COLUMN cancelled_at;
@@ -0,0 +1,3 @@
+ALTER TABLE track_imports
+    ADD COLUMN cancelled_at TIMESTAMP WITHOUT TIME ZONE,
+    ADD COLUMN job_id       BIGINT;
@@ -0,0 +1,2 @@
+ALTER TABLE track_imports
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
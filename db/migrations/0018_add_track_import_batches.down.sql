@@ -0,0 +1,4 @@
+ALTER TABLE track_imports
+    DROP COLUMN batch_id;
+
+DROP TABLE track_import_batches;
@@ -0,0 +1,12 @@
+CREATE TABLE track_import_batches
+(
+    id          BIGSERIAL PRIMARY KEY,
+    owner_id    TEXT                        NOT NULL,
+    filename    TEXT                        NOT NULL,
+    inserted_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE track_imports
+    ADD COLUMN batch_id BIGINT REFERENCES track_import_batches (id) ON DELETE SET NULL;
+
+CREATE INDEX track_imports_batch_id_idx ON track_imports (batch_id);
@@ -0,0 +1,2 @@
+DROP TABLE track_upload_chunks;
+DROP TABLE track_uploads;
@@ -0,0 +1,20 @@
+CREATE TABLE track_uploads
+(
+    id         BIGSERIAL PRIMARY KEY,
+    owner_id   TEXT                        NOT NULL,
+    filename   TEXT                        NOT NULL,
+    size       BIGINT                      NOT NULL,
+    received   BIGINT                      NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE INDEX track_uploads_expires_at_idx ON track_uploads (expires_at);
+
+CREATE TABLE track_upload_chunks
+(
+    upload_id    BIGINT NOT NULL REFERENCES track_uploads (id) ON DELETE CASCADE,
+    start_offset BIGINT NOT NULL,
+    data         BYTEA  NOT NULL,
+    PRIMARY KEY (upload_id, start_offset)
+);
@@ -0,0 +1,11 @@
+-- Files that were moved to the blob store are not copied back
+UPDATE track_imports
+SET data = ''::bytea
+WHERE data IS NULL;
+
+DROP INDEX track_imports_unmoved_idx;
+
+ALTER TABLE track_imports
+    ALTER COLUMN data SET NOT NULL,
+    DROP COLUMN blob_key,
+    DROP COLUMN byte_size;
@@ -0,0 +1,19 @@
+-- The original files move to the blob store, leaving data set only on rows
+-- that haven't been moved yet
+ALTER TABLE track_imports
+    ADD COLUMN blob_key  TEXT,
+    ADD COLUMN byte_size BIGINT;
+
+UPDATE track_imports
+SET byte_size = length(data);
+
+-- Cancelled imports already discarded their data
+UPDATE track_imports
+SET data = NULL
+WHERE cancelled_at IS NOT NULL;
+
+ALTER TABLE track_imports
+    ALTER COLUMN byte_size SET NOT NULL,
+    ALTER COLUMN data DROP NOT NULL;
+
+CREATE INDEX track_imports_unmoved_idx ON track_imports (id) WHERE data IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE track_shares
+    ALTER COLUMN created_at TYPE TIMESTAMP WITHOUT TIME ZONE USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMP WITHOUT TIME ZONE USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN revoked_at TYPE TIMESTAMP WITHOUT TIME ZONE USING revoked_at AT TIME ZONE 'UTC';
@@ -0,0 +1,2 @@
+ALTER TABLE track_imports
+    DROP COLUMN skipped_duplicate_of_ids;
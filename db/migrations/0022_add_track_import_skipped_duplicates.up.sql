@@ -0,0 +1,2 @@
+ALTER TABLE track_imports
+    ADD COLUMN skipped_duplicate_of_ids BIGINT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+ALTER TABLE track_imports
+    DROP COLUMN source_url;
@@ -0,0 +1,2 @@
+ALTER TABLE track_imports
+    ADD COLUMN source_url TEXT;
@@ -0,0 +1,13 @@
+DROP INDEX track_uploads_owner_id_idx;
+
+ALTER TABLE track_uploads
+    ALTER COLUMN created_at TYPE TIMESTAMP WITHOUT TIME ZONE USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMP WITHOUT TIME ZONE USING expires_at AT TIME ZONE 'UTC';
+
+-- The chunks are in the blob store, which this can't read back
+DELETE
+FROM track_uploads;
+
+ALTER TABLE track_upload_chunks
+    DROP COLUMN size,
+    ADD COLUMN data BYTEA NOT NULL;
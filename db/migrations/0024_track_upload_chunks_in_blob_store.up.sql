@@ -0,0 +1,15 @@
+-- Uploads only last a day, so those in progress are discarded rather than
+-- moving their chunks to the blob store
+DELETE
+FROM track_uploads;
+
+ALTER TABLE track_upload_chunks
+    DROP COLUMN data,
+    ADD COLUMN size BIGINT NOT NULL;
+
+-- Existing times were all stored in UTC
+ALTER TABLE track_uploads
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC';
+
+CREATE INDEX track_uploads_owner_id_idx ON track_uploads (owner_id);
@@ -0,0 +1,5 @@
+DROP INDEX track_imports_content_hash_idx;
+
+ALTER TABLE track_imports
+    DROP COLUMN duplicate_of_id,
+    DROP COLUMN content_hash;
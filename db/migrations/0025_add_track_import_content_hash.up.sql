@@ -0,0 +1,12 @@
+-- The hash of an import from a URL isn't of its file, so duplicates are found
+-- by the hash of the file once it is downloaded. URL imports from before this
+-- aren't checked, as their files would have to be read back.
+ALTER TABLE track_imports
+    ADD COLUMN content_hash    BYTEA,
+    ADD COLUMN duplicate_of_id BIGINT REFERENCES track_imports (id) ON DELETE SET NULL;
+
+UPDATE track_imports
+SET content_hash = hash
+WHERE source_url IS NULL;
+
+CREATE UNIQUE INDEX track_imports_content_hash_idx ON track_imports (content_hash);
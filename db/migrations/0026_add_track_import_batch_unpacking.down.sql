@@ -0,0 +1,14 @@
+DROP INDEX track_import_batches_upload_id_idx;
+DROP INDEX track_import_batches_hash_idx;
+
+ALTER TABLE track_import_batches
+    DROP COLUMN duplicate_of_ids,
+    DROP COLUMN skipped,
+    DROP COLUMN error,
+    DROP COLUMN failed_at,
+    DROP COLUMN unpacked_at,
+    DROP COLUMN skip_duplicates,
+    DROP COLUMN byte_size,
+    DROP COLUMN blob_key,
+    DROP COLUMN upload_id,
+    DROP COLUMN hash;
@@ -0,0 +1,21 @@
+-- Archives are unpacked by a job, so a batch is created before its files are
+-- known. The archive is read from a blob, or from the upload it was sent in,
+-- which is deleted once the batch is unpacked and so isn't a foreign key.
+ALTER TABLE track_import_batches
+    ADD COLUMN hash             BYTEA,
+    ADD COLUMN upload_id        BIGINT,
+    ADD COLUMN blob_key         TEXT,
+    ADD COLUMN byte_size        BIGINT      NOT NULL DEFAULT 0,
+    ADD COLUMN skip_duplicates  BOOLEAN     NOT NULL DEFAULT FALSE,
+    ADD COLUMN unpacked_at      TIMESTAMPTZ,
+    ADD COLUMN failed_at        TIMESTAMPTZ,
+    ADD COLUMN error            TEXT,
+    ADD COLUMN skipped          JSONB,
+    ADD COLUMN duplicate_of_ids BIGINT[];
+
+-- Existing batches were unpacked when they were created, in UTC
+UPDATE track_import_batches
+SET unpacked_at = inserted_at AT TIME ZONE 'UTC';
+
+CREATE UNIQUE INDEX track_import_batches_hash_idx ON track_import_batches (hash);
+CREATE UNIQUE INDEX track_import_batches_upload_id_idx ON track_import_batches (upload_id);
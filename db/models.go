@@ -11,29 +11,178 @@ import (
 	"github.com/paulmach/orb/geojson"
 )
 
+type Collection struct {
+	ID           int64            `json:"id"`
+	OwnerID      string           `json:"ownerID"`
+	Name         string           `json:"name"`
+	Description  string           `json:"description"`
+	CoverTrackID *int64           `json:"coverTrackID"`
+	CreatedAt    pgtype.Timestamp `json:"createdAt"`
+}
+
+type CollectionTrack struct {
+	CollectionID int64 `json:"collectionID"`
+	TrackID      int64 `json:"trackID"`
+	Position     int32 `json:"position"`
+}
+
+type GazetteerPlace struct {
+	ID              int64    `json:"id"`
+	Source          string   `json:"source"`
+	Name            string   `json:"name"`
+	Kind            string   `json:"kind"`
+	Lng             float64  `json:"lng"`
+	Lat             float64  `json:"lat"`
+	ElevationMeters *float64 `json:"elevationMeters"`
+}
+
+type Peak struct {
+	ID              int64    `json:"id"`
+	Dataset         string   `json:"dataset"`
+	Name            string   `json:"name"`
+	Lng             float64  `json:"lng"`
+	Lat             float64  `json:"lat"`
+	ElevationMeters *float64 `json:"elevationMeters"`
+}
+
+type PrivacyZone struct {
+	ID           int64   `json:"id"`
+	UserID       string  `json:"userID"`
+	Name         string  `json:"name"`
+	Lng          float64 `json:"lng"`
+	Lat          float64 `json:"lat"`
+	RadiusMeters float64 `json:"radiusMeters"`
+}
+
 type Track struct {
-	ID         int64            `json:"id"`
-	OwnerID    *string          `json:"ownerID"`
-	Name       *string          `json:"name"`
-	UploadTime pgtype.Timestamp `json:"uploadTime"`
-	Time       pgtype.Timestamp `json:"time"`
-	Geojson    geojson.Feature  `json:"geojson"`
-	ImportID   *int64           `json:"importID"`
+	ID              int64              `json:"id"`
+	OwnerID         *string            `json:"ownerID"`
+	Name            *string            `json:"name"`
+	UploadTime      pgtype.Timestamptz `json:"uploadTime"`
+	Time            pgtype.Timestamptz `json:"time"`
+	Geojson         geojson.Feature    `json:"geojson"`
+	ImportID        *int64             `json:"importID"`
+	OriginalGeojson *geojson.Feature   `json:"originalGeojson"`
+	ActivityType    *string            `json:"activityType"`
+	Timezone        *string            `json:"timezone"`
+}
+
+type TrackDuplicate struct {
+	TrackID       int64            `json:"trackID"`
+	DuplicateOfID int64            `json:"duplicateOfID"`
+	OwnerID       string           `json:"ownerID"`
+	FrechetMeters float64          `json:"frechetMeters"`
+	DetectedAt    pgtype.Timestamp `json:"detectedAt"`
+	DismissedAt   pgtype.Timestamp `json:"dismissedAt"`
 }
 
 type TrackImport struct {
-	ID          int64            `json:"id"`
-	OwnerID     string           `json:"ownerID"`
-	Hash        []byte           `json:"hash"`
-	InsertedAt  pgtype.Timestamp `json:"insertedAt"`
-	CompletedAt pgtype.Timestamp `json:"completedAt"`
-	FailedAt    pgtype.Timestamp `json:"failedAt"`
-	Error       *string          `json:"error"`
-	Filename    string           `json:"filename"`
-	Data        []byte           `json:"data"`
+	ID                    int64            `json:"id"`
+	OwnerID               string           `json:"ownerID"`
+	Hash                  []byte           `json:"hash"`
+	InsertedAt            pgtype.Timestamp `json:"insertedAt"`
+	CompletedAt           pgtype.Timestamp `json:"completedAt"`
+	FailedAt              pgtype.Timestamp `json:"failedAt"`
+	Error                 *string          `json:"error"`
+	Filename              string           `json:"filename"`
+	Data                  []byte           `json:"data"`
+	SkipDuplicates        bool             `json:"skipDuplicates"`
+	CancelledAt           pgtype.Timestamp `json:"cancelledAt"`
+	JobID                 *int64           `json:"jobID"`
+	Attempts              int32            `json:"attempts"`
+	Checkpoint            json.RawMessage  `json:"checkpoint"`
+	BatchID               *int64           `json:"batchID"`
+	BlobKey               *string          `json:"blobKey"`
+	ByteSize              int64            `json:"byteSize"`
+	SkippedDuplicateOfIds []int64          `json:"skippedDuplicateOfIds"`
+	SourceUrl             *string          `json:"sourceUrl"`
+	ContentHash           []byte           `json:"contentHash"`
+	DuplicateOfID         *int64           `json:"duplicateOfID"`
+}
+
+type TrackImportBatch struct {
+	ID             int64              `json:"id"`
+	OwnerID        string             `json:"ownerID"`
+	Filename       string             `json:"filename"`
+	InsertedAt     pgtype.Timestamp   `json:"insertedAt"`
+	Hash           []byte             `json:"hash"`
+	UploadID       *int64             `json:"uploadID"`
+	BlobKey        *string            `json:"blobKey"`
+	ByteSize       int64              `json:"byteSize"`
+	SkipDuplicates bool               `json:"skipDuplicates"`
+	UnpackedAt     pgtype.Timestamptz `json:"unpackedAt"`
+	FailedAt       pgtype.Timestamptz `json:"failedAt"`
+	Error          *string            `json:"error"`
+	Skipped        json.RawMessage    `json:"skipped"`
+	DuplicateOfIds []int64            `json:"duplicateOfIds"`
+}
+
+type TrackSearch struct {
+	TrackID  int64       `json:"trackID"`
+	Document interface{} `json:"document"`
+}
+
+type TrackShare struct {
+	ID        int64              `json:"id"`
+	Token     string             `json:"token"`
+	TrackID   int64              `json:"trackID"`
+	OwnerID   string             `json:"ownerID"`
+	CreatedAt pgtype.Timestamptz `json:"createdAt"`
+	ExpiresAt pgtype.Timestamptz `json:"expiresAt"`
+	RevokedAt pgtype.Timestamptz `json:"revokedAt"`
+}
+
+type TrackSummit struct {
+	TrackID   int64              `json:"trackID"`
+	PeakID    int64              `json:"peakID"`
+	VisitedAt pgtype.Timestamptz `json:"visitedAt"`
+}
+
+type TrackTag struct {
+	TrackID int64  `json:"trackID"`
+	OwnerID string `json:"ownerID"`
+	Tag     string `json:"tag"`
+}
+
+type TrackUpload struct {
+	ID        int64              `json:"id"`
+	OwnerID   string             `json:"ownerID"`
+	Filename  string             `json:"filename"`
+	Size      int64              `json:"size"`
+	Received  int64              `json:"received"`
+	CreatedAt pgtype.Timestamptz `json:"createdAt"`
+	ExpiresAt pgtype.Timestamptz `json:"expiresAt"`
+}
+
+type TrackUploadChunk struct {
+	UploadID    int64 `json:"uploadID"`
+	StartOffset int64 `json:"startOffset"`
+	Size        int64 `json:"size"`
 }
 
 type UnitSetting struct {
 	UserID string          `json:"userID"`
 	Value  json.RawMessage `json:"value"`
 }
+
+type Webhook struct {
+	ID        int64            `json:"id"`
+	OwnerID   string           `json:"ownerID"`
+	Url       string           `json:"url"`
+	Secret    string           `json:"secret"`
+	Events    []string         `json:"events"`
+	CreatedAt pgtype.Timestamp `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	ID          int64            `json:"id"`
+	WebhookID   int64            `json:"webhookID"`
+	EventID     string           `json:"eventID"`
+	Event       string           `json:"event"`
+	Attempt     int32            `json:"attempt"`
+	StatusCode  *int32           `json:"statusCode"`
+	Error       *string          `json:"error"`
+	DurationMs  int32            `json:"durationMs"`
+	Succeeded   bool             `json:"succeeded"`
+	AttemptedAt pgtype.Timestamp `json:"attemptedAt"`
+}
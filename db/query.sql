@@ -18,11 +18,45 @@ SELECT import_id
 FROM tracks
 WHERE id = $1;
 
--- name: ListTracksOrderByTime :many
-SELECT *
+-- name: ListTrackMovingTimeSamples :many
+SELECT (geojson -> 'properties' ->> 'movingSecs')::float8   AS moving_secs,
+       (geojson -> 'properties' ->> 'naismithSecs')::float8 AS naismith_secs,
+       (geojson -> 'properties' ->> 'toblerSecs')::float8   AS tobler_secs
 FROM tracks
 WHERE owner_id = $1
-ORDER BY time DESC;
+  AND (activity_type IS NULL OR activity_type = 'hike')
+  AND geojson -> 'properties' ->> 'movingSecs' IS NOT NULL
+  AND geojson -> 'properties' ->> 'naismithSecs' IS NOT NULL
+  AND geojson -> 'properties' ->> 'toblerSecs' IS NOT NULL
+ORDER BY time DESC
+LIMIT 200;
+
+-- name: ListTracks :many
+SELECT t.*
+FROM tracks t
+         LEFT JOIN collection_tracks ct
+                   ON ct.track_id = t.id AND ct.collection_id = sqlc.narg('collection_id')
+WHERE t.owner_id = @owner_id
+  AND (sqlc.narg('collection_id')::bigint IS NULL OR ct.collection_id IS NOT NULL)
+  AND (cardinality(@tags::text[]) = 0 OR
+       CASE
+           WHEN @match_all_tags::bool THEN
+               (SELECT COUNT(*) FROM track_tags tt WHERE tt.track_id = t.id AND tt.tag = ANY (@tags::text[])) =
+               cardinality(@tags::text[])
+           ELSE
+               EXISTS(SELECT 1 FROM track_tags tt WHERE tt.track_id = t.id AND tt.tag = ANY (@tags::text[]))
+           END)
+ORDER BY CASE WHEN @order_by_position::bool THEN ct.position END, t.time DESC;
+
+-- name: SearchTracks :many
+SELECT sqlc.embed(t), ts_rank_cd(s.document, query)::real AS rank
+FROM tracks t
+         JOIN track_search s ON s.track_id = t.id,
+     websearch_to_tsquery('english', @query::text) query
+WHERE t.owner_id = @owner_id
+  AND s.document @@ query
+ORDER BY rank DESC, t.time DESC
+LIMIT @page_limit OFFSET @page_offset;
 
 -- name: HasImportedTrack :one
 SELECT EXISTS(
@@ -38,21 +72,45 @@ SELECT EXISTS(
 DELETE FROM track_imports
 WHERE id = $1;
 
+-- name: DeleteTrackImportIfUnused :one
+DELETE FROM track_imports ti
+WHERE ti.id = $1
+  AND NOT EXISTS(SELECT 1 FROM tracks t WHERE t.import_id = ti.id)
+RETURNING blob_key;
+
 -- name: InsertTrackImport :one
-INSERT INTO track_imports (owner_id, filename, data, hash)
-VALUES ($1, $2, $3, $4)
+INSERT INTO track_imports (owner_id, filename, blob_key, byte_size, hash, skip_duplicates, batch_id, source_url,
+                           content_hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING id;
 
--- name: MarkTrackImportCompleted :exec
+-- name: SetTrackImportDownloaded :execrows
+UPDATE track_imports
+SET filename     = $2,
+    blob_key     = $3,
+    byte_size    = $4,
+    content_hash = $5
+WHERE id = $1
+  AND cancelled_at IS NULL;
+
+-- name: SetTrackImportDuplicateOf :exec
 UPDATE track_imports
-SET completed_at = NOW()
+SET duplicate_of_id = $2
 WHERE id = $1;
 
--- name: MarkTrackImportFailed :exec
+-- name: MarkTrackImportCompleted :execrows
+UPDATE track_imports
+SET completed_at = NOW(),
+    checkpoint   = NULL
+WHERE id = $1
+  AND cancelled_at IS NULL;
+
+-- name: MarkTrackImportFailed :execrows
 UPDATE track_imports
 SET failed_at = NOW(),
     error     = $2
-WHERE id = $1;
+WHERE id = $1
+  AND cancelled_at IS NULL;
 
 -- name: GetTrackImport :one
 SELECT *
@@ -67,32 +125,88 @@ SELECT hash,
        failed_at,
        error,
        filename,
-       length(data) as byte_size
+       byte_size,
+       cancelled_at,
+       attempts,
+       batch_id,
+       skipped_duplicate_of_ids
 FROM track_imports
 WHERE owner_id = $1
-  AND (completed_at IS NULL OR
+  AND ((completed_at IS NULL AND cancelled_at IS NULL) OR
        completed_at > NOW() - INTERVAL '1 DAY' OR
-       failed_at > NOW() - INTERVAL '1 DAY')
+       failed_at > NOW() - INTERVAL '1 DAY' OR
+       cancelled_at > NOW() - INTERVAL '1 DAY')
 ORDER BY inserted_at DESC;
 
--- name: GetTrackImportStatus :one
-SELECT hash,
-       owner_id,
-       inserted_at,
-       completed_at,
-       failed_at,
-       error,
-       filename,
-       length(data) as byte_size
+-- name: GetTrackImportIDByHash :one
+SELECT id
 FROM track_imports
-WHERE id = $1;
+WHERE hash = $1;
+
+-- name: GetTrackImportIDByContentHash :one
+SELECT id
+FROM track_imports
+WHERE content_hash = $1;
+
+-- name: ListImportTrackIDs :many
+SELECT id
+FROM tracks
+WHERE import_id = $1
+ORDER BY id;
+
+-- name: GetTrackImportStatus :one
+SELECT ti.hash,
+       ti.owner_id,
+       ti.inserted_at,
+       ti.completed_at,
+       ti.failed_at,
+       ti.error,
+       ti.filename,
+       ti.byte_size,
+       ti.cancelled_at,
+       ti.attempts,
+       ti.batch_id,
+       ti.skipped_duplicate_of_ids,
+       d.hash AS duplicate_of_hash
+FROM track_imports ti
+         LEFT JOIN track_imports d ON d.id = ti.duplicate_of_id
+WHERE ti.id = $1;
 
 -- name: InsertImportedTrack :one
 INSERT INTO tracks
-    (owner_id, name, upload_time, time, geojson, import_id)
-VALUES ($1, $2, $3, $4, $5, $6)
+    (owner_id, name, upload_time, time, geojson, import_id, original_geojson, activity_type, timezone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING id;
 
+-- name: TrimTrack :exec
+UPDATE tracks
+SET original_geojson = COALESCE(original_geojson, geojson),
+    geojson          = $2,
+    time             = $3
+WHERE id = $1;
+
+-- name: UpdateTrackGeojson :exec
+UPDATE tracks
+SET geojson          = $2,
+    time             = $3,
+    original_geojson = $4
+WHERE id = $1;
+
+-- name: UpdateTrackActivityType :exec
+UPDATE tracks
+SET activity_type    = $2,
+    geojson          = $3,
+    original_geojson = $4
+WHERE id = $1;
+
+-- name: RestoreTrackOriginal :exec
+UPDATE tracks
+SET geojson          = original_geojson,
+    time             = $2,
+    original_geojson = NULL
+WHERE id = $1
+  AND original_geojson IS NOT NULL;
+
 
 -- name: GetUnitSettings :one
 SELECT value
@@ -104,3 +218,571 @@ INSERT INTO unit_settings (user_id, value)
 VALUES ($1, $2)
 ON CONFLICT (user_id) DO UPDATE
 SET value = $2;
+
+-- name: ListPrivacyZones :many
+SELECT *
+FROM privacy_zones
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: InsertPrivacyZone :one
+INSERT INTO privacy_zones (user_id, name, lng, lat, radius_meters)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: DeletePrivacyZone :execrows
+DELETE
+FROM privacy_zones
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: InsertTrackShare :one
+INSERT INTO track_shares (token, track_id, owner_id, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetActiveTrackShare :one
+SELECT *
+FROM track_shares
+WHERE token = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: ListMyActiveTrackShares :many
+SELECT *
+FROM track_shares
+WHERE owner_id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC;
+
+-- name: RevokeTrackShare :execrows
+UPDATE track_shares
+SET revoked_at = NOW()
+WHERE token = $1
+  AND owner_id = $2
+  AND revoked_at IS NULL;
+
+-- name: ListCollections :many
+SELECT sqlc.embed(c),
+       COALESCE(array_agg(ct.track_id ORDER BY ct.position, ct.track_id)
+                FILTER (WHERE ct.track_id IS NOT NULL), '{}')::bigint[] AS track_ids
+FROM collections c
+         LEFT JOIN collection_tracks ct ON ct.collection_id = c.id
+WHERE c.owner_id = $1
+GROUP BY c.id
+ORDER BY c.name;
+
+-- name: GetCollection :one
+SELECT *
+FROM collections
+WHERE id = $1;
+
+-- name: GetCollectionOwner :one
+SELECT owner_id
+FROM collections
+WHERE id = $1;
+
+-- name: InsertCollection :one
+INSERT INTO collections (owner_id, name, description)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: UpdateCollection :one
+UPDATE collections
+SET name           = $2,
+    description    = $3,
+    cover_track_id = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteCollection :exec
+DELETE
+FROM collections
+WHERE id = $1;
+
+-- name: LockCollection :exec
+SELECT id
+FROM collections
+WHERE id = $1
+    FOR UPDATE;
+
+-- name: ListCollectionTrackIDs :many
+SELECT track_id
+FROM collection_tracks
+WHERE collection_id = $1
+ORDER BY position, track_id;
+
+-- name: AddCollectionTrack :exec
+INSERT INTO collection_tracks (collection_id, track_id, position)
+SELECT $1, $2, COALESCE(MAX(position) + 1, 0)
+FROM collection_tracks
+WHERE collection_id = $1
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveCollectionTrack :execrows
+DELETE
+FROM collection_tracks
+WHERE collection_id = $1
+  AND track_id = $2;
+
+-- name: SetCollectionTrackPosition :exec
+UPDATE collection_tracks
+SET position = $3
+WHERE collection_id = $1
+  AND track_id = $2;
+
+-- name: MakeRoomAfterCollectionTrack :exec
+UPDATE collection_tracks ct
+SET position = ct.position + 1
+FROM collection_tracks src
+WHERE src.track_id = $1
+  AND ct.collection_id = src.collection_id
+  AND ct.position > src.position;
+
+-- name: InsertCollectionTrackAfter :exec
+INSERT INTO collection_tracks (collection_id, track_id, position)
+SELECT src.collection_id, @new_track_id::bigint, src.position + 1
+FROM collection_tracks src
+WHERE src.track_id = @track_id
+ON CONFLICT DO NOTHING;
+
+-- name: CopyCollectionMemberships :exec
+INSERT INTO collection_tracks (collection_id, track_id, position)
+SELECT collection_id, @merged_track_id::bigint, MIN(position)
+FROM collection_tracks
+WHERE track_id = ANY (@source_track_ids::bigint[])
+GROUP BY collection_id
+ON CONFLICT DO NOTHING;
+
+-- name: AddTrackTag :exec
+INSERT INTO track_tags (track_id, owner_id, tag)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveTrackTag :execrows
+DELETE
+FROM track_tags
+WHERE track_id = $1
+  AND tag = $2;
+
+-- name: ListTagsForTracks :many
+SELECT track_id, tag
+FROM track_tags
+WHERE track_id = ANY (@track_ids::bigint[])
+ORDER BY track_id, tag;
+
+-- name: ListMyTagCounts :many
+SELECT tag, COUNT(*) AS count
+FROM track_tags
+WHERE owner_id = $1
+GROUP BY tag
+ORDER BY tag;
+
+-- name: CopyTag :exec
+INSERT INTO track_tags (track_id, owner_id, tag)
+SELECT t.track_id, t.owner_id, @to_tag::text
+FROM track_tags t
+WHERE t.owner_id = @owner_id
+  AND t.tag = @from_tag::text
+ON CONFLICT DO NOTHING;
+
+-- name: CopyTrackTags :exec
+INSERT INTO track_tags (track_id, owner_id, tag)
+SELECT DISTINCT @to_track_id::bigint, t.owner_id, t.tag
+FROM track_tags t
+WHERE t.track_id = ANY (@from_track_ids::bigint[])
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteTag :execrows
+DELETE
+FROM track_tags
+WHERE owner_id = $1
+  AND tag = $2;
+
+-- name: InsertGazetteerPlaces :copyfrom
+INSERT INTO gazetteer_places (source, name, kind, lng, lat, elevation_meters)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: DeleteGazetteerSource :exec
+DELETE
+FROM gazetteer_places
+WHERE source = $1;
+
+-- name: NearestGazetteerPlace :one
+SELECT name, kind, lng, lat
+FROM gazetteer_places
+WHERE lat BETWEEN @min_lat::float8 AND @max_lat::float8
+  AND lng BETWEEN @min_lng::float8 AND @max_lng::float8
+  AND (sqlc.narg('kind')::text IS NULL OR kind = sqlc.narg('kind'))
+ORDER BY ((lng - @lng::float8) * cos(radians(@lat::float8))) ^ 2 + (lat - @lat::float8) ^ 2
+LIMIT 1;
+
+-- name: InsertPeaks :copyfrom
+INSERT INTO peaks (dataset, name, lng, lat, elevation_meters)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: DeletePeakDataset :exec
+DELETE
+FROM peaks
+WHERE dataset = $1;
+
+-- name: ListPeaksInBounds :many
+SELECT *
+FROM peaks
+WHERE lat BETWEEN @min_lat::float8 AND @max_lat::float8
+  AND lng BETWEEN @min_lng::float8 AND @max_lng::float8;
+
+-- name: ListTrackIDsAfter :many
+SELECT id
+FROM tracks
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: DeleteTrackSummits :exec
+DELETE
+FROM track_summits
+WHERE track_id = $1;
+
+-- name: InsertTrackSummit :exec
+INSERT INTO track_summits (track_id, peak_id, visited_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (track_id, peak_id) DO UPDATE SET visited_at = LEAST(track_summits.visited_at, excluded.visited_at);
+
+-- name: ListTrackSummits :many
+SELECT sqlc.embed(p), ts.visited_at
+FROM track_summits ts
+         JOIN peaks p ON p.id = ts.peak_id
+WHERE ts.track_id = $1
+ORDER BY ts.visited_at, p.name;
+
+-- name: ListBaggedPeaks :many
+SELECT sqlc.embed(p),
+       MIN(ts.visited_at)::timestamptz AS first_visited_at,
+       MAX(ts.visited_at)::timestamptz AS latest_visited_at,
+       COUNT(DISTINCT ts.track_id)   AS visits
+FROM track_summits ts
+         JOIN tracks t ON t.id = ts.track_id
+         JOIN peaks p ON p.id = ts.peak_id
+WHERE t.owner_id = @owner_id
+  AND (sqlc.narg('dataset')::text IS NULL OR p.dataset = sqlc.narg('dataset'))
+GROUP BY p.id
+ORDER BY first_visited_at, p.name;
+
+-- name: ListTracksMissingTimezone :many
+SELECT id, geojson
+FROM tracks
+WHERE timezone IS NULL
+  AND id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: ListTracksMissingEstimates :many
+SELECT id, geojson
+FROM tracks
+WHERE (activity_type IS NULL OR activity_type IN ('hike', 'run'))
+  AND geojson -> 'properties' -> 'naismithSecs' IS NULL
+  AND geojson -> 'properties' -> 'coordinateProperties' -> 'elevationMeters' IS NOT NULL
+  AND id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: SetTrackGeojson :exec
+UPDATE tracks
+SET geojson = $2
+WHERE id = $1;
+
+-- name: SetTrackTimezone :exec
+UPDATE tracks
+SET timezone = $2
+WHERE id = $1;
+
+-- name: ListDuplicateCandidates :many
+SELECT *
+FROM tracks
+WHERE owner_id = @owner_id
+  AND (sqlc.narg('min_time')::timestamptz IS NULL OR
+       time IS NULL OR
+       time = upload_time OR
+       time BETWEEN sqlc.narg('min_time') AND sqlc.narg('max_time'))
+  AND (geojson -> 'geometry' -> 'coordinates' -> 0 ->> 1)::double precision BETWEEN @min_lat::float8 AND @max_lat::float8
+  AND (geojson -> 'geometry' -> 'coordinates' -> 0 ->> 0)::double precision BETWEEN @min_lng::float8 AND @max_lng::float8
+ORDER BY id DESC
+LIMIT 50;
+
+-- name: InsertTrackDuplicate :exec
+INSERT INTO track_duplicates (track_id, duplicate_of_id, owner_id, frechet_meters)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: ListMyTrackDuplicates :many
+SELECT d.track_id,
+       d.duplicate_of_id,
+       d.frechet_meters,
+       d.detected_at,
+       t.name AS track_name,
+       o.name AS duplicate_of_name
+FROM track_duplicates d
+         JOIN tracks t ON t.id = d.track_id
+         JOIN tracks o ON o.id = d.duplicate_of_id
+WHERE d.owner_id = $1
+  AND d.dismissed_at IS NULL
+ORDER BY d.detected_at DESC;
+
+-- name: DismissTrackDuplicate :execrows
+UPDATE track_duplicates
+SET dismissed_at = NOW()
+WHERE track_id = $1
+  AND duplicate_of_id = $2
+  AND owner_id = $3
+  AND dismissed_at IS NULL;
+
+-- name: NotifyTrackImportEvent :exec
+SELECT pg_notify('track_import_events', @payload::text);
+
+-- name: InsertWebhook :one
+INSERT INTO webhooks (owner_id, url, secret, events)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT *
+FROM webhooks
+WHERE id = $1;
+
+-- name: ListWebhooks :many
+SELECT *
+FROM webhooks
+WHERE owner_id = $1
+ORDER BY created_at;
+
+-- name: ListWebhooksForEvent :many
+SELECT *
+FROM webhooks
+WHERE owner_id = @owner_id
+  AND @event::text = ANY (events);
+
+-- name: DeleteWebhook :execrows
+DELETE
+FROM webhooks
+WHERE id = $1
+  AND owner_id = $2;
+
+-- name: InsertWebhookDelivery :exec
+INSERT INTO webhook_deliveries (webhook_id, event_id, event, attempt, status_code, error, duration_ms, succeeded)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY attempted_at DESC
+LIMIT 100;
+
+-- name: SetTrackImportJob :exec
+UPDATE track_imports
+SET job_id = $2
+WHERE id = $1;
+
+-- name: SetTrackImportCheckpoint :exec
+UPDATE track_imports
+SET checkpoint = $2
+WHERE id = $1;
+
+-- name: SetTrackImportSkippedDuplicates :exec
+UPDATE track_imports
+SET skipped_duplicate_of_ids = $2
+WHERE id = $1;
+
+-- name: SetTrackImportAttempts :exec
+UPDATE track_imports
+SET attempts = $2
+WHERE id = $1;
+
+-- name: ResetTrackImportFailure :execrows
+UPDATE track_imports
+SET failed_at = NULL,
+    error     = NULL,
+    attempts  = 0
+WHERE id = $1
+  AND failed_at IS NOT NULL
+  AND cancelled_at IS NULL;
+
+-- name: CancelTrackImport :one
+UPDATE track_imports ti
+SET cancelled_at = NOW(),
+    data         = NULL,
+    blob_key     = NULL,
+    checkpoint   = NULL
+FROM track_imports old
+WHERE ti.id = $1
+  AND old.id = ti.id
+  AND ti.completed_at IS NULL
+  AND ti.failed_at IS NULL
+  AND ti.cancelled_at IS NULL
+RETURNING ti.job_id, old.blob_key;
+
+-- name: ListUnmovedTrackImports :many
+SELECT id, hash, data
+FROM track_imports
+WHERE data IS NOT NULL
+ORDER BY id
+LIMIT $1;
+
+-- name: SetTrackImportBlobKey :execrows
+UPDATE track_imports
+SET blob_key = $2,
+    data     = NULL
+WHERE id = $1
+  AND data IS NOT NULL;
+
+-- name: DeleteCancelledTrackImport :exec
+DELETE
+FROM track_imports
+WHERE (hash = $1 OR content_hash = $1)
+  AND cancelled_at IS NOT NULL;
+
+-- name: InsertTrackImportBatch :one
+INSERT INTO track_import_batches (owner_id, filename, hash, upload_id, blob_key, byte_size, skip_duplicates)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id;
+
+-- name: GetTrackImportBatch :one
+SELECT *
+FROM track_import_batches
+WHERE id = $1;
+
+-- name: GetTrackImportBatchByHashForUpdate :one
+SELECT *
+FROM track_import_batches
+WHERE hash = $1
+    FOR UPDATE;
+
+-- name: GetTrackImportBatchByUploadForUpdate :one
+SELECT *
+FROM track_import_batches
+WHERE upload_id = $1
+    FOR UPDATE;
+
+-- name: SetTrackImportBatchUnpacked :execrows
+UPDATE track_import_batches
+SET unpacked_at      = NOW(),
+    skipped          = $2,
+    duplicate_of_ids = $3
+WHERE id = $1
+  AND unpacked_at IS NULL
+  AND failed_at IS NULL;
+
+-- name: MarkTrackImportBatchFailed :execrows
+UPDATE track_import_batches
+SET failed_at = NOW(),
+    error     = $2
+WHERE id = $1
+  AND unpacked_at IS NULL
+  AND failed_at IS NULL;
+
+-- name: ResetTrackImportBatchFailure :exec
+UPDATE track_import_batches
+SET failed_at       = NULL,
+    error           = NULL,
+    skip_duplicates = $2
+WHERE id = $1;
+
+-- name: ListTrackImportHashes :many
+SELECT hash
+FROM track_imports
+WHERE id = ANY (@ids::bigint[])
+ORDER BY id;
+
+-- name: GetTrackImportBatchProgress :one
+SELECT COUNT(*)            AS total,
+       COUNT(completed_at) AS completed,
+       COUNT(failed_at)    AS failed,
+       COUNT(cancelled_at) AS cancelled
+FROM track_imports
+WHERE batch_id = $1;
+
+-- name: ListTrackImportBatchImports :many
+SELECT hash,
+       owner_id,
+       inserted_at,
+       completed_at,
+       failed_at,
+       error,
+       filename,
+       byte_size,
+       cancelled_at,
+       attempts,
+       batch_id,
+       skipped_duplicate_of_ids
+FROM track_imports
+WHERE batch_id = $1
+ORDER BY id;
+
+-- name: InsertTrackUpload :one
+INSERT INTO track_uploads (owner_id, filename, size, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: LockTrackUploadsOfOwner :exec
+SELECT pg_advisory_xact_lock(hashtext('track_uploads:' || @owner_id::text));
+
+-- name: GetOpenTrackUploadUsage :one
+SELECT COUNT(*)::int8               AS count,
+       COALESCE(SUM(size), 0)::int8 AS size
+FROM track_uploads
+WHERE owner_id = $1
+  AND expires_at > NOW();
+
+-- name: GetTrackUpload :one
+SELECT *
+FROM track_uploads
+WHERE id = $1
+  AND expires_at > NOW();
+
+-- name: GetTrackUploadForUpdate :one
+SELECT *
+FROM track_uploads
+WHERE id = $1
+  AND expires_at > NOW()
+    FOR UPDATE;
+
+-- name: InsertTrackUploadChunk :exec
+INSERT INTO track_upload_chunks (upload_id, start_offset, size)
+VALUES ($1, $2, $3);
+
+-- name: SetTrackUploadReceived :one
+UPDATE track_uploads
+SET received = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: LockTrackUpload :exec
+SELECT id
+FROM track_uploads
+WHERE id = $1
+    FOR UPDATE;
+
+-- name: ListTrackUploadChunks :many
+SELECT *
+FROM track_upload_chunks
+WHERE upload_id = $1
+ORDER BY start_offset;
+
+-- name: DeleteTrackUpload :exec
+DELETE
+FROM track_uploads
+WHERE id = $1;
+
+-- name: ListExpiredTrackUploadIDs :many
+SELECT u.id
+FROM track_uploads u
+WHERE u.expires_at <= NOW()
+  AND NOT EXISTS (SELECT
+                  FROM track_import_batches b
+                  WHERE b.upload_id = u.id
+                    AND b.unpacked_at IS NULL
+                    AND b.failed_at IS NULL)
+ORDER BY u.id
+LIMIT $1;
@@ -13,6 +13,210 @@ import (
 	"github.com/paulmach/orb/geojson"
 )
 
+const addCollectionTrack = `-- name: AddCollectionTrack :exec
+INSERT INTO collection_tracks (collection_id, track_id, position)
+SELECT $1, $2, COALESCE(MAX(position) + 1, 0)
+FROM collection_tracks
+WHERE collection_id = $1
+ON CONFLICT DO NOTHING
+`
+
+type AddCollectionTrackParams struct {
+	CollectionID int64 `json:"collectionID"`
+	TrackID      int64 `json:"trackID"`
+}
+
+func (q *Queries) AddCollectionTrack(ctx context.Context, arg AddCollectionTrackParams) error {
+	_, err := q.db.Exec(ctx, addCollectionTrack, arg.CollectionID, arg.TrackID)
+	return err
+}
+
+const addTrackTag = `-- name: AddTrackTag :exec
+INSERT INTO track_tags (track_id, owner_id, tag)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type AddTrackTagParams struct {
+	TrackID int64  `json:"trackID"`
+	OwnerID string `json:"ownerID"`
+	Tag     string `json:"tag"`
+}
+
+func (q *Queries) AddTrackTag(ctx context.Context, arg AddTrackTagParams) error {
+	_, err := q.db.Exec(ctx, addTrackTag, arg.TrackID, arg.OwnerID, arg.Tag)
+	return err
+}
+
+const cancelTrackImport = `-- name: CancelTrackImport :one
+UPDATE track_imports ti
+SET cancelled_at = NOW(),
+    data         = NULL,
+    blob_key     = NULL,
+    checkpoint   = NULL
+FROM track_imports old
+WHERE ti.id = $1
+  AND old.id = ti.id
+  AND ti.completed_at IS NULL
+  AND ti.failed_at IS NULL
+  AND ti.cancelled_at IS NULL
+RETURNING ti.job_id, old.blob_key
+`
+
+type CancelTrackImportRow struct {
+	JobID   *int64  `json:"jobID"`
+	BlobKey *string `json:"blobKey"`
+}
+
+func (q *Queries) CancelTrackImport(ctx context.Context, id int64) (CancelTrackImportRow, error) {
+	row := q.db.QueryRow(ctx, cancelTrackImport, id)
+	var i CancelTrackImportRow
+	err := row.Scan(&i.JobID, &i.BlobKey)
+	return i, err
+}
+
+const copyCollectionMemberships = `-- name: CopyCollectionMemberships :exec
+INSERT INTO collection_tracks (collection_id, track_id, position)
+SELECT collection_id, $1::bigint, MIN(position)
+FROM collection_tracks
+WHERE track_id = ANY ($2::bigint[])
+GROUP BY collection_id
+ON CONFLICT DO NOTHING
+`
+
+type CopyCollectionMembershipsParams struct {
+	MergedTrackID  int64   `json:"mergedTrackID"`
+	SourceTrackIds []int64 `json:"sourceTrackIds"`
+}
+
+func (q *Queries) CopyCollectionMemberships(ctx context.Context, arg CopyCollectionMembershipsParams) error {
+	_, err := q.db.Exec(ctx, copyCollectionMemberships, arg.MergedTrackID, arg.SourceTrackIds)
+	return err
+}
+
+const copyTag = `-- name: CopyTag :exec
+INSERT INTO track_tags (track_id, owner_id, tag)
+SELECT t.track_id, t.owner_id, $1::text
+FROM track_tags t
+WHERE t.owner_id = $2
+  AND t.tag = $3::text
+ON CONFLICT DO NOTHING
+`
+
+type CopyTagParams struct {
+	ToTag   string `json:"toTag"`
+	OwnerID string `json:"ownerID"`
+	FromTag string `json:"fromTag"`
+}
+
+func (q *Queries) CopyTag(ctx context.Context, arg CopyTagParams) error {
+	_, err := q.db.Exec(ctx, copyTag, arg.ToTag, arg.OwnerID, arg.FromTag)
+	return err
+}
+
+const copyTrackTags = `-- name: CopyTrackTags :exec
+INSERT INTO track_tags (track_id, owner_id, tag)
+SELECT DISTINCT $1::bigint, t.owner_id, t.tag
+FROM track_tags t
+WHERE t.track_id = ANY ($2::bigint[])
+ON CONFLICT DO NOTHING
+`
+
+type CopyTrackTagsParams struct {
+	ToTrackID    int64   `json:"toTrackID"`
+	FromTrackIds []int64 `json:"fromTrackIds"`
+}
+
+func (q *Queries) CopyTrackTags(ctx context.Context, arg CopyTrackTagsParams) error {
+	_, err := q.db.Exec(ctx, copyTrackTags, arg.ToTrackID, arg.FromTrackIds)
+	return err
+}
+
+const deleteCancelledTrackImport = `-- name: DeleteCancelledTrackImport :exec
+DELETE
+FROM track_imports
+WHERE (hash = $1 OR content_hash = $1)
+  AND cancelled_at IS NOT NULL
+`
+
+func (q *Queries) DeleteCancelledTrackImport(ctx context.Context, hash []byte) error {
+	_, err := q.db.Exec(ctx, deleteCancelledTrackImport, hash)
+	return err
+}
+
+const deleteCollection = `-- name: DeleteCollection :exec
+DELETE
+FROM collections
+WHERE id = $1
+`
+
+func (q *Queries) DeleteCollection(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, deleteCollection, id)
+	return err
+}
+
+const deleteGazetteerSource = `-- name: DeleteGazetteerSource :exec
+DELETE
+FROM gazetteer_places
+WHERE source = $1
+`
+
+func (q *Queries) DeleteGazetteerSource(ctx context.Context, source string) error {
+	_, err := q.db.Exec(ctx, deleteGazetteerSource, source)
+	return err
+}
+
+const deletePeakDataset = `-- name: DeletePeakDataset :exec
+DELETE
+FROM peaks
+WHERE dataset = $1
+`
+
+func (q *Queries) DeletePeakDataset(ctx context.Context, dataset string) error {
+	_, err := q.db.Exec(ctx, deletePeakDataset, dataset)
+	return err
+}
+
+const deletePrivacyZone = `-- name: DeletePrivacyZone :execrows
+DELETE
+FROM privacy_zones
+WHERE id = $1
+  AND user_id = $2
+`
+
+type DeletePrivacyZoneParams struct {
+	ID     int64  `json:"id"`
+	UserID string `json:"userID"`
+}
+
+func (q *Queries) DeletePrivacyZone(ctx context.Context, arg DeletePrivacyZoneParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePrivacyZone, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteTag = `-- name: DeleteTag :execrows
+DELETE
+FROM track_tags
+WHERE owner_id = $1
+  AND tag = $2
+`
+
+type DeleteTagParams struct {
+	OwnerID string `json:"ownerID"`
+	Tag     string `json:"tag"`
+}
+
+func (q *Queries) DeleteTag(ctx context.Context, arg DeleteTagParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTag, arg.OwnerID, arg.Tag)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteTrack = `-- name: DeleteTrack :exec
 DELETE
 FROM tracks
@@ -34,8 +238,163 @@ func (q *Queries) DeleteTrackImport(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteTrackImportIfUnused = `-- name: DeleteTrackImportIfUnused :one
+DELETE FROM track_imports ti
+WHERE ti.id = $1
+  AND NOT EXISTS(SELECT 1 FROM tracks t WHERE t.import_id = ti.id)
+RETURNING blob_key
+`
+
+func (q *Queries) DeleteTrackImportIfUnused(ctx context.Context, id int64) (*string, error) {
+	row := q.db.QueryRow(ctx, deleteTrackImportIfUnused, id)
+	var blob_key *string
+	err := row.Scan(&blob_key)
+	return blob_key, err
+}
+
+const deleteTrackSummits = `-- name: DeleteTrackSummits :exec
+DELETE
+FROM track_summits
+WHERE track_id = $1
+`
+
+func (q *Queries) DeleteTrackSummits(ctx context.Context, trackID int64) error {
+	_, err := q.db.Exec(ctx, deleteTrackSummits, trackID)
+	return err
+}
+
+const deleteTrackUpload = `-- name: DeleteTrackUpload :exec
+DELETE
+FROM track_uploads
+WHERE id = $1
+`
+
+func (q *Queries) DeleteTrackUpload(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, deleteTrackUpload, id)
+	return err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE
+FROM webhooks
+WHERE id = $1
+  AND owner_id = $2
+`
+
+type DeleteWebhookParams struct {
+	ID      int64  `json:"id"`
+	OwnerID string `json:"ownerID"`
+}
+
+func (q *Queries) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWebhook, arg.ID, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const dismissTrackDuplicate = `-- name: DismissTrackDuplicate :execrows
+UPDATE track_duplicates
+SET dismissed_at = NOW()
+WHERE track_id = $1
+  AND duplicate_of_id = $2
+  AND owner_id = $3
+  AND dismissed_at IS NULL
+`
+
+type DismissTrackDuplicateParams struct {
+	TrackID       int64  `json:"trackID"`
+	DuplicateOfID int64  `json:"duplicateOfID"`
+	OwnerID       string `json:"ownerID"`
+}
+
+func (q *Queries) DismissTrackDuplicate(ctx context.Context, arg DismissTrackDuplicateParams) (int64, error) {
+	result, err := q.db.Exec(ctx, dismissTrackDuplicate, arg.TrackID, arg.DuplicateOfID, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getActiveTrackShare = `-- name: GetActiveTrackShare :one
+SELECT id, token, track_id, owner_id, created_at, expires_at, revoked_at
+FROM track_shares
+WHERE token = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+`
+
+func (q *Queries) GetActiveTrackShare(ctx context.Context, token string) (TrackShare, error) {
+	row := q.db.QueryRow(ctx, getActiveTrackShare, token)
+	var i TrackShare
+	err := row.Scan(
+		&i.ID,
+		&i.Token,
+		&i.TrackID,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getCollection = `-- name: GetCollection :one
+SELECT id, owner_id, name, description, cover_track_id, created_at
+FROM collections
+WHERE id = $1
+`
+
+func (q *Queries) GetCollection(ctx context.Context, id int64) (Collection, error) {
+	row := q.db.QueryRow(ctx, getCollection, id)
+	var i Collection
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Description,
+		&i.CoverTrackID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getCollectionOwner = `-- name: GetCollectionOwner :one
+SELECT owner_id
+FROM collections
+WHERE id = $1
+`
+
+func (q *Queries) GetCollectionOwner(ctx context.Context, id int64) (string, error) {
+	row := q.db.QueryRow(ctx, getCollectionOwner, id)
+	var owner_id string
+	err := row.Scan(&owner_id)
+	return owner_id, err
+}
+
+const getOpenTrackUploadUsage = `-- name: GetOpenTrackUploadUsage :one
+SELECT COUNT(*)::int8               AS count,
+       COALESCE(SUM(size), 0)::int8 AS size
+FROM track_uploads
+WHERE owner_id = $1
+  AND expires_at > NOW()
+`
+
+type GetOpenTrackUploadUsageRow struct {
+	Count int64 `json:"count"`
+	Size  int64 `json:"size"`
+}
+
+func (q *Queries) GetOpenTrackUploadUsage(ctx context.Context, ownerID string) (GetOpenTrackUploadUsageRow, error) {
+	row := q.db.QueryRow(ctx, getOpenTrackUploadUsage, ownerID)
+	var i GetOpenTrackUploadUsageRow
+	err := row.Scan(&i.Count, &i.Size)
+	return i, err
+}
+
 const getTrack = `-- name: GetTrack :one
-SELECT id, owner_id, name, upload_time, time, geojson, import_id
+SELECT id, owner_id, name, upload_time, time, geojson, import_id, original_geojson, activity_type, timezone
 FROM tracks
 WHERE id = $1
 `
@@ -51,12 +410,15 @@ func (q *Queries) GetTrack(ctx context.Context, id int64) (Track, error) {
 		&i.Time,
 		&i.Geojson,
 		&i.ImportID,
+		&i.OriginalGeojson,
+		&i.ActivityType,
+		&i.Timezone,
 	)
 	return i, err
 }
 
 const getTrackImport = `-- name: GetTrackImport :one
-SELECT id, owner_id, hash, inserted_at, completed_at, failed_at, error, filename, data
+SELECT id, owner_id, hash, inserted_at, completed_at, failed_at, error, filename, data, skip_duplicates, cancelled_at, job_id, attempts, checkpoint, batch_id, blob_key, byte_size, skipped_duplicate_of_ids, source_url, content_hash, duplicate_of_id
 FROM track_imports
 WHERE id = $1
 `
@@ -74,6 +436,132 @@ func (q *Queries) GetTrackImport(ctx context.Context, id int64) (TrackImport, er
 		&i.Error,
 		&i.Filename,
 		&i.Data,
+		&i.SkipDuplicates,
+		&i.CancelledAt,
+		&i.JobID,
+		&i.Attempts,
+		&i.Checkpoint,
+		&i.BatchID,
+		&i.BlobKey,
+		&i.ByteSize,
+		&i.SkippedDuplicateOfIds,
+		&i.SourceUrl,
+		&i.ContentHash,
+		&i.DuplicateOfID,
+	)
+	return i, err
+}
+
+const getTrackImportBatch = `-- name: GetTrackImportBatch :one
+SELECT id, owner_id, filename, inserted_at, hash, upload_id, blob_key, byte_size, skip_duplicates, unpacked_at, failed_at, error, skipped, duplicate_of_ids
+FROM track_import_batches
+WHERE id = $1
+`
+
+func (q *Queries) GetTrackImportBatch(ctx context.Context, id int64) (TrackImportBatch, error) {
+	row := q.db.QueryRow(ctx, getTrackImportBatch, id)
+	var i TrackImportBatch
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.InsertedAt,
+		&i.Hash,
+		&i.UploadID,
+		&i.BlobKey,
+		&i.ByteSize,
+		&i.SkipDuplicates,
+		&i.UnpackedAt,
+		&i.FailedAt,
+		&i.Error,
+		&i.Skipped,
+		&i.DuplicateOfIds,
+	)
+	return i, err
+}
+
+const getTrackImportBatchByHashForUpdate = `-- name: GetTrackImportBatchByHashForUpdate :one
+SELECT id, owner_id, filename, inserted_at, hash, upload_id, blob_key, byte_size, skip_duplicates, unpacked_at, failed_at, error, skipped, duplicate_of_ids
+FROM track_import_batches
+WHERE hash = $1
+    FOR UPDATE
+`
+
+func (q *Queries) GetTrackImportBatchByHashForUpdate(ctx context.Context, hash []byte) (TrackImportBatch, error) {
+	row := q.db.QueryRow(ctx, getTrackImportBatchByHashForUpdate, hash)
+	var i TrackImportBatch
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.InsertedAt,
+		&i.Hash,
+		&i.UploadID,
+		&i.BlobKey,
+		&i.ByteSize,
+		&i.SkipDuplicates,
+		&i.UnpackedAt,
+		&i.FailedAt,
+		&i.Error,
+		&i.Skipped,
+		&i.DuplicateOfIds,
+	)
+	return i, err
+}
+
+const getTrackImportBatchByUploadForUpdate = `-- name: GetTrackImportBatchByUploadForUpdate :one
+SELECT id, owner_id, filename, inserted_at, hash, upload_id, blob_key, byte_size, skip_duplicates, unpacked_at, failed_at, error, skipped, duplicate_of_ids
+FROM track_import_batches
+WHERE upload_id = $1
+    FOR UPDATE
+`
+
+func (q *Queries) GetTrackImportBatchByUploadForUpdate(ctx context.Context, uploadID *int64) (TrackImportBatch, error) {
+	row := q.db.QueryRow(ctx, getTrackImportBatchByUploadForUpdate, uploadID)
+	var i TrackImportBatch
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.InsertedAt,
+		&i.Hash,
+		&i.UploadID,
+		&i.BlobKey,
+		&i.ByteSize,
+		&i.SkipDuplicates,
+		&i.UnpackedAt,
+		&i.FailedAt,
+		&i.Error,
+		&i.Skipped,
+		&i.DuplicateOfIds,
+	)
+	return i, err
+}
+
+const getTrackImportBatchProgress = `-- name: GetTrackImportBatchProgress :one
+SELECT COUNT(*)            AS total,
+       COUNT(completed_at) AS completed,
+       COUNT(failed_at)    AS failed,
+       COUNT(cancelled_at) AS cancelled
+FROM track_imports
+WHERE batch_id = $1
+`
+
+type GetTrackImportBatchProgressRow struct {
+	Total     int64 `json:"total"`
+	Completed int64 `json:"completed"`
+	Failed    int64 `json:"failed"`
+	Cancelled int64 `json:"cancelled"`
+}
+
+func (q *Queries) GetTrackImportBatchProgress(ctx context.Context, batchID *int64) (GetTrackImportBatchProgressRow, error) {
+	row := q.db.QueryRow(ctx, getTrackImportBatchProgress, batchID)
+	var i GetTrackImportBatchProgressRow
+	err := row.Scan(
+		&i.Total,
+		&i.Completed,
+		&i.Failed,
+		&i.Cancelled,
 	)
 	return i, err
 }
@@ -91,28 +579,65 @@ func (q *Queries) GetTrackImportID(ctx context.Context, id int64) (*int64, error
 	return import_id, err
 }
 
-const getTrackImportStatus = `-- name: GetTrackImportStatus :one
-SELECT hash,
-       owner_id,
-       inserted_at,
-       completed_at,
-       failed_at,
-       error,
-       filename,
-       length(data) as byte_size
+const getTrackImportIDByContentHash = `-- name: GetTrackImportIDByContentHash :one
+SELECT id
 FROM track_imports
-WHERE id = $1
+WHERE content_hash = $1
+`
+
+func (q *Queries) GetTrackImportIDByContentHash(ctx context.Context, contentHash []byte) (int64, error) {
+	row := q.db.QueryRow(ctx, getTrackImportIDByContentHash, contentHash)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getTrackImportIDByHash = `-- name: GetTrackImportIDByHash :one
+SELECT id
+FROM track_imports
+WHERE hash = $1
+`
+
+func (q *Queries) GetTrackImportIDByHash(ctx context.Context, hash []byte) (int64, error) {
+	row := q.db.QueryRow(ctx, getTrackImportIDByHash, hash)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getTrackImportStatus = `-- name: GetTrackImportStatus :one
+SELECT ti.hash,
+       ti.owner_id,
+       ti.inserted_at,
+       ti.completed_at,
+       ti.failed_at,
+       ti.error,
+       ti.filename,
+       ti.byte_size,
+       ti.cancelled_at,
+       ti.attempts,
+       ti.batch_id,
+       ti.skipped_duplicate_of_ids,
+       d.hash AS duplicate_of_hash
+FROM track_imports ti
+         LEFT JOIN track_imports d ON d.id = ti.duplicate_of_id
+WHERE ti.id = $1
 `
 
 type GetTrackImportStatusRow struct {
-	Hash        []byte           `json:"hash"`
-	OwnerID     string           `json:"ownerID"`
-	InsertedAt  pgtype.Timestamp `json:"insertedAt"`
-	CompletedAt pgtype.Timestamp `json:"completedAt"`
-	FailedAt    pgtype.Timestamp `json:"failedAt"`
-	Error       *string          `json:"error"`
-	Filename    string           `json:"filename"`
-	ByteSize    int32            `json:"byteSize"`
+	Hash                  []byte           `json:"hash"`
+	OwnerID               string           `json:"ownerID"`
+	InsertedAt            pgtype.Timestamp `json:"insertedAt"`
+	CompletedAt           pgtype.Timestamp `json:"completedAt"`
+	FailedAt              pgtype.Timestamp `json:"failedAt"`
+	Error                 *string          `json:"error"`
+	Filename              string           `json:"filename"`
+	ByteSize              int64            `json:"byteSize"`
+	CancelledAt           pgtype.Timestamp `json:"cancelledAt"`
+	Attempts              int32            `json:"attempts"`
+	BatchID               *int64           `json:"batchID"`
+	SkippedDuplicateOfIds []int64          `json:"skippedDuplicateOfIds"`
+	DuplicateOfHash       []byte           `json:"duplicateOfHash"`
 }
 
 func (q *Queries) GetTrackImportStatus(ctx context.Context, id int64) (GetTrackImportStatusRow, error) {
@@ -127,6 +652,11 @@ func (q *Queries) GetTrackImportStatus(ctx context.Context, id int64) (GetTrackI
 		&i.Error,
 		&i.Filename,
 		&i.ByteSize,
+		&i.CancelledAt,
+		&i.Attempts,
+		&i.BatchID,
+		&i.SkippedDuplicateOfIds,
+		&i.DuplicateOfHash,
 	)
 	return i, err
 }
@@ -144,22 +674,87 @@ func (q *Queries) GetTrackOwner(ctx context.Context, id int64) (*string, error)
 	return owner_id, err
 }
 
-const getUnitSettings = `-- name: GetUnitSettings :one
-SELECT value
-FROM unit_settings
-WHERE user_id = $1
+const getTrackUpload = `-- name: GetTrackUpload :one
+SELECT id, owner_id, filename, size, received, created_at, expires_at
+FROM track_uploads
+WHERE id = $1
+  AND expires_at > NOW()
 `
 
-func (q *Queries) GetUnitSettings(ctx context.Context, userID string) (json.RawMessage, error) {
-	row := q.db.QueryRow(ctx, getUnitSettings, userID)
-	var value json.RawMessage
-	err := row.Scan(&value)
-	return value, err
-}
-
-const hasImportedTrack = `-- name: HasImportedTrack :one
-SELECT EXISTS(
-    SELECT 1
+func (q *Queries) GetTrackUpload(ctx context.Context, id int64) (TrackUpload, error) {
+	row := q.db.QueryRow(ctx, getTrackUpload, id)
+	var i TrackUpload
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.Size,
+		&i.Received,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getTrackUploadForUpdate = `-- name: GetTrackUploadForUpdate :one
+SELECT id, owner_id, filename, size, received, created_at, expires_at
+FROM track_uploads
+WHERE id = $1
+  AND expires_at > NOW()
+    FOR UPDATE
+`
+
+func (q *Queries) GetTrackUploadForUpdate(ctx context.Context, id int64) (TrackUpload, error) {
+	row := q.db.QueryRow(ctx, getTrackUploadForUpdate, id)
+	var i TrackUpload
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.Size,
+		&i.Received,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getUnitSettings = `-- name: GetUnitSettings :one
+SELECT value
+FROM unit_settings
+WHERE user_id = $1
+`
+
+func (q *Queries) GetUnitSettings(ctx context.Context, userID string) (json.RawMessage, error) {
+	row := q.db.QueryRow(ctx, getUnitSettings, userID)
+	var value json.RawMessage
+	err := row.Scan(&value)
+	return value, err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, owner_id, url, secret, events, created_at
+FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	row := q.db.QueryRow(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const hasImportedTrack = `-- name: HasImportedTrack :one
+SELECT EXISTS(
+    SELECT 1
     FROM tracks t
     JOIN track_imports ti ON t.import_id = ti.id
     WHERE t.owner_id = $1 AND
@@ -181,20 +776,76 @@ func (q *Queries) HasImportedTrack(ctx context.Context, arg HasImportedTrackPara
 	return exists, err
 }
 
+const insertCollection = `-- name: InsertCollection :one
+INSERT INTO collections (owner_id, name, description)
+VALUES ($1, $2, $3)
+RETURNING id, owner_id, name, description, cover_track_id, created_at
+`
+
+type InsertCollectionParams struct {
+	OwnerID     string `json:"ownerID"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (q *Queries) InsertCollection(ctx context.Context, arg InsertCollectionParams) (Collection, error) {
+	row := q.db.QueryRow(ctx, insertCollection, arg.OwnerID, arg.Name, arg.Description)
+	var i Collection
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Description,
+		&i.CoverTrackID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const insertCollectionTrackAfter = `-- name: InsertCollectionTrackAfter :exec
+INSERT INTO collection_tracks (collection_id, track_id, position)
+SELECT src.collection_id, $1::bigint, src.position + 1
+FROM collection_tracks src
+WHERE src.track_id = $2
+ON CONFLICT DO NOTHING
+`
+
+type InsertCollectionTrackAfterParams struct {
+	NewTrackID int64 `json:"newTrackID"`
+	TrackID    int64 `json:"trackID"`
+}
+
+func (q *Queries) InsertCollectionTrackAfter(ctx context.Context, arg InsertCollectionTrackAfterParams) error {
+	_, err := q.db.Exec(ctx, insertCollectionTrackAfter, arg.NewTrackID, arg.TrackID)
+	return err
+}
+
+type InsertGazetteerPlacesParams struct {
+	Source          string   `json:"source"`
+	Name            string   `json:"name"`
+	Kind            string   `json:"kind"`
+	Lng             float64  `json:"lng"`
+	Lat             float64  `json:"lat"`
+	ElevationMeters *float64 `json:"elevationMeters"`
+}
+
 const insertImportedTrack = `-- name: InsertImportedTrack :one
 INSERT INTO tracks
-    (owner_id, name, upload_time, time, geojson, import_id)
-VALUES ($1, $2, $3, $4, $5, $6)
+    (owner_id, name, upload_time, time, geojson, import_id, original_geojson, activity_type, timezone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING id
 `
 
 type InsertImportedTrackParams struct {
-	OwnerID    *string          `json:"ownerID"`
-	Name       *string          `json:"name"`
-	UploadTime pgtype.Timestamp `json:"uploadTime"`
-	Time       pgtype.Timestamp `json:"time"`
-	Geojson    geojson.Feature  `json:"geojson"`
-	ImportID   *int64           `json:"importID"`
+	OwnerID         *string            `json:"ownerID"`
+	Name            *string            `json:"name"`
+	UploadTime      pgtype.Timestamptz `json:"uploadTime"`
+	Time            pgtype.Timestamptz `json:"time"`
+	Geojson         geojson.Feature    `json:"geojson"`
+	ImportID        *int64             `json:"importID"`
+	OriginalGeojson *geojson.Feature   `json:"originalGeojson"`
+	ActivityType    *string            `json:"activityType"`
+	Timezone        *string            `json:"timezone"`
 }
 
 func (q *Queries) InsertImportedTrack(ctx context.Context, arg InsertImportedTrackParams) (int64, error) {
@@ -205,83 +856,424 @@ func (q *Queries) InsertImportedTrack(ctx context.Context, arg InsertImportedTra
 		arg.Time,
 		arg.Geojson,
 		arg.ImportID,
+		arg.OriginalGeojson,
+		arg.ActivityType,
+		arg.Timezone,
 	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
-const insertTrackImport = `-- name: InsertTrackImport :one
-INSERT INTO track_imports (owner_id, filename, data, hash)
+type InsertPeaksParams struct {
+	Dataset         string   `json:"dataset"`
+	Name            string   `json:"name"`
+	Lng             float64  `json:"lng"`
+	Lat             float64  `json:"lat"`
+	ElevationMeters *float64 `json:"elevationMeters"`
+}
+
+const insertPrivacyZone = `-- name: InsertPrivacyZone :one
+INSERT INTO privacy_zones (user_id, name, lng, lat, radius_meters)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, user_id, name, lng, lat, radius_meters
+`
+
+type InsertPrivacyZoneParams struct {
+	UserID       string  `json:"userID"`
+	Name         string  `json:"name"`
+	Lng          float64 `json:"lng"`
+	Lat          float64 `json:"lat"`
+	RadiusMeters float64 `json:"radiusMeters"`
+}
+
+func (q *Queries) InsertPrivacyZone(ctx context.Context, arg InsertPrivacyZoneParams) (PrivacyZone, error) {
+	row := q.db.QueryRow(ctx, insertPrivacyZone,
+		arg.UserID,
+		arg.Name,
+		arg.Lng,
+		arg.Lat,
+		arg.RadiusMeters,
+	)
+	var i PrivacyZone
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Lng,
+		&i.Lat,
+		&i.RadiusMeters,
+	)
+	return i, err
+}
+
+const insertTrackDuplicate = `-- name: InsertTrackDuplicate :exec
+INSERT INTO track_duplicates (track_id, duplicate_of_id, owner_id, frechet_meters)
 VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING
+`
+
+type InsertTrackDuplicateParams struct {
+	TrackID       int64   `json:"trackID"`
+	DuplicateOfID int64   `json:"duplicateOfID"`
+	OwnerID       string  `json:"ownerID"`
+	FrechetMeters float64 `json:"frechetMeters"`
+}
+
+func (q *Queries) InsertTrackDuplicate(ctx context.Context, arg InsertTrackDuplicateParams) error {
+	_, err := q.db.Exec(ctx, insertTrackDuplicate,
+		arg.TrackID,
+		arg.DuplicateOfID,
+		arg.OwnerID,
+		arg.FrechetMeters,
+	)
+	return err
+}
+
+const insertTrackImport = `-- name: InsertTrackImport :one
+INSERT INTO track_imports (owner_id, filename, blob_key, byte_size, hash, skip_duplicates, batch_id, source_url,
+                           content_hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING id
 `
 
 type InsertTrackImportParams struct {
-	OwnerID  string `json:"ownerID"`
-	Filename string `json:"filename"`
-	Data     []byte `json:"data"`
-	Hash     []byte `json:"hash"`
+	OwnerID        string  `json:"ownerID"`
+	Filename       string  `json:"filename"`
+	BlobKey        *string `json:"blobKey"`
+	ByteSize       int64   `json:"byteSize"`
+	Hash           []byte  `json:"hash"`
+	SkipDuplicates bool    `json:"skipDuplicates"`
+	BatchID        *int64  `json:"batchID"`
+	SourceUrl      *string `json:"sourceUrl"`
+	ContentHash    []byte  `json:"contentHash"`
 }
 
 func (q *Queries) InsertTrackImport(ctx context.Context, arg InsertTrackImportParams) (int64, error) {
 	row := q.db.QueryRow(ctx, insertTrackImport,
 		arg.OwnerID,
 		arg.Filename,
-		arg.Data,
+		arg.BlobKey,
+		arg.ByteSize,
 		arg.Hash,
+		arg.SkipDuplicates,
+		arg.BatchID,
+		arg.SourceUrl,
+		arg.ContentHash,
 	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
-const listMyPendingOrRecentImports = `-- name: ListMyPendingOrRecentImports :many
-SELECT hash,
-       owner_id,
-       inserted_at,
-       completed_at,
-       failed_at,
-       error,
-       filename,
-       length(data) as byte_size
-FROM track_imports
-WHERE owner_id = $1
-  AND (completed_at IS NULL OR
-       completed_at > NOW() - INTERVAL '1 DAY' OR
-       failed_at > NOW() - INTERVAL '1 DAY')
-ORDER BY inserted_at DESC
+const insertTrackImportBatch = `-- name: InsertTrackImportBatch :one
+INSERT INTO track_import_batches (owner_id, filename, hash, upload_id, blob_key, byte_size, skip_duplicates)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id
 `
 
-type ListMyPendingOrRecentImportsRow struct {
-	Hash        []byte           `json:"hash"`
-	OwnerID     string           `json:"ownerID"`
-	InsertedAt  pgtype.Timestamp `json:"insertedAt"`
-	CompletedAt pgtype.Timestamp `json:"completedAt"`
-	FailedAt    pgtype.Timestamp `json:"failedAt"`
-	Error       *string          `json:"error"`
-	Filename    string           `json:"filename"`
-	ByteSize    int32            `json:"byteSize"`
+type InsertTrackImportBatchParams struct {
+	OwnerID        string  `json:"ownerID"`
+	Filename       string  `json:"filename"`
+	Hash           []byte  `json:"hash"`
+	UploadID       *int64  `json:"uploadID"`
+	BlobKey        *string `json:"blobKey"`
+	ByteSize       int64   `json:"byteSize"`
+	SkipDuplicates bool    `json:"skipDuplicates"`
 }
 
-func (q *Queries) ListMyPendingOrRecentImports(ctx context.Context, ownerID string) ([]ListMyPendingOrRecentImportsRow, error) {
-	rows, err := q.db.Query(ctx, listMyPendingOrRecentImports, ownerID)
+func (q *Queries) InsertTrackImportBatch(ctx context.Context, arg InsertTrackImportBatchParams) (int64, error) {
+	row := q.db.QueryRow(ctx, insertTrackImportBatch,
+		arg.OwnerID,
+		arg.Filename,
+		arg.Hash,
+		arg.UploadID,
+		arg.BlobKey,
+		arg.ByteSize,
+		arg.SkipDuplicates,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertTrackShare = `-- name: InsertTrackShare :one
+INSERT INTO track_shares (token, track_id, owner_id, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id, token, track_id, owner_id, created_at, expires_at, revoked_at
+`
+
+type InsertTrackShareParams struct {
+	Token     string             `json:"token"`
+	TrackID   int64              `json:"trackID"`
+	OwnerID   string             `json:"ownerID"`
+	ExpiresAt pgtype.Timestamptz `json:"expiresAt"`
+}
+
+func (q *Queries) InsertTrackShare(ctx context.Context, arg InsertTrackShareParams) (TrackShare, error) {
+	row := q.db.QueryRow(ctx, insertTrackShare,
+		arg.Token,
+		arg.TrackID,
+		arg.OwnerID,
+		arg.ExpiresAt,
+	)
+	var i TrackShare
+	err := row.Scan(
+		&i.ID,
+		&i.Token,
+		&i.TrackID,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const insertTrackSummit = `-- name: InsertTrackSummit :exec
+INSERT INTO track_summits (track_id, peak_id, visited_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (track_id, peak_id) DO UPDATE SET visited_at = LEAST(track_summits.visited_at, excluded.visited_at)
+`
+
+type InsertTrackSummitParams struct {
+	TrackID   int64              `json:"trackID"`
+	PeakID    int64              `json:"peakID"`
+	VisitedAt pgtype.Timestamptz `json:"visitedAt"`
+}
+
+func (q *Queries) InsertTrackSummit(ctx context.Context, arg InsertTrackSummitParams) error {
+	_, err := q.db.Exec(ctx, insertTrackSummit, arg.TrackID, arg.PeakID, arg.VisitedAt)
+	return err
+}
+
+const insertTrackUpload = `-- name: InsertTrackUpload :one
+INSERT INTO track_uploads (owner_id, filename, size, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id, owner_id, filename, size, received, created_at, expires_at
+`
+
+type InsertTrackUploadParams struct {
+	OwnerID   string             `json:"ownerID"`
+	Filename  string             `json:"filename"`
+	Size      int64              `json:"size"`
+	ExpiresAt pgtype.Timestamptz `json:"expiresAt"`
+}
+
+func (q *Queries) InsertTrackUpload(ctx context.Context, arg InsertTrackUploadParams) (TrackUpload, error) {
+	row := q.db.QueryRow(ctx, insertTrackUpload,
+		arg.OwnerID,
+		arg.Filename,
+		arg.Size,
+		arg.ExpiresAt,
+	)
+	var i TrackUpload
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.Size,
+		&i.Received,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const insertTrackUploadChunk = `-- name: InsertTrackUploadChunk :exec
+INSERT INTO track_upload_chunks (upload_id, start_offset, size)
+VALUES ($1, $2, $3)
+`
+
+type InsertTrackUploadChunkParams struct {
+	UploadID    int64 `json:"uploadID"`
+	StartOffset int64 `json:"startOffset"`
+	Size        int64 `json:"size"`
+}
+
+func (q *Queries) InsertTrackUploadChunk(ctx context.Context, arg InsertTrackUploadChunkParams) error {
+	_, err := q.db.Exec(ctx, insertTrackUploadChunk, arg.UploadID, arg.StartOffset, arg.Size)
+	return err
+}
+
+const insertWebhook = `-- name: InsertWebhook :one
+INSERT INTO webhooks (owner_id, url, secret, events)
+VALUES ($1, $2, $3, $4)
+RETURNING id, owner_id, url, secret, events, created_at
+`
+
+type InsertWebhookParams struct {
+	OwnerID string   `json:"ownerID"`
+	Url     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+}
+
+func (q *Queries) InsertWebhook(ctx context.Context, arg InsertWebhookParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, insertWebhook,
+		arg.OwnerID,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const insertWebhookDelivery = `-- name: InsertWebhookDelivery :exec
+INSERT INTO webhook_deliveries (webhook_id, event_id, event, attempt, status_code, error, duration_ms, succeeded)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+`
+
+type InsertWebhookDeliveryParams struct {
+	WebhookID  int64   `json:"webhookID"`
+	EventID    string  `json:"eventID"`
+	Event      string  `json:"event"`
+	Attempt    int32   `json:"attempt"`
+	StatusCode *int32  `json:"statusCode"`
+	Error      *string `json:"error"`
+	DurationMs int32   `json:"durationMs"`
+	Succeeded  bool    `json:"succeeded"`
+}
+
+func (q *Queries) InsertWebhookDelivery(ctx context.Context, arg InsertWebhookDeliveryParams) error {
+	_, err := q.db.Exec(ctx, insertWebhookDelivery,
+		arg.WebhookID,
+		arg.EventID,
+		arg.Event,
+		arg.Attempt,
+		arg.StatusCode,
+		arg.Error,
+		arg.DurationMs,
+		arg.Succeeded,
+	)
+	return err
+}
+
+const listBaggedPeaks = `-- name: ListBaggedPeaks :many
+SELECT p.id, p.dataset, p.name, p.lng, p.lat, p.elevation_meters,
+       MIN(ts.visited_at)::timestamptz AS first_visited_at,
+       MAX(ts.visited_at)::timestamptz AS latest_visited_at,
+       COUNT(DISTINCT ts.track_id)   AS visits
+FROM track_summits ts
+         JOIN tracks t ON t.id = ts.track_id
+         JOIN peaks p ON p.id = ts.peak_id
+WHERE t.owner_id = $1
+  AND ($2::text IS NULL OR p.dataset = $2)
+GROUP BY p.id
+ORDER BY first_visited_at, p.name
+`
+
+type ListBaggedPeaksParams struct {
+	OwnerID *string `json:"ownerID"`
+	Dataset *string `json:"dataset"`
+}
+
+type ListBaggedPeaksRow struct {
+	Peak            Peak               `json:"peak"`
+	FirstVisitedAt  pgtype.Timestamptz `json:"firstVisitedAt"`
+	LatestVisitedAt pgtype.Timestamptz `json:"latestVisitedAt"`
+	Visits          int64              `json:"visits"`
+}
+
+func (q *Queries) ListBaggedPeaks(ctx context.Context, arg ListBaggedPeaksParams) ([]ListBaggedPeaksRow, error) {
+	rows, err := q.db.Query(ctx, listBaggedPeaks, arg.OwnerID, arg.Dataset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []ListMyPendingOrRecentImportsRow{}
+	items := []ListBaggedPeaksRow{}
 	for rows.Next() {
-		var i ListMyPendingOrRecentImportsRow
+		var i ListBaggedPeaksRow
 		if err := rows.Scan(
-			&i.Hash,
-			&i.OwnerID,
-			&i.InsertedAt,
-			&i.CompletedAt,
-			&i.FailedAt,
-			&i.Error,
-			&i.Filename,
-			&i.ByteSize,
+			&i.Peak.ID,
+			&i.Peak.Dataset,
+			&i.Peak.Name,
+			&i.Peak.Lng,
+			&i.Peak.Lat,
+			&i.Peak.ElevationMeters,
+			&i.FirstVisitedAt,
+			&i.LatestVisitedAt,
+			&i.Visits,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCollectionTrackIDs = `-- name: ListCollectionTrackIDs :many
+SELECT track_id
+FROM collection_tracks
+WHERE collection_id = $1
+ORDER BY position, track_id
+`
+
+func (q *Queries) ListCollectionTrackIDs(ctx context.Context, collectionID int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listCollectionTrackIDs, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var track_id int64
+		if err := rows.Scan(&track_id); err != nil {
+			return nil, err
+		}
+		items = append(items, track_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCollections = `-- name: ListCollections :many
+SELECT c.id, c.owner_id, c.name, c.description, c.cover_track_id, c.created_at,
+       COALESCE(array_agg(ct.track_id ORDER BY ct.position, ct.track_id)
+                FILTER (WHERE ct.track_id IS NOT NULL), '{}')::bigint[] AS track_ids
+FROM collections c
+         LEFT JOIN collection_tracks ct ON ct.collection_id = c.id
+WHERE c.owner_id = $1
+GROUP BY c.id
+ORDER BY c.name
+`
+
+type ListCollectionsRow struct {
+	Collection Collection `json:"collection"`
+	TrackIds   []int64    `json:"trackIds"`
+}
+
+func (q *Queries) ListCollections(ctx context.Context, ownerID string) ([]ListCollectionsRow, error) {
+	rows, err := q.db.Query(ctx, listCollections, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListCollectionsRow{}
+	for rows.Next() {
+		var i ListCollectionsRow
+		if err := rows.Scan(
+			&i.Collection.ID,
+			&i.Collection.OwnerID,
+			&i.Collection.Name,
+			&i.Collection.Description,
+			&i.Collection.CoverTrackID,
+			&i.Collection.CreatedAt,
+			&i.TrackIds,
 		); err != nil {
 			return nil, err
 		}
@@ -293,15 +1285,40 @@ func (q *Queries) ListMyPendingOrRecentImports(ctx context.Context, ownerID stri
 	return items, nil
 }
 
-const listTracksOrderByTime = `-- name: ListTracksOrderByTime :many
-SELECT id, owner_id, name, upload_time, time, geojson, import_id
+const listDuplicateCandidates = `-- name: ListDuplicateCandidates :many
+SELECT id, owner_id, name, upload_time, time, geojson, import_id, original_geojson, activity_type, timezone
 FROM tracks
 WHERE owner_id = $1
-ORDER BY time DESC
+  AND ($2::timestamptz IS NULL OR
+       time IS NULL OR
+       time = upload_time OR
+       time BETWEEN $2 AND $3)
+  AND (geojson -> 'geometry' -> 'coordinates' -> 0 ->> 1)::double precision BETWEEN $4::float8 AND $5::float8
+  AND (geojson -> 'geometry' -> 'coordinates' -> 0 ->> 0)::double precision BETWEEN $6::float8 AND $7::float8
+ORDER BY id DESC
+LIMIT 50
 `
 
-func (q *Queries) ListTracksOrderByTime(ctx context.Context, ownerID *string) ([]Track, error) {
-	rows, err := q.db.Query(ctx, listTracksOrderByTime, ownerID)
+type ListDuplicateCandidatesParams struct {
+	OwnerID *string            `json:"ownerID"`
+	MinTime pgtype.Timestamptz `json:"minTime"`
+	MaxTime pgtype.Timestamptz `json:"maxTime"`
+	MinLat  float64            `json:"minLat"`
+	MaxLat  float64            `json:"maxLat"`
+	MinLng  float64            `json:"minLng"`
+	MaxLng  float64            `json:"maxLng"`
+}
+
+func (q *Queries) ListDuplicateCandidates(ctx context.Context, arg ListDuplicateCandidatesParams) ([]Track, error) {
+	rows, err := q.db.Query(ctx, listDuplicateCandidates,
+		arg.OwnerID,
+		arg.MinTime,
+		arg.MaxTime,
+		arg.MinLat,
+		arg.MaxLat,
+		arg.MinLng,
+		arg.MaxLng,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -317,6 +1334,9 @@ func (q *Queries) ListTracksOrderByTime(ctx context.Context, ownerID *string) ([
 			&i.Time,
 			&i.Geojson,
 			&i.ImportID,
+			&i.OriginalGeojson,
+			&i.ActivityType,
+			&i.Timezone,
 		); err != nil {
 			return nil, err
 		}
@@ -328,22 +1348,983 @@ func (q *Queries) ListTracksOrderByTime(ctx context.Context, ownerID *string) ([
 	return items, nil
 }
 
-const markTrackImportCompleted = `-- name: MarkTrackImportCompleted :exec
-UPDATE track_imports
-SET completed_at = NOW()
-WHERE id = $1
+const listExpiredTrackUploadIDs = `-- name: ListExpiredTrackUploadIDs :many
+SELECT u.id
+FROM track_uploads u
+WHERE u.expires_at <= NOW()
+  AND NOT EXISTS (SELECT
+                  FROM track_import_batches b
+                  WHERE b.upload_id = u.id
+                    AND b.unpacked_at IS NULL
+                    AND b.failed_at IS NULL)
+ORDER BY u.id
+LIMIT $1
 `
 
-func (q *Queries) MarkTrackImportCompleted(ctx context.Context, id int64) error {
-	_, err := q.db.Exec(ctx, markTrackImportCompleted, id)
-	return err
+func (q *Queries) ListExpiredTrackUploadIDs(ctx context.Context, limit int32) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listExpiredTrackUploadIDs, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const markTrackImportFailed = `-- name: MarkTrackImportFailed :exec
+const listImportTrackIDs = `-- name: ListImportTrackIDs :many
+SELECT id
+FROM tracks
+WHERE import_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListImportTrackIDs(ctx context.Context, importID *int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listImportTrackIDs, importID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMyActiveTrackShares = `-- name: ListMyActiveTrackShares :many
+SELECT id, token, track_id, owner_id, created_at, expires_at, revoked_at
+FROM track_shares
+WHERE owner_id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListMyActiveTrackShares(ctx context.Context, ownerID string) ([]TrackShare, error) {
+	rows, err := q.db.Query(ctx, listMyActiveTrackShares, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TrackShare{}
+	for rows.Next() {
+		var i TrackShare
+		if err := rows.Scan(
+			&i.ID,
+			&i.Token,
+			&i.TrackID,
+			&i.OwnerID,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMyPendingOrRecentImports = `-- name: ListMyPendingOrRecentImports :many
+SELECT hash,
+       owner_id,
+       inserted_at,
+       completed_at,
+       failed_at,
+       error,
+       filename,
+       byte_size,
+       cancelled_at,
+       attempts,
+       batch_id,
+       skipped_duplicate_of_ids
+FROM track_imports
+WHERE owner_id = $1
+  AND ((completed_at IS NULL AND cancelled_at IS NULL) OR
+       completed_at > NOW() - INTERVAL '1 DAY' OR
+       failed_at > NOW() - INTERVAL '1 DAY' OR
+       cancelled_at > NOW() - INTERVAL '1 DAY')
+ORDER BY inserted_at DESC
+`
+
+type ListMyPendingOrRecentImportsRow struct {
+	Hash                  []byte           `json:"hash"`
+	OwnerID               string           `json:"ownerID"`
+	InsertedAt            pgtype.Timestamp `json:"insertedAt"`
+	CompletedAt           pgtype.Timestamp `json:"completedAt"`
+	FailedAt              pgtype.Timestamp `json:"failedAt"`
+	Error                 *string          `json:"error"`
+	Filename              string           `json:"filename"`
+	ByteSize              int64            `json:"byteSize"`
+	CancelledAt           pgtype.Timestamp `json:"cancelledAt"`
+	Attempts              int32            `json:"attempts"`
+	BatchID               *int64           `json:"batchID"`
+	SkippedDuplicateOfIds []int64          `json:"skippedDuplicateOfIds"`
+}
+
+func (q *Queries) ListMyPendingOrRecentImports(ctx context.Context, ownerID string) ([]ListMyPendingOrRecentImportsRow, error) {
+	rows, err := q.db.Query(ctx, listMyPendingOrRecentImports, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMyPendingOrRecentImportsRow{}
+	for rows.Next() {
+		var i ListMyPendingOrRecentImportsRow
+		if err := rows.Scan(
+			&i.Hash,
+			&i.OwnerID,
+			&i.InsertedAt,
+			&i.CompletedAt,
+			&i.FailedAt,
+			&i.Error,
+			&i.Filename,
+			&i.ByteSize,
+			&i.CancelledAt,
+			&i.Attempts,
+			&i.BatchID,
+			&i.SkippedDuplicateOfIds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMyTagCounts = `-- name: ListMyTagCounts :many
+SELECT tag, COUNT(*) AS count
+FROM track_tags
+WHERE owner_id = $1
+GROUP BY tag
+ORDER BY tag
+`
+
+type ListMyTagCountsRow struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+func (q *Queries) ListMyTagCounts(ctx context.Context, ownerID string) ([]ListMyTagCountsRow, error) {
+	rows, err := q.db.Query(ctx, listMyTagCounts, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMyTagCountsRow{}
+	for rows.Next() {
+		var i ListMyTagCountsRow
+		if err := rows.Scan(&i.Tag, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMyTrackDuplicates = `-- name: ListMyTrackDuplicates :many
+SELECT d.track_id,
+       d.duplicate_of_id,
+       d.frechet_meters,
+       d.detected_at,
+       t.name AS track_name,
+       o.name AS duplicate_of_name
+FROM track_duplicates d
+         JOIN tracks t ON t.id = d.track_id
+         JOIN tracks o ON o.id = d.duplicate_of_id
+WHERE d.owner_id = $1
+  AND d.dismissed_at IS NULL
+ORDER BY d.detected_at DESC
+`
+
+type ListMyTrackDuplicatesRow struct {
+	TrackID         int64            `json:"trackID"`
+	DuplicateOfID   int64            `json:"duplicateOfID"`
+	FrechetMeters   float64          `json:"frechetMeters"`
+	DetectedAt      pgtype.Timestamp `json:"detectedAt"`
+	TrackName       *string          `json:"trackName"`
+	DuplicateOfName *string          `json:"duplicateOfName"`
+}
+
+func (q *Queries) ListMyTrackDuplicates(ctx context.Context, ownerID string) ([]ListMyTrackDuplicatesRow, error) {
+	rows, err := q.db.Query(ctx, listMyTrackDuplicates, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMyTrackDuplicatesRow{}
+	for rows.Next() {
+		var i ListMyTrackDuplicatesRow
+		if err := rows.Scan(
+			&i.TrackID,
+			&i.DuplicateOfID,
+			&i.FrechetMeters,
+			&i.DetectedAt,
+			&i.TrackName,
+			&i.DuplicateOfName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPeaksInBounds = `-- name: ListPeaksInBounds :many
+SELECT id, dataset, name, lng, lat, elevation_meters
+FROM peaks
+WHERE lat BETWEEN $1::float8 AND $2::float8
+  AND lng BETWEEN $3::float8 AND $4::float8
+`
+
+type ListPeaksInBoundsParams struct {
+	MinLat float64 `json:"minLat"`
+	MaxLat float64 `json:"maxLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLng float64 `json:"maxLng"`
+}
+
+func (q *Queries) ListPeaksInBounds(ctx context.Context, arg ListPeaksInBoundsParams) ([]Peak, error) {
+	rows, err := q.db.Query(ctx, listPeaksInBounds,
+		arg.MinLat,
+		arg.MaxLat,
+		arg.MinLng,
+		arg.MaxLng,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Peak{}
+	for rows.Next() {
+		var i Peak
+		if err := rows.Scan(
+			&i.ID,
+			&i.Dataset,
+			&i.Name,
+			&i.Lng,
+			&i.Lat,
+			&i.ElevationMeters,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPrivacyZones = `-- name: ListPrivacyZones :many
+SELECT id, user_id, name, lng, lat, radius_meters
+FROM privacy_zones
+WHERE user_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListPrivacyZones(ctx context.Context, userID string) ([]PrivacyZone, error) {
+	rows, err := q.db.Query(ctx, listPrivacyZones, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []PrivacyZone{}
+	for rows.Next() {
+		var i PrivacyZone
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.Lng,
+			&i.Lat,
+			&i.RadiusMeters,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagsForTracks = `-- name: ListTagsForTracks :many
+SELECT track_id, tag
+FROM track_tags
+WHERE track_id = ANY ($1::bigint[])
+ORDER BY track_id, tag
+`
+
+type ListTagsForTracksRow struct {
+	TrackID int64  `json:"trackID"`
+	Tag     string `json:"tag"`
+}
+
+func (q *Queries) ListTagsForTracks(ctx context.Context, trackIds []int64) ([]ListTagsForTracksRow, error) {
+	rows, err := q.db.Query(ctx, listTagsForTracks, trackIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTagsForTracksRow{}
+	for rows.Next() {
+		var i ListTagsForTracksRow
+		if err := rows.Scan(&i.TrackID, &i.Tag); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrackIDsAfter = `-- name: ListTrackIDsAfter :many
+SELECT id
+FROM tracks
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListTrackIDsAfterParams struct {
+	ID    int64 `json:"id"`
+	Limit int32 `json:"limit"`
+}
+
+func (q *Queries) ListTrackIDsAfter(ctx context.Context, arg ListTrackIDsAfterParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listTrackIDsAfter, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrackImportBatchImports = `-- name: ListTrackImportBatchImports :many
+SELECT hash,
+       owner_id,
+       inserted_at,
+       completed_at,
+       failed_at,
+       error,
+       filename,
+       byte_size,
+       cancelled_at,
+       attempts,
+       batch_id,
+       skipped_duplicate_of_ids
+FROM track_imports
+WHERE batch_id = $1
+ORDER BY id
+`
+
+type ListTrackImportBatchImportsRow struct {
+	Hash                  []byte           `json:"hash"`
+	OwnerID               string           `json:"ownerID"`
+	InsertedAt            pgtype.Timestamp `json:"insertedAt"`
+	CompletedAt           pgtype.Timestamp `json:"completedAt"`
+	FailedAt              pgtype.Timestamp `json:"failedAt"`
+	Error                 *string          `json:"error"`
+	Filename              string           `json:"filename"`
+	ByteSize              int64            `json:"byteSize"`
+	CancelledAt           pgtype.Timestamp `json:"cancelledAt"`
+	Attempts              int32            `json:"attempts"`
+	BatchID               *int64           `json:"batchID"`
+	SkippedDuplicateOfIds []int64          `json:"skippedDuplicateOfIds"`
+}
+
+func (q *Queries) ListTrackImportBatchImports(ctx context.Context, batchID *int64) ([]ListTrackImportBatchImportsRow, error) {
+	rows, err := q.db.Query(ctx, listTrackImportBatchImports, batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTrackImportBatchImportsRow{}
+	for rows.Next() {
+		var i ListTrackImportBatchImportsRow
+		if err := rows.Scan(
+			&i.Hash,
+			&i.OwnerID,
+			&i.InsertedAt,
+			&i.CompletedAt,
+			&i.FailedAt,
+			&i.Error,
+			&i.Filename,
+			&i.ByteSize,
+			&i.CancelledAt,
+			&i.Attempts,
+			&i.BatchID,
+			&i.SkippedDuplicateOfIds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrackImportHashes = `-- name: ListTrackImportHashes :many
+SELECT hash
+FROM track_imports
+WHERE id = ANY ($1::bigint[])
+ORDER BY id
+`
+
+func (q *Queries) ListTrackImportHashes(ctx context.Context, ids []int64) ([][]byte, error) {
+	rows, err := q.db.Query(ctx, listTrackImportHashes, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := [][]byte{}
+	for rows.Next() {
+		var hash []byte
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		items = append(items, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrackMovingTimeSamples = `-- name: ListTrackMovingTimeSamples :many
+SELECT (geojson -> 'properties' ->> 'movingSecs')::float8   AS moving_secs,
+       (geojson -> 'properties' ->> 'naismithSecs')::float8 AS naismith_secs,
+       (geojson -> 'properties' ->> 'toblerSecs')::float8   AS tobler_secs
+FROM tracks
+WHERE owner_id = $1
+  AND (activity_type IS NULL OR activity_type = 'hike')
+  AND geojson -> 'properties' ->> 'movingSecs' IS NOT NULL
+  AND geojson -> 'properties' ->> 'naismithSecs' IS NOT NULL
+  AND geojson -> 'properties' ->> 'toblerSecs' IS NOT NULL
+ORDER BY time DESC
+LIMIT 200
+`
+
+type ListTrackMovingTimeSamplesRow struct {
+	MovingSecs   float64 `json:"movingSecs"`
+	NaismithSecs float64 `json:"naismithSecs"`
+	ToblerSecs   float64 `json:"toblerSecs"`
+}
+
+func (q *Queries) ListTrackMovingTimeSamples(ctx context.Context, ownerID *string) ([]ListTrackMovingTimeSamplesRow, error) {
+	rows, err := q.db.Query(ctx, listTrackMovingTimeSamples, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTrackMovingTimeSamplesRow{}
+	for rows.Next() {
+		var i ListTrackMovingTimeSamplesRow
+		if err := rows.Scan(&i.MovingSecs, &i.NaismithSecs, &i.ToblerSecs); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrackSummits = `-- name: ListTrackSummits :many
+SELECT p.id, p.dataset, p.name, p.lng, p.lat, p.elevation_meters, ts.visited_at
+FROM track_summits ts
+         JOIN peaks p ON p.id = ts.peak_id
+WHERE ts.track_id = $1
+ORDER BY ts.visited_at, p.name
+`
+
+type ListTrackSummitsRow struct {
+	Peak      Peak               `json:"peak"`
+	VisitedAt pgtype.Timestamptz `json:"visitedAt"`
+}
+
+func (q *Queries) ListTrackSummits(ctx context.Context, trackID int64) ([]ListTrackSummitsRow, error) {
+	rows, err := q.db.Query(ctx, listTrackSummits, trackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTrackSummitsRow{}
+	for rows.Next() {
+		var i ListTrackSummitsRow
+		if err := rows.Scan(
+			&i.Peak.ID,
+			&i.Peak.Dataset,
+			&i.Peak.Name,
+			&i.Peak.Lng,
+			&i.Peak.Lat,
+			&i.Peak.ElevationMeters,
+			&i.VisitedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrackUploadChunks = `-- name: ListTrackUploadChunks :many
+SELECT upload_id, start_offset, size
+FROM track_upload_chunks
+WHERE upload_id = $1
+ORDER BY start_offset
+`
+
+func (q *Queries) ListTrackUploadChunks(ctx context.Context, uploadID int64) ([]TrackUploadChunk, error) {
+	rows, err := q.db.Query(ctx, listTrackUploadChunks, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TrackUploadChunk{}
+	for rows.Next() {
+		var i TrackUploadChunk
+		if err := rows.Scan(&i.UploadID, &i.StartOffset, &i.Size); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTracks = `-- name: ListTracks :many
+SELECT t.id, t.owner_id, t.name, t.upload_time, t.time, t.geojson, t.import_id, t.original_geojson, t.activity_type, t.timezone
+FROM tracks t
+         LEFT JOIN collection_tracks ct
+                   ON ct.track_id = t.id AND ct.collection_id = $1
+WHERE t.owner_id = $2
+  AND ($1::bigint IS NULL OR ct.collection_id IS NOT NULL)
+  AND (cardinality($3::text[]) = 0 OR
+       CASE
+           WHEN $4::bool THEN
+               (SELECT COUNT(*) FROM track_tags tt WHERE tt.track_id = t.id AND tt.tag = ANY ($3::text[])) =
+               cardinality($3::text[])
+           ELSE
+               EXISTS(SELECT 1 FROM track_tags tt WHERE tt.track_id = t.id AND tt.tag = ANY ($3::text[]))
+           END)
+ORDER BY CASE WHEN $5::bool THEN ct.position END, t.time DESC
+`
+
+type ListTracksParams struct {
+	CollectionID    *int64   `json:"collectionID"`
+	OwnerID         *string  `json:"ownerID"`
+	Tags            []string `json:"tags"`
+	MatchAllTags    bool     `json:"matchAllTags"`
+	OrderByPosition bool     `json:"orderByPosition"`
+}
+
+func (q *Queries) ListTracks(ctx context.Context, arg ListTracksParams) ([]Track, error) {
+	rows, err := q.db.Query(ctx, listTracks,
+		arg.CollectionID,
+		arg.OwnerID,
+		arg.Tags,
+		arg.MatchAllTags,
+		arg.OrderByPosition,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Track{}
+	for rows.Next() {
+		var i Track
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.UploadTime,
+			&i.Time,
+			&i.Geojson,
+			&i.ImportID,
+			&i.OriginalGeojson,
+			&i.ActivityType,
+			&i.Timezone,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTracksMissingEstimates = `-- name: ListTracksMissingEstimates :many
+SELECT id, geojson
+FROM tracks
+WHERE (activity_type IS NULL OR activity_type IN ('hike', 'run'))
+  AND geojson -> 'properties' -> 'naismithSecs' IS NULL
+  AND geojson -> 'properties' -> 'coordinateProperties' -> 'elevationMeters' IS NOT NULL
+  AND id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListTracksMissingEstimatesParams struct {
+	ID    int64 `json:"id"`
+	Limit int32 `json:"limit"`
+}
+
+type ListTracksMissingEstimatesRow struct {
+	ID      int64           `json:"id"`
+	Geojson geojson.Feature `json:"geojson"`
+}
+
+func (q *Queries) ListTracksMissingEstimates(ctx context.Context, arg ListTracksMissingEstimatesParams) ([]ListTracksMissingEstimatesRow, error) {
+	rows, err := q.db.Query(ctx, listTracksMissingEstimates, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTracksMissingEstimatesRow{}
+	for rows.Next() {
+		var i ListTracksMissingEstimatesRow
+		if err := rows.Scan(&i.ID, &i.Geojson); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTracksMissingTimezone = `-- name: ListTracksMissingTimezone :many
+SELECT id, geojson
+FROM tracks
+WHERE timezone IS NULL
+  AND id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListTracksMissingTimezoneParams struct {
+	ID    int64 `json:"id"`
+	Limit int32 `json:"limit"`
+}
+
+type ListTracksMissingTimezoneRow struct {
+	ID      int64           `json:"id"`
+	Geojson geojson.Feature `json:"geojson"`
+}
+
+func (q *Queries) ListTracksMissingTimezone(ctx context.Context, arg ListTracksMissingTimezoneParams) ([]ListTracksMissingTimezoneRow, error) {
+	rows, err := q.db.Query(ctx, listTracksMissingTimezone, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTracksMissingTimezoneRow{}
+	for rows.Next() {
+		var i ListTracksMissingTimezoneRow
+		if err := rows.Scan(&i.ID, &i.Geojson); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnmovedTrackImports = `-- name: ListUnmovedTrackImports :many
+SELECT id, hash, data
+FROM track_imports
+WHERE data IS NOT NULL
+ORDER BY id
+LIMIT $1
+`
+
+type ListUnmovedTrackImportsRow struct {
+	ID   int64  `json:"id"`
+	Hash []byte `json:"hash"`
+	Data []byte `json:"data"`
+}
+
+func (q *Queries) ListUnmovedTrackImports(ctx context.Context, limit int32) ([]ListUnmovedTrackImportsRow, error) {
+	rows, err := q.db.Query(ctx, listUnmovedTrackImports, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUnmovedTrackImportsRow{}
+	for rows.Next() {
+		var i ListUnmovedTrackImportsRow
+		if err := rows.Scan(&i.ID, &i.Hash, &i.Data); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event_id, event, attempt, status_code, error, duration_ms, succeeded, attempted_at
+FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY attempted_at DESC
+LIMIT 100
+`
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, webhookID int64) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WebhookDelivery{}
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventID,
+			&i.Event,
+			&i.Attempt,
+			&i.StatusCode,
+			&i.Error,
+			&i.DurationMs,
+			&i.Succeeded,
+			&i.AttemptedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooks = `-- name: ListWebhooks :many
+SELECT id, owner_id, url, secret, events, created_at
+FROM webhooks
+WHERE owner_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListWebhooks(ctx context.Context, ownerID string) ([]Webhook, error) {
+	rows, err := q.db.Query(ctx, listWebhooks, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Webhook{}
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooksForEvent = `-- name: ListWebhooksForEvent :many
+SELECT id, owner_id, url, secret, events, created_at
+FROM webhooks
+WHERE owner_id = $1
+  AND $2::text = ANY (events)
+`
+
+type ListWebhooksForEventParams struct {
+	OwnerID string `json:"ownerID"`
+	Event   string `json:"event"`
+}
+
+func (q *Queries) ListWebhooksForEvent(ctx context.Context, arg ListWebhooksForEventParams) ([]Webhook, error) {
+	rows, err := q.db.Query(ctx, listWebhooksForEvent, arg.OwnerID, arg.Event)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Webhook{}
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockCollection = `-- name: LockCollection :exec
+SELECT id
+FROM collections
+WHERE id = $1
+    FOR UPDATE
+`
+
+func (q *Queries) LockCollection(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, lockCollection, id)
+	return err
+}
+
+const lockTrackUpload = `-- name: LockTrackUpload :exec
+SELECT id
+FROM track_uploads
+WHERE id = $1
+    FOR UPDATE
+`
+
+func (q *Queries) LockTrackUpload(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, lockTrackUpload, id)
+	return err
+}
+
+const lockTrackUploadsOfOwner = `-- name: LockTrackUploadsOfOwner :exec
+SELECT pg_advisory_xact_lock(hashtext('track_uploads:' || $1::text))
+`
+
+func (q *Queries) LockTrackUploadsOfOwner(ctx context.Context, ownerID string) error {
+	_, err := q.db.Exec(ctx, lockTrackUploadsOfOwner, ownerID)
+	return err
+}
+
+const makeRoomAfterCollectionTrack = `-- name: MakeRoomAfterCollectionTrack :exec
+UPDATE collection_tracks ct
+SET position = ct.position + 1
+FROM collection_tracks src
+WHERE src.track_id = $1
+  AND ct.collection_id = src.collection_id
+  AND ct.position > src.position
+`
+
+func (q *Queries) MakeRoomAfterCollectionTrack(ctx context.Context, trackID int64) error {
+	_, err := q.db.Exec(ctx, makeRoomAfterCollectionTrack, trackID)
+	return err
+}
+
+const markTrackImportBatchFailed = `-- name: MarkTrackImportBatchFailed :execrows
+UPDATE track_import_batches
+SET failed_at = NOW(),
+    error     = $2
+WHERE id = $1
+  AND unpacked_at IS NULL
+  AND failed_at IS NULL
+`
+
+type MarkTrackImportBatchFailedParams struct {
+	ID    int64   `json:"id"`
+	Error *string `json:"error"`
+}
+
+func (q *Queries) MarkTrackImportBatchFailed(ctx context.Context, arg MarkTrackImportBatchFailedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, markTrackImportBatchFailed, arg.ID, arg.Error)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markTrackImportCompleted = `-- name: MarkTrackImportCompleted :execrows
+UPDATE track_imports
+SET completed_at = NOW(),
+    checkpoint   = NULL
+WHERE id = $1
+  AND cancelled_at IS NULL
+`
+
+func (q *Queries) MarkTrackImportCompleted(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, markTrackImportCompleted, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markTrackImportFailed = `-- name: MarkTrackImportFailed :execrows
 UPDATE track_imports
 SET failed_at = NOW(),
     error     = $2
 WHERE id = $1
+  AND cancelled_at IS NULL
 `
 
 type MarkTrackImportFailedParams struct {
@@ -351,11 +2332,480 @@ type MarkTrackImportFailedParams struct {
 	Error *string `json:"error"`
 }
 
-func (q *Queries) MarkTrackImportFailed(ctx context.Context, arg MarkTrackImportFailedParams) error {
-	_, err := q.db.Exec(ctx, markTrackImportFailed, arg.ID, arg.Error)
+func (q *Queries) MarkTrackImportFailed(ctx context.Context, arg MarkTrackImportFailedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, markTrackImportFailed, arg.ID, arg.Error)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const nearestGazetteerPlace = `-- name: NearestGazetteerPlace :one
+SELECT name, kind, lng, lat
+FROM gazetteer_places
+WHERE lat BETWEEN $1::float8 AND $2::float8
+  AND lng BETWEEN $3::float8 AND $4::float8
+  AND ($5::text IS NULL OR kind = $5)
+ORDER BY ((lng - $6::float8) * cos(radians($7::float8))) ^ 2 + (lat - $7::float8) ^ 2
+LIMIT 1
+`
+
+type NearestGazetteerPlaceParams struct {
+	MinLat float64 `json:"minLat"`
+	MaxLat float64 `json:"maxLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLng float64 `json:"maxLng"`
+	Kind   *string `json:"kind"`
+	Lng    float64 `json:"lng"`
+	Lat    float64 `json:"lat"`
+}
+
+type NearestGazetteerPlaceRow struct {
+	Name string  `json:"name"`
+	Kind string  `json:"kind"`
+	Lng  float64 `json:"lng"`
+	Lat  float64 `json:"lat"`
+}
+
+func (q *Queries) NearestGazetteerPlace(ctx context.Context, arg NearestGazetteerPlaceParams) (NearestGazetteerPlaceRow, error) {
+	row := q.db.QueryRow(ctx, nearestGazetteerPlace,
+		arg.MinLat,
+		arg.MaxLat,
+		arg.MinLng,
+		arg.MaxLng,
+		arg.Kind,
+		arg.Lng,
+		arg.Lat,
+	)
+	var i NearestGazetteerPlaceRow
+	err := row.Scan(
+		&i.Name,
+		&i.Kind,
+		&i.Lng,
+		&i.Lat,
+	)
+	return i, err
+}
+
+const notifyTrackImportEvent = `-- name: NotifyTrackImportEvent :exec
+SELECT pg_notify('track_import_events', $1::text)
+`
+
+func (q *Queries) NotifyTrackImportEvent(ctx context.Context, payload string) error {
+	_, err := q.db.Exec(ctx, notifyTrackImportEvent, payload)
+	return err
+}
+
+const removeCollectionTrack = `-- name: RemoveCollectionTrack :execrows
+DELETE
+FROM collection_tracks
+WHERE collection_id = $1
+  AND track_id = $2
+`
+
+type RemoveCollectionTrackParams struct {
+	CollectionID int64 `json:"collectionID"`
+	TrackID      int64 `json:"trackID"`
+}
+
+func (q *Queries) RemoveCollectionTrack(ctx context.Context, arg RemoveCollectionTrackParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeCollectionTrack, arg.CollectionID, arg.TrackID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const removeTrackTag = `-- name: RemoveTrackTag :execrows
+DELETE
+FROM track_tags
+WHERE track_id = $1
+  AND tag = $2
+`
+
+type RemoveTrackTagParams struct {
+	TrackID int64  `json:"trackID"`
+	Tag     string `json:"tag"`
+}
+
+func (q *Queries) RemoveTrackTag(ctx context.Context, arg RemoveTrackTagParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeTrackTag, arg.TrackID, arg.Tag)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const resetTrackImportBatchFailure = `-- name: ResetTrackImportBatchFailure :exec
+UPDATE track_import_batches
+SET failed_at       = NULL,
+    error           = NULL,
+    skip_duplicates = $2
+WHERE id = $1
+`
+
+type ResetTrackImportBatchFailureParams struct {
+	ID             int64 `json:"id"`
+	SkipDuplicates bool  `json:"skipDuplicates"`
+}
+
+func (q *Queries) ResetTrackImportBatchFailure(ctx context.Context, arg ResetTrackImportBatchFailureParams) error {
+	_, err := q.db.Exec(ctx, resetTrackImportBatchFailure, arg.ID, arg.SkipDuplicates)
+	return err
+}
+
+const resetTrackImportFailure = `-- name: ResetTrackImportFailure :execrows
+UPDATE track_imports
+SET failed_at = NULL,
+    error     = NULL,
+    attempts  = 0
+WHERE id = $1
+  AND failed_at IS NOT NULL
+  AND cancelled_at IS NULL
+`
+
+func (q *Queries) ResetTrackImportFailure(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, resetTrackImportFailure, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreTrackOriginal = `-- name: RestoreTrackOriginal :exec
+UPDATE tracks
+SET geojson          = original_geojson,
+    time             = $2,
+    original_geojson = NULL
+WHERE id = $1
+  AND original_geojson IS NOT NULL
+`
+
+type RestoreTrackOriginalParams struct {
+	ID   int64              `json:"id"`
+	Time pgtype.Timestamptz `json:"time"`
+}
+
+func (q *Queries) RestoreTrackOriginal(ctx context.Context, arg RestoreTrackOriginalParams) error {
+	_, err := q.db.Exec(ctx, restoreTrackOriginal, arg.ID, arg.Time)
+	return err
+}
+
+const revokeTrackShare = `-- name: RevokeTrackShare :execrows
+UPDATE track_shares
+SET revoked_at = NOW()
+WHERE token = $1
+  AND owner_id = $2
+  AND revoked_at IS NULL
+`
+
+type RevokeTrackShareParams struct {
+	Token   string `json:"token"`
+	OwnerID string `json:"ownerID"`
+}
+
+func (q *Queries) RevokeTrackShare(ctx context.Context, arg RevokeTrackShareParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeTrackShare, arg.Token, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const searchTracks = `-- name: SearchTracks :many
+SELECT t.id, t.owner_id, t.name, t.upload_time, t.time, t.geojson, t.import_id, t.original_geojson, t.activity_type, t.timezone, ts_rank_cd(s.document, query)::real AS rank
+FROM tracks t
+         JOIN track_search s ON s.track_id = t.id,
+     websearch_to_tsquery('english', $1::text) query
+WHERE t.owner_id = $2
+  AND s.document @@ query
+ORDER BY rank DESC, t.time DESC
+LIMIT $4 OFFSET $3
+`
+
+type SearchTracksParams struct {
+	Query      string  `json:"query"`
+	OwnerID    *string `json:"ownerID"`
+	PageOffset int32   `json:"pageOffset"`
+	PageLimit  int32   `json:"pageLimit"`
+}
+
+type SearchTracksRow struct {
+	Track Track   `json:"track"`
+	Rank  float32 `json:"rank"`
+}
+
+func (q *Queries) SearchTracks(ctx context.Context, arg SearchTracksParams) ([]SearchTracksRow, error) {
+	rows, err := q.db.Query(ctx, searchTracks,
+		arg.Query,
+		arg.OwnerID,
+		arg.PageOffset,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchTracksRow{}
+	for rows.Next() {
+		var i SearchTracksRow
+		if err := rows.Scan(
+			&i.Track.ID,
+			&i.Track.OwnerID,
+			&i.Track.Name,
+			&i.Track.UploadTime,
+			&i.Track.Time,
+			&i.Track.Geojson,
+			&i.Track.ImportID,
+			&i.Track.OriginalGeojson,
+			&i.Track.ActivityType,
+			&i.Track.Timezone,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setCollectionTrackPosition = `-- name: SetCollectionTrackPosition :exec
+UPDATE collection_tracks
+SET position = $3
+WHERE collection_id = $1
+  AND track_id = $2
+`
+
+type SetCollectionTrackPositionParams struct {
+	CollectionID int64 `json:"collectionID"`
+	TrackID      int64 `json:"trackID"`
+	Position     int32 `json:"position"`
+}
+
+func (q *Queries) SetCollectionTrackPosition(ctx context.Context, arg SetCollectionTrackPositionParams) error {
+	_, err := q.db.Exec(ctx, setCollectionTrackPosition, arg.CollectionID, arg.TrackID, arg.Position)
+	return err
+}
+
+const setTrackGeojson = `-- name: SetTrackGeojson :exec
+UPDATE tracks
+SET geojson = $2
+WHERE id = $1
+`
+
+type SetTrackGeojsonParams struct {
+	ID      int64           `json:"id"`
+	Geojson geojson.Feature `json:"geojson"`
+}
+
+func (q *Queries) SetTrackGeojson(ctx context.Context, arg SetTrackGeojsonParams) error {
+	_, err := q.db.Exec(ctx, setTrackGeojson, arg.ID, arg.Geojson)
+	return err
+}
+
+const setTrackImportAttempts = `-- name: SetTrackImportAttempts :exec
+UPDATE track_imports
+SET attempts = $2
+WHERE id = $1
+`
+
+type SetTrackImportAttemptsParams struct {
+	ID       int64 `json:"id"`
+	Attempts int32 `json:"attempts"`
+}
+
+func (q *Queries) SetTrackImportAttempts(ctx context.Context, arg SetTrackImportAttemptsParams) error {
+	_, err := q.db.Exec(ctx, setTrackImportAttempts, arg.ID, arg.Attempts)
+	return err
+}
+
+const setTrackImportBatchUnpacked = `-- name: SetTrackImportBatchUnpacked :execrows
+UPDATE track_import_batches
+SET unpacked_at      = NOW(),
+    skipped          = $2,
+    duplicate_of_ids = $3
+WHERE id = $1
+  AND unpacked_at IS NULL
+  AND failed_at IS NULL
+`
+
+type SetTrackImportBatchUnpackedParams struct {
+	ID             int64           `json:"id"`
+	Skipped        json.RawMessage `json:"skipped"`
+	DuplicateOfIds []int64         `json:"duplicateOfIds"`
+}
+
+func (q *Queries) SetTrackImportBatchUnpacked(ctx context.Context, arg SetTrackImportBatchUnpackedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setTrackImportBatchUnpacked, arg.ID, arg.Skipped, arg.DuplicateOfIds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setTrackImportBlobKey = `-- name: SetTrackImportBlobKey :execrows
+UPDATE track_imports
+SET blob_key = $2,
+    data     = NULL
+WHERE id = $1
+  AND data IS NOT NULL
+`
+
+type SetTrackImportBlobKeyParams struct {
+	ID      int64   `json:"id"`
+	BlobKey *string `json:"blobKey"`
+}
+
+func (q *Queries) SetTrackImportBlobKey(ctx context.Context, arg SetTrackImportBlobKeyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setTrackImportBlobKey, arg.ID, arg.BlobKey)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setTrackImportCheckpoint = `-- name: SetTrackImportCheckpoint :exec
+UPDATE track_imports
+SET checkpoint = $2
+WHERE id = $1
+`
+
+type SetTrackImportCheckpointParams struct {
+	ID         int64           `json:"id"`
+	Checkpoint json.RawMessage `json:"checkpoint"`
+}
+
+func (q *Queries) SetTrackImportCheckpoint(ctx context.Context, arg SetTrackImportCheckpointParams) error {
+	_, err := q.db.Exec(ctx, setTrackImportCheckpoint, arg.ID, arg.Checkpoint)
+	return err
+}
+
+const setTrackImportDownloaded = `-- name: SetTrackImportDownloaded :execrows
+UPDATE track_imports
+SET filename     = $2,
+    blob_key     = $3,
+    byte_size    = $4,
+    content_hash = $5
+WHERE id = $1
+  AND cancelled_at IS NULL
+`
+
+type SetTrackImportDownloadedParams struct {
+	ID          int64   `json:"id"`
+	Filename    string  `json:"filename"`
+	BlobKey     *string `json:"blobKey"`
+	ByteSize    int64   `json:"byteSize"`
+	ContentHash []byte  `json:"contentHash"`
+}
+
+func (q *Queries) SetTrackImportDownloaded(ctx context.Context, arg SetTrackImportDownloadedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setTrackImportDownloaded,
+		arg.ID,
+		arg.Filename,
+		arg.BlobKey,
+		arg.ByteSize,
+		arg.ContentHash,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setTrackImportDuplicateOf = `-- name: SetTrackImportDuplicateOf :exec
+UPDATE track_imports
+SET duplicate_of_id = $2
+WHERE id = $1
+`
+
+type SetTrackImportDuplicateOfParams struct {
+	ID            int64  `json:"id"`
+	DuplicateOfID *int64 `json:"duplicateOfID"`
+}
+
+func (q *Queries) SetTrackImportDuplicateOf(ctx context.Context, arg SetTrackImportDuplicateOfParams) error {
+	_, err := q.db.Exec(ctx, setTrackImportDuplicateOf, arg.ID, arg.DuplicateOfID)
+	return err
+}
+
+const setTrackImportJob = `-- name: SetTrackImportJob :exec
+UPDATE track_imports
+SET job_id = $2
+WHERE id = $1
+`
+
+type SetTrackImportJobParams struct {
+	ID    int64  `json:"id"`
+	JobID *int64 `json:"jobID"`
+}
+
+func (q *Queries) SetTrackImportJob(ctx context.Context, arg SetTrackImportJobParams) error {
+	_, err := q.db.Exec(ctx, setTrackImportJob, arg.ID, arg.JobID)
+	return err
+}
+
+const setTrackImportSkippedDuplicates = `-- name: SetTrackImportSkippedDuplicates :exec
+UPDATE track_imports
+SET skipped_duplicate_of_ids = $2
+WHERE id = $1
+`
+
+type SetTrackImportSkippedDuplicatesParams struct {
+	ID                    int64   `json:"id"`
+	SkippedDuplicateOfIds []int64 `json:"skippedDuplicateOfIds"`
+}
+
+func (q *Queries) SetTrackImportSkippedDuplicates(ctx context.Context, arg SetTrackImportSkippedDuplicatesParams) error {
+	_, err := q.db.Exec(ctx, setTrackImportSkippedDuplicates, arg.ID, arg.SkippedDuplicateOfIds)
+	return err
+}
+
+const setTrackTimezone = `-- name: SetTrackTimezone :exec
+UPDATE tracks
+SET timezone = $2
+WHERE id = $1
+`
+
+type SetTrackTimezoneParams struct {
+	ID       int64   `json:"id"`
+	Timezone *string `json:"timezone"`
+}
+
+func (q *Queries) SetTrackTimezone(ctx context.Context, arg SetTrackTimezoneParams) error {
+	_, err := q.db.Exec(ctx, setTrackTimezone, arg.ID, arg.Timezone)
 	return err
 }
 
+const setTrackUploadReceived = `-- name: SetTrackUploadReceived :one
+UPDATE track_uploads
+SET received = $2
+WHERE id = $1
+RETURNING id, owner_id, filename, size, received, created_at, expires_at
+`
+
+type SetTrackUploadReceivedParams struct {
+	ID       int64 `json:"id"`
+	Received int64 `json:"received"`
+}
+
+func (q *Queries) SetTrackUploadReceived(ctx context.Context, arg SetTrackUploadReceivedParams) (TrackUpload, error) {
+	row := q.db.QueryRow(ctx, setTrackUploadReceived, arg.ID, arg.Received)
+	var i TrackUpload
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.Size,
+		&i.Received,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
 const setUnitSettings = `-- name: SetUnitSettings :exec
 INSERT INTO unit_settings (user_id, value)
 VALUES ($1, $2)
@@ -372,3 +2822,107 @@ func (q *Queries) SetUnitSettings(ctx context.Context, arg SetUnitSettingsParams
 	_, err := q.db.Exec(ctx, setUnitSettings, arg.UserID, arg.Value)
 	return err
 }
+
+const trimTrack = `-- name: TrimTrack :exec
+UPDATE tracks
+SET original_geojson = COALESCE(original_geojson, geojson),
+    geojson          = $2,
+    time             = $3
+WHERE id = $1
+`
+
+type TrimTrackParams struct {
+	ID      int64              `json:"id"`
+	Geojson geojson.Feature    `json:"geojson"`
+	Time    pgtype.Timestamptz `json:"time"`
+}
+
+func (q *Queries) TrimTrack(ctx context.Context, arg TrimTrackParams) error {
+	_, err := q.db.Exec(ctx, trimTrack, arg.ID, arg.Geojson, arg.Time)
+	return err
+}
+
+const updateCollection = `-- name: UpdateCollection :one
+UPDATE collections
+SET name           = $2,
+    description    = $3,
+    cover_track_id = $4
+WHERE id = $1
+RETURNING id, owner_id, name, description, cover_track_id, created_at
+`
+
+type UpdateCollectionParams struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	CoverTrackID *int64 `json:"coverTrackID"`
+}
+
+func (q *Queries) UpdateCollection(ctx context.Context, arg UpdateCollectionParams) (Collection, error) {
+	row := q.db.QueryRow(ctx, updateCollection,
+		arg.ID,
+		arg.Name,
+		arg.Description,
+		arg.CoverTrackID,
+	)
+	var i Collection
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Description,
+		&i.CoverTrackID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateTrackActivityType = `-- name: UpdateTrackActivityType :exec
+UPDATE tracks
+SET activity_type    = $2,
+    geojson          = $3,
+    original_geojson = $4
+WHERE id = $1
+`
+
+type UpdateTrackActivityTypeParams struct {
+	ID              int64            `json:"id"`
+	ActivityType    *string          `json:"activityType"`
+	Geojson         geojson.Feature  `json:"geojson"`
+	OriginalGeojson *geojson.Feature `json:"originalGeojson"`
+}
+
+func (q *Queries) UpdateTrackActivityType(ctx context.Context, arg UpdateTrackActivityTypeParams) error {
+	_, err := q.db.Exec(ctx, updateTrackActivityType,
+		arg.ID,
+		arg.ActivityType,
+		arg.Geojson,
+		arg.OriginalGeojson,
+	)
+	return err
+}
+
+const updateTrackGeojson = `-- name: UpdateTrackGeojson :exec
+UPDATE tracks
+SET geojson          = $2,
+    time             = $3,
+    original_geojson = $4
+WHERE id = $1
+`
+
+type UpdateTrackGeojsonParams struct {
+	ID              int64              `json:"id"`
+	Geojson         geojson.Feature    `json:"geojson"`
+	Time            pgtype.Timestamptz `json:"time"`
+	OriginalGeojson *geojson.Feature   `json:"originalGeojson"`
+}
+
+func (q *Queries) UpdateTrackGeojson(ctx context.Context, arg UpdateTrackGeojsonParams) error {
+	_, err := q.db.Exec(ctx, updateTrackGeojson,
+		arg.ID,
+		arg.Geojson,
+		arg.Time,
+		arg.OriginalGeojson,
+	)
+	return err
+}
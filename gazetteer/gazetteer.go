@@ -0,0 +1,55 @@
+package gazetteer
+
+import (
+	"context"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/analysis"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"math"
+)
+
+// Gazetteer looks up places loaded into the database, so that tracks can be
+// labelled without depending on a live geocoding service.
+type Gazetteer struct {
+	db *pgxpool.Pool
+	q  *db.Queries
+}
+
+func New(pool *pgxpool.Pool) *Gazetteer {
+	return &Gazetteer{db: pool, q: db.New(pool)}
+}
+
+func (g *Gazetteer) NearestPlace(ctx context.Context, point orb.Point, kind string, maxMeters float64) (analysis.Place, bool, error) {
+	dLat := maxMeters / analysis.MetersPerDegreeLat
+	dLng := dLat / math.Max(math.Cos(point.Lat()*math.Pi/180), 0.01)
+	params := db.NearestGazetteerPlaceParams{
+		MinLat: point.Lat() - dLat,
+		MaxLat: point.Lat() + dLat,
+		MinLng: point.Lon() - dLng,
+		MaxLng: point.Lon() + dLng,
+		Lng:    point.Lon(),
+		Lat:    point.Lat(),
+	}
+	if kind != "" {
+		params.Kind = &kind
+	}
+
+	row, err := g.q.NearestGazetteerPlace(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return analysis.Place{}, false, nil
+		}
+		return analysis.Place{}, false, err
+	}
+
+	place := analysis.Place{Name: row.Name, Kind: row.Kind, Point: orb.Point{row.Lng, row.Lat}}
+	// The bounding box is a square so the corners are further than maxMeters
+	if geo.DistanceHaversine(point, place.Point) > maxMeters {
+		return analysis.Place{}, false, nil
+	}
+	return place, true, nil
+}
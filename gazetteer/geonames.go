@@ -0,0 +1,130 @@
+package gazetteer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/analysis"
+	"github.com/dzfranklin/plantopo-api/db"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const geoNamesSource = "geonames"
+
+const loadBatchSize = 10_000
+
+// Feature codes of the GeoNames class T features treated as peaks
+var geoNamesPeakCodes = map[string]bool{
+	"PK":  true,
+	"MT":  true,
+	"HLL": true,
+}
+
+// LoadGeoNames replaces the places from GeoNames with those in a dump in the
+// tab-separated GeoNames format (such as GB.txt or allCountries.txt from
+// <https://download.geonames.org/export/dump/>). Only populated places and
+// peaks are kept. It returns the number of places loaded.
+func (g *Gazetteer) LoadGeoNames(ctx context.Context, r io.Reader) (int, error) {
+	tx, err := g.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	q := g.q.WithTx(tx)
+
+	if err := q.DeleteGazetteerSource(ctx, geoNamesSource); err != nil {
+		return 0, err
+	}
+
+	var total int
+	batch := make([]db.InsertGazetteerPlacesParams, 0, loadBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := q.InsertGazetteerPlaces(ctx, batch)
+		total += int(n)
+		batch = batch[:0]
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		place, ok, err := parseGeoNamesLine(scanner.Text())
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if !ok {
+			continue
+		}
+		batch = append(batch, place)
+		if len(batch) == loadBatchSize {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	return total, tx.Commit(ctx)
+}
+
+// parseGeoNamesLine parses a line of a GeoNames dump, returning false if it
+// isn't a kind of place we keep.
+func parseGeoNamesLine(line string) (db.InsertGazetteerPlacesParams, bool, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 17 {
+		return db.InsertGazetteerPlacesParams{}, false, fmt.Errorf("expected at least 17 fields, got %d", len(fields))
+	}
+	name := fields[1]
+	class := fields[6]
+	code := fields[7]
+
+	var kind string
+	switch {
+	case class == "P":
+		kind = analysis.PlaceKindPlace
+	case class == "T" && geoNamesPeakCodes[code]:
+		kind = analysis.PlaceKindPeak
+	default:
+		return db.InsertGazetteerPlacesParams{}, false, nil
+	}
+
+	lat, err := strconv.ParseFloat(fields[4], 64)
+	if err != nil {
+		return db.InsertGazetteerPlacesParams{}, false, fmt.Errorf("invalid latitude: %w", err)
+	}
+	lng, err := strconv.ParseFloat(fields[5], 64)
+	if err != nil {
+		return db.InsertGazetteerPlacesParams{}, false, fmt.Errorf("invalid longitude: %w", err)
+	}
+
+	// Prefer the surveyed elevation, falling back to the digital elevation
+	// model where GeoNames uses -9999 for no data
+	var elevation *float64
+	for _, field := range []string{fields[15], fields[16]} {
+		if v, err := strconv.ParseFloat(field, 64); err == nil && v != -9999 {
+			elevation = &v
+			break
+		}
+	}
+
+	return db.InsertGazetteerPlacesParams{
+		Source:          geoNamesSource,
+		Name:            name,
+		Kind:            kind,
+		Lng:             lng,
+		Lat:             lat,
+		ElevationMeters: elevation,
+	}, true, nil
+}
@@ -0,0 +1,70 @@
+package gazetteer
+
+import (
+	"context"
+	"github.com/dzfranklin/plantopo-api/analysis"
+	"github.com/dzfranklin/plantopo-api/testsupport"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const sampleGeoNames = "2656139\tBen Lawers\tBen Lawers\t\t56.54486\t-4.22139\tT\tMT\tGB\t\tSCT\tV9\t\t\t0\t1214\t1203\tEurope/London\t2012-01-18\n" +
+	"2644927\tLawers\tLawers\t\t56.52056\t-4.15278\tP\tPPL\tGB\t\tSCT\tV9\t\t\t0\t\t-9999\tEurope/London\t2012-01-18\n" +
+	"2636085\tLoch Tay\tLoch Tay\t\t56.51667\t-4.16667\tH\tLK\tGB\t\tSCT\tV9\t\t\t0\t\t105\tEurope/London\t2012-01-18\n"
+
+func TestParseGeoNamesLine(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(sampleGeoNames), "\n")
+
+	peak, ok, err := parseGeoNamesLine(lines[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Ben Lawers", peak.Name)
+	assert.Equal(t, analysis.PlaceKindPeak, peak.Kind)
+	assert.Equal(t, -4.22139, peak.Lng)
+	require.NotNil(t, peak.ElevationMeters)
+	assert.Equal(t, 1214.0, *peak.ElevationMeters)
+
+	village, ok, err := parseGeoNamesLine(lines[1])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, analysis.PlaceKindPlace, village.Kind)
+	assert.Nil(t, village.ElevationMeters)
+
+	_, ok, err = parseGeoNamesLine(lines[2])
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = parseGeoNamesLine("too\tshort")
+	assert.Error(t, err)
+}
+
+func TestLoadGeoNamesAndNearestPlace(t *testing.T) {
+	ctx := context.Background()
+	g := New(testsupport.NewDB(t))
+
+	n, err := g.LoadGeoNames(ctx, strings.NewReader(sampleGeoNames))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// Reloading replaces rather than duplicates
+	n, err = g.LoadGeoNames(ctx, strings.NewReader(sampleGeoNames))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	place, ok, err := g.NearestPlace(ctx, orb.Point{-4.2213, 56.5448}, "", 3000)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Ben Lawers", place.Name)
+
+	place, ok, err = g.NearestPlace(ctx, orb.Point{-4.2213, 56.5448}, analysis.PlaceKindPlace, 10_000)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Lawers", place.Name)
+
+	_, ok, err = g.NearestPlace(ctx, orb.Point{-4.2213, 56.5448}, analysis.PlaceKindPlace, 1000)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"github.com/dzfranklin/plantopo-api/analysis"
 	"github.com/dzfranklin/plantopo-api/authn"
+	"github.com/dzfranklin/plantopo-api/blobs"
 	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/gazetteer"
 	"github.com/dzfranklin/plantopo-api/routes"
 	"github.com/dzfranklin/plantopo-api/settings"
 	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/dzfranklin/plantopo-api/webhooks"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/joho/godotenv"
@@ -23,6 +26,8 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	// Embedded so tracks can be shown in local time whatever the host has
+	_ "time/tzdata"
 )
 
 var buildDir string
@@ -70,6 +75,14 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if len(os.Args) > 1 {
+		err := runCommand(context.Background(), pool, os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// TODO: Configure for traefik
 	// See eg <https://stackoverflow.com/questions/44190607/how-do-you-find-the-cluster-service-cidr-of-a-kubernetes-cluster>
 	var trustedProxies []string
@@ -93,19 +106,36 @@ func main() {
 
 	toGeoJSONService := tracks.NewToGeoJSONService(mustGetEnv("TO_GEOJSON_SERVICE"))
 	elevationService := analysis.NewElevationService(mustGetEnv("ELEVATION_SERVICE"))
-	analyzer := analysis.NewAnalyzer(elevationService)
+	analyzer := analysis.NewAnalyzer(elevationService, gazetteer.New(pool))
+
+	blobStore, err := newBlobStore(appEnv)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	sigintOrTerm := make(chan os.Signal, 1)
 	signal.Notify(sigintOrTerm, syscall.SIGINT, syscall.SIGTERM)
 
+	// Closed when a soft stop begins, so that long jobs can checkpoint
+	softStop := make(chan struct{})
+
 	workers := river.NewWorkers()
-	tracks.AddImportWorker(workers, pool, toGeoJSONService, analyzer)
+	tracks.AddImportWorker(workers, pool, blobStore, toGeoJSONService, analyzer, softStop)
+	tracks.AddURLImportWorker(workers, pool, blobStore)
+	tracks.AddArchiveImportWorker(workers, pool, blobStore)
+	tracks.AddUploadCleanupWorker(workers, pool, blobStore)
+	tracks.AddMoveImportDataWorker(workers, pool, blobStore)
+	webhooks.AddDeliveryWorker(workers, pool)
 
 	riverClient, err := river.NewClient[pgx.Tx](riverpgxv5.New(pool), &river.Config{
 		Queues: map[string]river.QueueConfig{
 			river.QueueDefault: {MaxWorkers: 100},
 		},
 		Workers: workers,
+		PeriodicJobs: []*river.PeriodicJob{
+			tracks.UploadCleanupJob(),
+			tracks.MoveImportDataJob(),
+		},
 	})
 	if err != nil {
 		log.Fatal(err)
@@ -117,14 +147,26 @@ func main() {
 		}
 	}()
 
-	tracksRepo := tracks.NewRepo(pool, riverClient)
+	tracksRepo := tracks.NewRepo(pool, riverClient, blobStore)
 	settingsRepo := settings.NewRepo(pool)
+	webhooksRepo := webhooks.NewRepo(pool)
+
+	importEvents := tracks.NewImportEvents(pool)
+	go importEvents.Run(context.Background())
 
 	router := routes.Router(
 		authenticator,
 		tracksRepo,
 		elevationService,
 		settingsRepo,
+		tracksRepo,
+		tracksRepo,
+		tracksRepo,
+		tracksRepo,
+		tracksRepo,
+		tracksRepo,
+		importEvents,
+		webhooksRepo,
 	)
 
 	err = router.SetTrustedProxies(trustedProxies)
@@ -143,6 +185,7 @@ func main() {
 	go func() {
 		<-sigintOrTerm
 		fmt.Printf("Received SIGINT/SIGTERM; initiating soft stop (try to wait for jobs to finish)\n")
+		close(softStop)
 
 		softStopCtx, softStopCtxCancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer softStopCtxCancel()
@@ -185,6 +228,30 @@ func main() {
 	<-riverClient.Stopped()
 }
 
+// newBlobStore picks where to keep imported files from BLOB_STORE, which is
+// either local or s3. Development defaults to local.
+func newBlobStore(appEnv string) (tracks.BlobStore, error) {
+	kind := os.Getenv("BLOB_STORE")
+	if kind == "" && appEnv == "development" {
+		kind = "local"
+	}
+	switch kind {
+	case "local":
+		return blobs.NewLocal(getEnvOr("BLOB_DIR", "data/blobs"))
+	case "s3":
+		return blobs.NewS3(blobs.S3Config{
+			Endpoint:        mustGetEnv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          mustGetEnv("S3_BUCKET"),
+			AccessKeyID:     mustGetEnv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: mustGetEnv("S3_SECRET_ACCESS_KEY"),
+			Insecure:        os.Getenv("S3_INSECURE") == "true",
+		})
+	default:
+		return nil, fmt.Errorf("BLOB_STORE must be local or s3, got %q", kind)
+	}
+}
+
 func getEnvOr(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -0,0 +1,99 @@
+// Package publicnet restricts requests made to URLs that users provide to the
+// public internet, so that they can't be used to reach internal services.
+package publicnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var ErrBlockedAddress = errors.New("blocked address")
+
+// blockedPrefixes are the address ranges that aren't public internet in
+// addition to those the netip.Addr methods detect.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// NewTransport creates a transport that refuses to connect to anything but
+// the public internet. The check is made on the address being dialed, after
+// DNS resolution and on every redirect, so it can't be bypassed with a
+// hostname that resolves to a private address.
+func NewTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if IsBlockedAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		// No proxy, as it would be the one dialed
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// CheckHost returns ErrBlockedAddress if the host is or resolves to an
+// address that isn't public. A host that doesn't resolve isn't an error, as
+// connections are checked again when they are made.
+func CheckHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if IsBlockedAddr(addr) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if IsBlockedAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, addr)
+		}
+	}
+	return nil
+}
+
+// IsBlockedAddr reports whether the address is anything but public internet,
+// such as loopback, private or link-local.
+func IsBlockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package publicnet
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/netip"
+	"testing"
+)
+
+func TestIsBlockedAddr(t *testing.T) {
+	blocked := []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "::ffff:10.0.0.1"}
+	for _, addr := range blocked {
+		assert.True(t, IsBlockedAddr(netip.MustParseAddr(addr)), addr)
+	}
+
+	allowed := []string{"8.8.8.8", "151.101.0.1", "2606:4700::1111"}
+	for _, addr := range allowed {
+		assert.False(t, IsBlockedAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	ctx := context.Background()
+	for _, host := range []string{"127.0.0.1", "localhost", "10.0.0.1", "169.254.169.254", "::1"} {
+		assert.ErrorIs(t, CheckHost(ctx, host), ErrBlockedAddress, host)
+	}
+	assert.NoError(t, CheckHost(ctx, "8.8.8.8"))
+	assert.NoError(t, CheckHost(ctx, "does-not-exist.invalid"))
+}
@@ -0,0 +1,247 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+)
+
+type CollectionsRepo interface {
+	ListMyCollections(ctx context.Context, userID string) ([]tracks.Collection, error)
+	GetCollection(ctx context.Context, id string) (tracks.Collection, error)
+	IsCollectionOwner(ctx context.Context, userID string, id string) (bool, error)
+	CreateCollection(ctx context.Context, ownerID string, input tracks.CollectionInput) (tracks.Collection, error)
+	UpdateCollection(ctx context.Context, id string, input tracks.CollectionInput) (tracks.Collection, error)
+	DeleteCollection(ctx context.Context, id string) error
+	AddToCollection(ctx context.Context, id string, trackID string) (tracks.Collection, error)
+	RemoveFromCollection(ctx context.Context, id string, trackID string) (tracks.Collection, error)
+	ReorderCollection(ctx context.Context, id string, trackIDs []string) (tracks.Collection, error)
+}
+
+func registerCollectionsRoutes(
+	r gin.IRouter,
+	tracksRepo TracksRepo,
+	repo CollectionsRepo,
+) {
+	r.GET("/collections/my", getMyCollections(repo))
+	r.POST("/collections", postCollection(repo))
+	r.GET("/collections/:id", getCollection(repo))
+	r.PUT("/collections/:id", putCollection(repo))
+	r.DELETE("/collections/:id", deleteCollection(repo))
+	r.PUT("/collections/:id/tracks/:trackID", putCollectionTrack(tracksRepo, repo))
+	r.DELETE("/collections/:id/tracks/:trackID", deleteCollectionTrack(repo))
+	r.PUT("/collections/:id/order", putCollectionOrder(repo))
+}
+
+func getMyCollections(repo CollectionsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		data, err := repo.ListMyCollections(c.Request.Context(), userId)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func postCollection(repo CollectionsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var input tracks.CollectionInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		collection, err := repo.CreateCollection(c.Request.Context(), userId, input)
+		if err != nil {
+			respondCollectionError(c, "create collection", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": collection,
+		})
+	}
+}
+
+func getCollection(repo CollectionsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !authorizeCollectionOwner(c, repo, id) {
+			return
+		}
+
+		collection, err := repo.GetCollection(c.Request.Context(), id)
+		if err != nil {
+			respondCollectionError(c, "get collection", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": collection,
+		})
+	}
+}
+
+func putCollection(repo CollectionsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !authorizeCollectionOwner(c, repo, id) {
+			return
+		}
+
+		var input tracks.CollectionInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		collection, err := repo.UpdateCollection(c.Request.Context(), id, input)
+		if err != nil {
+			respondCollectionError(c, "update collection", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": collection,
+		})
+	}
+}
+
+func deleteCollection(repo CollectionsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !authorizeCollectionOwner(c, repo, id) {
+			return
+		}
+
+		if err := repo.DeleteCollection(c.Request.Context(), id); err != nil {
+			respondCollectionError(c, "delete collection", err)
+			return
+		}
+
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
+
+func putCollectionTrack(tracksRepo TracksRepo, repo CollectionsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !authorizeCollectionOwner(c, repo, id) {
+			return
+		}
+		trackId := c.Param("trackID")
+		if !authorizeTrackOwner(c, tracksRepo, trackId) {
+			return
+		}
+
+		collection, err := repo.AddToCollection(c.Request.Context(), id, trackId)
+		if err != nil {
+			respondCollectionError(c, "add to collection", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": collection,
+		})
+	}
+}
+
+func deleteCollectionTrack(repo CollectionsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !authorizeCollectionOwner(c, repo, id) {
+			return
+		}
+
+		collection, err := repo.RemoveFromCollection(c.Request.Context(), id, c.Param("trackID"))
+		if err != nil {
+			respondCollectionError(c, "remove from collection", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": collection,
+		})
+	}
+}
+
+func putCollectionOrder(repo CollectionsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !authorizeCollectionOwner(c, repo, id) {
+			return
+		}
+
+		var payload struct {
+			TrackIDs []string `json:"trackIDs" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		collection, err := repo.ReorderCollection(c.Request.Context(), id, payload.TrackIDs)
+		if err != nil {
+			respondCollectionError(c, "reorder collection", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": collection,
+		})
+	}
+}
+
+// authorizeCollectionOwner responds with an error and returns false unless
+// the authenticated user owns the collection.
+func authorizeCollectionOwner(c *gin.Context, repo CollectionsRepo, id string) bool {
+	userId, ok := getUserID(c)
+	if !ok {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return false
+	}
+
+	isOwner, err := repo.IsCollectionOwner(c.Request.Context(), userId, id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return false
+	}
+	if !isOwner {
+		c.JSON(404, gin.H{"error": "Collection not found"})
+		return false
+	}
+
+	return true
+}
+
+func respondCollectionError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, tracks.ErrCollectionNotFound):
+		c.JSON(404, gin.H{"error": "Collection not found"})
+	case errors.Is(err, tracks.ErrTrackNotFound):
+		c.JSON(404, gin.H{"error": "Track not found"})
+	case errors.Is(err, tracks.ErrInvalidCollection):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		slog.Error(action, "error", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+	}
+}
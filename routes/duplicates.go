@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+)
+
+type DuplicatesRepo interface {
+	ListMyDuplicates(ctx context.Context, userID string) ([]tracks.SuspectedDuplicate, error)
+	DismissDuplicate(ctx context.Context, userID string, trackID string, duplicateOfID string) error
+}
+
+func registerDuplicatesRoutes(
+	r gin.IRouter,
+	tracksRepo TracksRepo,
+	repo DuplicatesRepo,
+) {
+	r.GET("/tracks/duplicates/my", getMyDuplicates(repo))
+	r.POST("/tracks/:id/duplicates/:otherID/dismiss", postDismissDuplicate(tracksRepo, repo))
+}
+
+func getMyDuplicates(repo DuplicatesRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		data, err := repo.ListMyDuplicates(c.Request.Context(), userId)
+		if err != nil {
+			slog.Error("list duplicates", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func postDismissDuplicate(tracksRepo TracksRepo, repo DuplicatesRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, tracksRepo, trackId) {
+			return
+		}
+		userId, _ := getUserID(c)
+
+		err := repo.DismissDuplicate(c.Request.Context(), userId, trackId, c.Param("otherID"))
+		if err != nil {
+			if errors.Is(err, tracks.ErrDuplicateNotFound) || errors.Is(err, tracks.ErrTrackNotFound) {
+				c.JSON(404, gin.H{"error": "Duplicate not found"})
+				return
+			}
+			slog.Error("dismiss duplicate", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/analysis"
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	// maxEstimatePoints is the most points a line to estimate can have, as
+	// the elevation of each is looked up
+	maxEstimatePoints   = 10000
+	maxEstimateBodySize = 1024 * 1024 // 1MB
+)
+
+type MovingTimeCalibrator interface {
+	MovingTimeCalibration(ctx context.Context, userID string) (analysis.Calibration, error)
+}
+
+func registerEstimateRoutes(
+	r gin.IRouter,
+	elevation analysis.ElevationQuerier,
+	calibrator MovingTimeCalibrator,
+) {
+	r.POST("/estimate/moving-time", postEstimateMovingTime(elevation, calibrator))
+}
+
+// postEstimateMovingTime estimates the time to walk planned geometry. If the
+// request is authenticated the estimates are calibrated against the user's
+// own tracks.
+func postEstimateMovingTime(elevation analysis.ElevationQuerier, calibrator MovingTimeCalibrator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxEstimateBodySize)
+		var payload struct {
+			Points orb.LineString `json:"points" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil || len(payload.Points) < 2 {
+			var tooLargeErr *http.MaxBytesError
+			if errors.As(err, &tooLargeErr) {
+				c.JSON(413, gin.H{"error": "Request too large"})
+				return
+			}
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+		if len(payload.Points) > maxEstimatePoints {
+			c.JSON(400, gin.H{"error": "Too many points"})
+			return
+		}
+
+		elevations, err := elevation.QueryElevations(c.Request.Context(), payload.Points)
+		if err != nil {
+			slog.Error("query elevations", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		calibration := analysis.DefaultCalibration
+		if userID, ok := getUserID(c); ok {
+			calibration, err = calibrator.MovingTimeCalibration(c.Request.Context(), userID)
+			if err != nil {
+				slog.Error("get moving time calibration", "error", err)
+				c.JSON(500, gin.H{"error": "Internal server error"})
+				return
+			}
+		}
+
+		estimates := gin.H{}
+		for _, method := range []analysis.EstimateMethod{analysis.Naismith, analysis.Tobler} {
+			secs, ok := analysis.EstimateMovingTime(method, payload.Points, elevations)
+			if !ok {
+				c.JSON(500, gin.H{"error": "Internal server error"})
+				return
+			}
+			estimates[string(method)] = gin.H{
+				"secs":           secs,
+				"calibratedSecs": calibration.Apply(method, secs),
+			}
+		}
+
+		c.JSON(200, gin.H{
+			"data": gin.H{
+				"lengthMeters": geo.LengthHaversine(payload.Points),
+				"estimates":    estimates,
+				"calibration":  calibration,
+			},
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/gin-gonic/gin"
+	"io"
+	"time"
+)
+
+// Sent as a comment so that proxies don't time out idle streams
+const importEventsKeepAlive = 30 * time.Second
+
+type ImportEventsSource interface {
+	Subscribe(userID string) (<-chan tracks.ImportEvent, func())
+}
+
+func registerImportEventsRoutes(
+	r gin.IRouter,
+	events ImportEventsSource,
+) {
+	r.GET("/tracks/import/my/events", getMyImportEvents(events))
+}
+
+// getMyImportEvents streams the user's import events as server-sent events
+// named "import".
+func getMyImportEvents(events ImportEventsSource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		ch, unsubscribe := events.Subscribe(userId)
+		defer unsubscribe()
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+
+		keepAlive := time.NewTicker(importEventsKeepAlive)
+		defer keepAlive.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case event := <-ch:
+				c.SSEvent("import", event)
+				return true
+			case <-keepAlive.C:
+				_, err := io.WriteString(w, ": keep-alive\n\n")
+				return err == nil
+			}
+		})
+	}
+}
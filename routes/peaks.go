@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"context"
+	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+)
+
+type PeaksRepo interface {
+	ListSummits(ctx context.Context, trackID string) ([]tracks.Summit, error)
+	ListMyBaggedPeaks(ctx context.Context, userID string, dataset string) ([]tracks.BaggedPeak, error)
+}
+
+func registerPeaksRoutes(
+	r gin.IRouter,
+	tracksRepo TracksRepo,
+	repo PeaksRepo,
+) {
+	r.GET("/tracks/:id/summits", getTrackSummits(tracksRepo, repo))
+	r.GET("/peaks/bagged/my", getMyBaggedPeaks(repo))
+}
+
+func getTrackSummits(tracksRepo TracksRepo, repo PeaksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, tracksRepo, trackId) {
+			return
+		}
+
+		data, err := repo.ListSummits(c.Request.Context(), trackId)
+		if err != nil {
+			slog.Error("list summits", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func getMyBaggedPeaks(repo PeaksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		data, err := repo.ListMyBaggedPeaks(c.Request.Context(), userId, c.Query("dataset"))
+		if err != nil {
+			slog.Error("list bagged peaks", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
@@ -10,6 +10,14 @@ func Router(
 	tracks TracksRepo,
 	elevation analysis.ElevationQuerier,
 	settings SettingsRepo,
+	calibrator MovingTimeCalibrator,
+	shares SharesRepo,
+	collections CollectionsRepo,
+	tags TagsRepo,
+	peaks PeaksRepo,
+	duplicates DuplicatesRepo,
+	importEvents ImportEventsSource,
+	webhooks WebhooksRepo,
 ) *gin.Engine {
 	r := gin.New()
 
@@ -26,6 +34,14 @@ func Router(
 	registerTracksRoutes(base, tracks)
 	registerElevationRoute(base, elevation)
 	registerSettingsRoutes(base, settings)
+	registerEstimateRoutes(base, elevation, calibrator)
+	registerSharesRoutes(base, tracks, shares)
+	registerCollectionsRoutes(base, tracks, collections)
+	registerTagsRoutes(base, tracks, tags)
+	registerPeaksRoutes(base, tracks, peaks)
+	registerDuplicatesRoutes(base, tracks, duplicates)
+	registerImportEventsRoutes(base, importEvents)
+	registerWebhooksRoutes(base, webhooks)
 
 	return r
 }
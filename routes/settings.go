@@ -3,18 +3,32 @@ package routes
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/settings"
 	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb"
 	"log/slog"
 )
 
 type SettingsRepo interface {
 	GetUnitSettings(ctx context.Context, userID string) (json.RawMessage, error)
 	SetUnitSettings(ctx context.Context, userID string, value json.RawMessage) error
+	ListPrivacyZones(ctx context.Context, userID string) ([]settings.PrivacyZone, error)
+	CreatePrivacyZone(ctx context.Context, userID string, zone settings.PrivacyZone) (settings.PrivacyZone, error)
+	DeletePrivacyZone(ctx context.Context, userID string, id string) error
 }
 
+const (
+	minPrivacyZoneRadius = 50
+	maxPrivacyZoneRadius = 5000
+)
+
 func registerSettingsRoutes(r gin.IRouter, repo SettingsRepo) {
 	r.GET("/settings/units", getUnitSettings(repo))
 	r.POST("/settings/units", setUnitSettings(repo))
+	r.GET("/settings/privacy-zones", getPrivacyZones(repo))
+	r.POST("/settings/privacy-zones", postPrivacyZone(repo))
+	r.DELETE("/settings/privacy-zones/:id", deletePrivacyZone(repo))
 }
 
 func getUnitSettings(repo SettingsRepo) gin.HandlerFunc {
@@ -57,3 +71,84 @@ func setUnitSettings(repo SettingsRepo) gin.HandlerFunc {
 		c.JSON(200, gin.H{"data": gin.H{}})
 	}
 }
+
+func getPrivacyZones(repo SettingsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		zones, err := repo.ListPrivacyZones(c.Request.Context(), userID)
+		if err != nil {
+			slog.Error("list privacy zones", "error", err)
+			c.JSON(500, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		c.JSON(200, gin.H{"data": zones})
+	}
+}
+
+func postPrivacyZone(repo SettingsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		var payload struct {
+			Name         string    `json:"name"`
+			Center       orb.Point `json:"center" binding:"required"`
+			RadiusMeters float64   `json:"radiusMeters" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if payload.RadiusMeters < minPrivacyZoneRadius || payload.RadiusMeters > maxPrivacyZoneRadius {
+			c.JSON(400, gin.H{"error": "radiusMeters out of range"})
+			return
+		}
+		if payload.Center.Lon() < -180 || payload.Center.Lon() > 180 ||
+			payload.Center.Lat() < -90 || payload.Center.Lat() > 90 {
+			c.JSON(400, gin.H{"error": "center out of range"})
+			return
+		}
+
+		zone, err := repo.CreatePrivacyZone(c.Request.Context(), userID, settings.PrivacyZone{
+			Name:         payload.Name,
+			Center:       payload.Center,
+			RadiusMeters: payload.RadiusMeters,
+		})
+		if err != nil {
+			slog.Error("create privacy zone", "error", err)
+			c.JSON(500, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		c.JSON(200, gin.H{"data": zone})
+	}
+}
+
+func deletePrivacyZone(repo SettingsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		err := repo.DeletePrivacyZone(c.Request.Context(), userID, c.Param("id"))
+		if err != nil {
+			if errors.Is(err, settings.ErrPrivacyZoneNotFound) {
+				c.JSON(404, gin.H{"error": "Privacy zone not found"})
+				return
+			}
+			slog.Error("delete privacy zone", "error", err)
+			c.JSON(500, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
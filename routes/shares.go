@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"time"
+)
+
+type SharesRepo interface {
+	CreateShare(ctx context.Context, ownerID string, trackID string, expiresAt *time.Time) (tracks.Share, error)
+	ListMyActiveShares(ctx context.Context, ownerID string) ([]tracks.Share, error)
+	RevokeShare(ctx context.Context, ownerID string, token string) error
+	GetShared(ctx context.Context, token string) (tracks.Track, error)
+}
+
+func registerSharesRoutes(
+	r gin.IRouter,
+	tracksRepo TracksRepo,
+	repo SharesRepo,
+) {
+	r.POST("/tracks/:id/shares", postTrackShare(tracksRepo, repo))
+	r.GET("/shares/my", getMyShares(repo))
+	r.DELETE("/shares/:token", deleteShare(repo))
+	r.GET("/shared/:token", getSharedTrack(repo))
+}
+
+func postTrackShare(tracksRepo TracksRepo, repo SharesRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, tracksRepo, trackId) {
+			return
+		}
+		userId, _ := getUserID(c)
+
+		var payload struct {
+			ExpiresAt *time.Time `json:"expiresAt"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&payload); err != nil {
+				c.JSON(400, gin.H{"error": "Invalid request"})
+				return
+			}
+		}
+		if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+			c.JSON(400, gin.H{"error": "expiresAt must be in the future"})
+			return
+		}
+
+		share, err := repo.CreateShare(c.Request.Context(), userId, trackId, payload.ExpiresAt)
+		if err != nil {
+			slog.Error("create share", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": share,
+		})
+	}
+}
+
+func getMyShares(repo SharesRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		data, err := repo.ListMyActiveShares(c.Request.Context(), userId)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func deleteShare(repo SharesRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		err := repo.RevokeShare(c.Request.Context(), userId, c.Param("token"))
+		if err != nil {
+			if errors.Is(err, tracks.ErrShareNotFound) {
+				c.JSON(404, gin.H{"error": "Share not found"})
+				return
+			}
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
+
+// getSharedTrack is deliberately unauthenticated, as the token is the
+// credential.
+func getSharedTrack(repo SharesRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		track, err := repo.GetShared(c.Request.Context(), c.Param("token"))
+		if err != nil {
+			if errors.Is(err, tracks.ErrShareNotFound) {
+				c.JSON(404, gin.H{"error": "Share not found"})
+				return
+			}
+			slog.Error("get shared track", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": track,
+		})
+	}
+}
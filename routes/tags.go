@@ -0,0 +1,123 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+)
+
+type TagsRepo interface {
+	AddTag(ctx context.Context, ownerID string, trackID string, tag string) error
+	RemoveTag(ctx context.Context, trackID string, tag string) error
+	RenameTag(ctx context.Context, ownerID string, from string, to string) error
+	ListMyTags(ctx context.Context, ownerID string) ([]tracks.TagCount, error)
+}
+
+func registerTagsRoutes(
+	r gin.IRouter,
+	tracksRepo TracksRepo,
+	repo TagsRepo,
+) {
+	r.PUT("/tracks/:id/tags/:tag", putTrackTag(tracksRepo, repo))
+	r.DELETE("/tracks/:id/tags/:tag", deleteTrackTag(tracksRepo, repo))
+	r.GET("/tags/my", getMyTags(repo))
+	r.POST("/tags/rename", postRenameTag(repo))
+}
+
+func putTrackTag(tracksRepo TracksRepo, repo TagsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, tracksRepo, trackId) {
+			return
+		}
+		userId, _ := getUserID(c)
+
+		err := repo.AddTag(c.Request.Context(), userId, trackId, c.Param("tag"))
+		if err != nil {
+			respondTagError(c, "add tag", err)
+			return
+		}
+
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
+
+func deleteTrackTag(tracksRepo TracksRepo, repo TagsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, tracksRepo, trackId) {
+			return
+		}
+
+		err := repo.RemoveTag(c.Request.Context(), trackId, c.Param("tag"))
+		if err != nil {
+			respondTagError(c, "remove tag", err)
+			return
+		}
+
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
+
+func getMyTags(repo TagsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		data, err := repo.ListMyTags(c.Request.Context(), userId)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func postRenameTag(repo TagsRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var payload struct {
+			From string `json:"from" binding:"required"`
+			To   string `json:"to" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		err := repo.RenameTag(c.Request.Context(), userId, payload.From, payload.To)
+		if err != nil {
+			respondTagError(c, "rename tag", err)
+			return
+		}
+
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
+
+func respondTagError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, tracks.ErrInvalidTag):
+		c.JSON(400, gin.H{"error": "Invalid tag"})
+	case errors.Is(err, tracks.ErrTagNotFound):
+		c.JSON(404, gin.H{"error": "Tag not found"})
+	case errors.Is(err, tracks.ErrTrackNotFound):
+		c.JSON(404, gin.H{"error": "Track not found"})
+	default:
+		slog.Error(action, "error", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+	}
+}
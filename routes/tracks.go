@@ -9,15 +9,36 @@ import (
 	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type TracksRepo interface {
 	Get(ctx context.Context, id string) (tracks.Track, error)
 	Delete(ctx context.Context, id string) error
+	Trim(ctx context.Context, id string) (tracks.Track, error)
+	RestoreOriginal(ctx context.Context, id string) (tracks.Track, error)
+	Split(ctx context.Context, id string, index int) ([]tracks.Track, error)
+	SplitAtTime(ctx context.Context, id string, t time.Time) ([]tracks.Track, error)
+	Merge(ctx context.Context, trackIDs []string) (tracks.Track, error)
+	SetActivityType(ctx context.Context, id string, activityType string) (tracks.Track, error)
 	IsOwner(ctx context.Context, userId string, trackId string) (bool, error)
-	ListMyTracksOrderByTime(ctx context.Context, userId string) ([]tracks.Track, error)
-	Import(ctx context.Context, ownerID string, filename string, data []byte) (string, error)
+	ListMyTracks(ctx context.Context, userId string, opts tracks.ListOptions) ([]tracks.Track, error)
+	Search(ctx context.Context, userID string, query string, limit int, offset int) (tracks.SearchPage, error)
+	IsCollectionOwner(ctx context.Context, userId string, collectionId string) (bool, error)
+	Import(ctx context.Context, ownerID string, filename string, data []byte, opts tracks.ImportOptions) (string, error)
 	ListMyPendingOrRecentImports(ctx context.Context, userID string) ([]tracks.Import, error)
+	ImportStatus(ctx context.Context, id string) (tracks.Import, error)
+	RetryImport(ctx context.Context, id string) (tracks.Import, error)
+	CancelImport(ctx context.Context, id string) (tracks.Import, error)
+	ImportURL(ctx context.Context, ownerID string, rawURL string, opts tracks.ImportOptions) (tracks.Import, error)
+	ImportArchive(ctx context.Context, ownerID string, filename string, data []byte, opts tracks.ImportOptions) (tracks.ImportBatch, error)
+	GetImportBatch(ctx context.Context, id string) (tracks.ImportBatch, error)
+	CreateUpload(ctx context.Context, ownerID string, filename string, size int64) (tracks.Upload, error)
+	GetUpload(ctx context.Context, id string) (tracks.Upload, error)
+	AppendUpload(ctx context.Context, id string, offset int64, chunk []byte) (tracks.Upload, error)
+	ImportUpload(ctx context.Context, id string, opts tracks.ImportOptions) (string, *tracks.ImportBatch, error)
+	DeleteUpload(ctx context.Context, id string) error
 }
 
 const maxImportSize = 10 * 1024 * 1024 // 10MB
@@ -28,9 +49,25 @@ func registerTracksRoutes(
 ) {
 	r.GET("/tracks/:id", getTrack(repo))
 	r.DELETE("/tracks/:id", deleteTrack(repo))
+	r.POST("/tracks/:id/trim", postTrimTrack(repo))
+	r.POST("/tracks/:id/restore-original", postRestoreOriginalTrack(repo))
+	r.POST("/tracks/:id/split", postSplitTrack(repo))
+	r.POST("/tracks/merge", postMergeTracks(repo))
+	r.PUT("/tracks/:id/activity-type", putTrackActivityType(repo))
 	r.GET("/tracks/my", getMyTracks(repo))
+	r.GET("/tracks/search", searchTracks(repo))
 	r.GET("/tracks/import/my/pending-or-recent", getMyPendingOrRecentImports(repo))
+	r.GET("/tracks/import/:id", getImport(repo))
+	r.POST("/tracks/import/:id/retry", postRetryImport(repo))
+	r.POST("/tracks/import/:id/cancel", postCancelImport(repo))
 	r.POST("/tracks/import", postImportTrack(repo))
+	r.POST("/tracks/import/url", postImportURL(repo))
+	r.GET("/tracks/import/batch/:id", getImportBatch(repo))
+	r.POST("/tracks/import/uploads", postUpload(repo))
+	r.GET("/tracks/import/uploads/:id", getUpload(repo))
+	r.PATCH("/tracks/import/uploads/:id", patchUpload(repo))
+	r.POST("/tracks/import/uploads/:id/finish", postFinishUpload(repo))
+	r.DELETE("/tracks/import/uploads/:id", deleteUpload(repo))
 }
 
 func getTrack(repo TracksRepo) gin.HandlerFunc {
@@ -103,6 +140,176 @@ func deleteTrack(repo TracksRepo) gin.HandlerFunc {
 	}
 }
 
+func postTrimTrack(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, repo, trackId) {
+			return
+		}
+
+		track, err := repo.Trim(c.Request.Context(), trackId)
+		if err != nil {
+			slog.Error("trim track", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": track,
+		})
+	}
+}
+
+func postRestoreOriginalTrack(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, repo, trackId) {
+			return
+		}
+
+		track, err := repo.RestoreOriginal(c.Request.Context(), trackId)
+		if err != nil {
+			slog.Error("restore original track", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": track,
+		})
+	}
+}
+
+func postSplitTrack(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, repo, trackId) {
+			return
+		}
+
+		var payload struct {
+			Index *int       `json:"index"`
+			Time  *time.Time `json:"time"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil || (payload.Index == nil) == (payload.Time == nil) {
+			c.JSON(400, gin.H{"error": "Expected exactly one of index or time"})
+			return
+		}
+
+		var parts []tracks.Track
+		var err error
+		if payload.Index != nil {
+			parts, err = repo.Split(c.Request.Context(), trackId, *payload.Index)
+		} else {
+			parts, err = repo.SplitAtTime(c.Request.Context(), trackId, *payload.Time)
+		}
+		if err != nil {
+			if errors.Is(err, tracks.ErrInvalidSplit) {
+				c.JSON(400, gin.H{"error": "Invalid split point"})
+				return
+			}
+			slog.Error("split track", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": parts,
+		})
+	}
+}
+
+func postMergeTracks(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload struct {
+			TrackIDs []string `json:"trackIDs" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		for _, trackId := range payload.TrackIDs {
+			if !authorizeTrackOwner(c, repo, trackId) {
+				return
+			}
+		}
+
+		track, err := repo.Merge(c.Request.Context(), payload.TrackIDs)
+		if err != nil {
+			if errors.Is(err, tracks.ErrInvalidMerge) {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, tracks.ErrTrackNotFound) {
+				c.JSON(404, gin.H{"error": "Track not found"})
+				return
+			}
+			slog.Error("merge tracks", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": track,
+		})
+	}
+}
+
+func putTrackActivityType(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackId := c.Param("id")
+		if !authorizeTrackOwner(c, repo, trackId) {
+			return
+		}
+
+		var payload struct {
+			ActivityType string `json:"activityType" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		track, err := repo.SetActivityType(c.Request.Context(), trackId, payload.ActivityType)
+		if err != nil {
+			if errors.Is(err, tracks.ErrInvalidActivityType) {
+				c.JSON(400, gin.H{"error": "Invalid activity type"})
+				return
+			}
+			slog.Error("set activity type", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": track,
+		})
+	}
+}
+
+// authorizeTrackOwner responds with an error and returns false unless the
+// authenticated user owns the track.
+func authorizeTrackOwner(c *gin.Context, repo TracksRepo, trackId string) bool {
+	userId, ok := getUserID(c)
+	if !ok {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return false
+	}
+
+	isOwner, err := repo.IsOwner(c.Request.Context(), userId, trackId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return false
+	}
+	if !isOwner {
+		c.JSON(403, gin.H{"error": "Forbidden"})
+		return false
+	}
+
+	return true
+}
+
 func getMyTracks(repo TracksRepo) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId, ok := getUserID(c)
@@ -111,14 +318,85 @@ func getMyTracks(repo TracksRepo) gin.HandlerFunc {
 			return
 		}
 
-		orderBy := c.Query("orderBy")
-		if orderBy != "time" {
+		orderBy := tracks.ListOrder(c.Query("orderBy"))
+		if orderBy != tracks.OrderByTime && orderBy != tracks.OrderByPosition {
 			c.JSON(400, gin.H{"error": "Invalid orderBy parameter"})
 			return
 		}
 
-		data, err := repo.ListMyTracksOrderByTime(c.Request.Context(), userId)
+		collection := c.Query("collection")
+		if collection != "" {
+			isOwner, err := repo.IsCollectionOwner(c.Request.Context(), userId, collection)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "Internal server error"})
+				return
+			}
+			if !isOwner {
+				c.JSON(404, gin.H{"error": "Collection not found"})
+				return
+			}
+		}
+
+		tagMatch := c.DefaultQuery("tagMatch", "all")
+		if tagMatch != "all" && tagMatch != "any" {
+			c.JSON(400, gin.H{"error": "Invalid tagMatch parameter"})
+			return
+		}
+
+		data, err := repo.ListMyTracks(c.Request.Context(), userId, tracks.ListOptions{
+			OrderBy:      orderBy,
+			CollectionID: collection,
+			Tags:         c.QueryArray("tag"),
+			MatchAllTags: tagMatch == "all",
+		})
 		if err != nil {
+			if errors.Is(err, tracks.ErrInvalidListOptions) {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func searchTracks(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		limit := tracks.DefaultSearchLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid limit parameter"})
+				return
+			}
+			limit = n
+		}
+		var offset int
+		if v := c.Query("offset"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid offset parameter"})
+				return
+			}
+			offset = n
+		}
+
+		data, err := repo.Search(c.Request.Context(), userId, c.Query("q"), limit, offset)
+		if err != nil {
+			if errors.Is(err, tracks.ErrInvalidSearch) {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(500, gin.H{"error": "Internal server error"})
 			return
 		}
@@ -149,9 +427,118 @@ func getMyPendingOrRecentImports(repo TracksRepo) gin.HandlerFunc {
 	}
 }
 
-type successfulTrackImportRequest struct {
-	ID       string `json:"id"`
+func getImport(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, ok := authorizeImportOwner(c, repo, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func postRetryImport(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		importId := c.Param("id")
+		if _, ok := authorizeImportOwner(c, repo, importId); !ok {
+			return
+		}
+
+		data, err := repo.RetryImport(c.Request.Context(), importId)
+		if err != nil {
+			respondImportActionError(c, "retry import", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func postCancelImport(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		importId := c.Param("id")
+		if _, ok := authorizeImportOwner(c, repo, importId); !ok {
+			return
+		}
+
+		data, err := repo.CancelImport(c.Request.Context(), importId)
+		if err != nil {
+			respondImportActionError(c, "cancel import", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+// authorizeImportOwner gets the import if it belongs to the user, otherwise
+// responding with an error.
+func authorizeImportOwner(c *gin.Context, repo TracksRepo, importId string) (tracks.Import, bool) {
+	userId, ok := getUserID(c)
+	if !ok {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return tracks.Import{}, false
+	}
+
+	data, err := repo.ImportStatus(c.Request.Context(), importId)
+	if err != nil {
+		if errors.Is(err, tracks.ErrImportNotFound) {
+			c.JSON(404, gin.H{"error": "Import not found"})
+			return tracks.Import{}, false
+		}
+		slog.Error("get import status", "error", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return tracks.Import{}, false
+	}
+	if data.OwnerID != userId {
+		// Don't reveal that another user's import exists
+		c.JSON(404, gin.H{"error": "Import not found"})
+		return tracks.Import{}, false
+	}
+
+	return data, true
+}
+
+func respondImportActionError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, tracks.ErrInvalidImportAction):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, tracks.ErrImportNotFound):
+		c.JSON(404, gin.H{"error": "Import not found"})
+	default:
+		slog.Error(action, "error", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+	}
+}
+
+const (
+	trackImportCreated   = "created"
+	trackImportDuplicate = "duplicate"
+	trackImportFailed    = "failed"
+)
+
+// trackImportResult is the outcome for one file of an upload, so that a
+// problem with one file doesn't fail the rest.
+type trackImportResult struct {
+	ID       string `json:"id,omitempty"`
 	Filename string `json:"filename"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	// Existing is the earlier import of the same file if Status is duplicate
+	Existing *tracks.Import `json:"existing,omitempty"`
+	// Batch is set if the file was an archive, in which case ID is the ID of
+	// the batch of its files. The archive is unpacked in the background,
+	// which can be followed with the batch.
+	Batch *tracks.ImportBatch `json:"batch,omitempty"`
+	// retryable is set if the import failed for reasons unrelated to the file
+	retryable bool
 }
 
 func postImportTrack(repo TracksRepo) gin.HandlerFunc {
@@ -174,17 +561,20 @@ func postImportTrack(repo TracksRepo) gin.HandlerFunc {
 			return
 		}
 
-		var imports []successfulTrackImportRequest
+		var opts tracks.ImportOptions
+		switch c.DefaultQuery("duplicates", "flag") {
+		case "flag":
+		case "skip":
+			opts.SkipDuplicates = true
+		default:
+			c.JSON(400, gin.H{"error": "Invalid duplicates parameter"})
+			return
+		}
+
+		imports := make([]trackImportResult, 0, len(files))
 		for _, file := range files {
-			id, err := importTrackFile(c.Request.Context(), c.Writer, repo, userId, *file)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "Internal server error"})
-				return
-			}
-			imports = append(imports, successfulTrackImportRequest{
-				ID:       id,
-				Filename: file.Filename,
-			})
+			id, batch, err := importTrackFile(c.Request.Context(), c.Writer, repo, userId, *file, opts)
+			imports = append(imports, newTrackImportResult(file.Filename, id, batch, err))
 		}
 
 		c.JSON(200, gin.H{
@@ -195,19 +585,140 @@ func postImportTrack(repo TracksRepo) gin.HandlerFunc {
 	}
 }
 
-func importTrackFile(ctx context.Context, w http.ResponseWriter, repo TracksRepo, userId string, file multipart.FileHeader) (string, error) {
+func postImportURL(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var payload struct {
+			URL        string `json:"url" binding:"required"`
+			Duplicates string `json:"duplicates"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		var opts tracks.ImportOptions
+		switch payload.Duplicates {
+		case "", "flag":
+		case "skip":
+			opts.SkipDuplicates = true
+		default:
+			c.JSON(400, gin.H{"error": "Invalid duplicates parameter"})
+			return
+		}
+
+		data, err := repo.ImportURL(c.Request.Context(), userId, payload.URL, opts)
+		switch {
+		case errors.Is(err, tracks.ErrInvalidImportURL):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case err != nil:
+			slog.Error("import url", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+		default:
+			// The file is downloaded and processed in the background, which
+			// can be followed with the import status or events. If it was
+			// already imported the import fails with duplicateOfID set.
+			c.JSON(202, gin.H{
+				"data": newTrackImportResult(data.Filename, data.ID, nil, nil),
+			})
+		}
+	}
+}
+
+func importTrackFile(ctx context.Context, w http.ResponseWriter, repo TracksRepo, userId string, file multipart.FileHeader, opts tracks.ImportOptions) (string, *tracks.ImportBatch, error) {
 	f, err := file.Open()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	defer f.Close()
-	data, err := io.ReadAll(http.MaxBytesReader(w, f, maxImportSize))
+	maxSize := int64(maxImportSize)
+	if tracks.IsArchive(file.Filename) {
+		maxSize = tracks.MaxArchiveSize
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, f, maxSize))
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	id, err := repo.Import(ctx, userId, file.Filename, data)
+	return importFileData(ctx, repo, userId, file.Filename, data, opts)
+}
+
+// importFileData imports a track file or an archive of them. If it was an
+// archive the ID is that of the batch.
+func importFileData(ctx context.Context, repo TracksRepo, userId string, filename string, data []byte, opts tracks.ImportOptions) (string, *tracks.ImportBatch, error) {
+	if tracks.IsArchive(filename) {
+		batch, err := repo.ImportArchive(ctx, userId, filename, data, opts)
+		if err != nil {
+			return "", nil, err
+		}
+		return batch.ID, &batch, nil
+	}
+	id, err := repo.Import(ctx, userId, filename, data, opts)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	return id, nil, nil
+}
+
+func newTrackImportResult(filename string, id string, batch *tracks.ImportBatch, err error) trackImportResult {
+	result := trackImportResult{Filename: filename}
+	var duplicateErr tracks.DuplicateImportError
+	var tooLargeErr *http.MaxBytesError
+	switch {
+	case err == nil:
+		result.ID = id
+		result.Status = trackImportCreated
+		result.Batch = batch
+	case errors.As(err, &duplicateErr):
+		result.ID = duplicateErr.Existing.ID
+		result.Status = trackImportDuplicate
+		result.Error = "File already imported"
+		result.Existing = &duplicateErr.Existing
+	case errors.Is(err, tracks.ErrImportTooLarge) || errors.As(err, &tooLargeErr):
+		result.Status = trackImportFailed
+		result.Error = "File too large"
+	case errors.Is(err, tracks.ErrInvalidArchive):
+		result.Status = trackImportFailed
+		result.Error = err.Error()
+	default:
+		slog.Error("import track file", "filename", filename, "error", err)
+		result.Status = trackImportFailed
+		result.Error = "Internal server error"
+		result.retryable = true
+	}
+	return result
+}
+
+func getImportBatch(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		data, err := repo.GetImportBatch(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			if errors.Is(err, tracks.ErrImportBatchNotFound) {
+				c.JSON(404, gin.H{"error": "Import batch not found"})
+				return
+			}
+			slog.Error("get import batch", "error", err)
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+		if data.OwnerID != userId {
+			// Don't reveal that another user's batch exists
+			c.JSON(404, gin.H{"error": "Import batch not found"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
 	}
-	return id, nil
 }
@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"errors"
+	"github.com/dzfranklin/plantopo-api/tracks"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// uploadOffsetHeader is the offset a chunk starts at, as in the tus protocol.
+const uploadOffsetHeader = "Upload-Offset"
+
+func postUpload(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var payload struct {
+			Filename string `json:"filename" binding:"required"`
+			Size     int64  `json:"size" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		upload, err := repo.CreateUpload(c.Request.Context(), userId, payload.Filename, payload.Size)
+		if err != nil {
+			respondUploadError(c, "create upload", err)
+			return
+		}
+
+		c.JSON(201, gin.H{
+			"data": upload,
+		})
+	}
+}
+
+func getUpload(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		upload, ok := authorizeUploadOwner(c, repo, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": upload,
+		})
+	}
+}
+
+// patchUpload appends the request body to the upload.
+func patchUpload(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uploadId := c.Param("id")
+		if _, ok := authorizeUploadOwner(c, repo, uploadId); !ok {
+			return
+		}
+
+		offset, err := strconv.ParseInt(c.GetHeader(uploadOffsetHeader), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid " + uploadOffsetHeader + " header"})
+			return
+		}
+
+		chunk, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, tracks.MaxUploadChunkSize))
+		if err != nil {
+			var tooLargeErr *http.MaxBytesError
+			if errors.As(err, &tooLargeErr) {
+				c.JSON(413, gin.H{"error": "Chunk too large"})
+				return
+			}
+			// The connection dropped, and the client will resume from the
+			// offset it gets next time
+			slog.Info("read upload chunk", "error", err)
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		upload, err := repo.AppendUpload(c.Request.Context(), uploadId, offset, chunk)
+		if err != nil {
+			respondUploadError(c, "append upload", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": upload,
+		})
+	}
+}
+
+// postFinishUpload imports a complete upload, responding like an upload of
+// the single file to /tracks/import. The file is processed in the
+// background, as is an archive, which is unpacked into a batch. Either can be
+// followed with the ID in the response.
+func postFinishUpload(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uploadId := c.Param("id")
+		upload, ok := authorizeUploadOwner(c, repo, uploadId)
+		if !ok {
+			return
+		}
+
+		var opts tracks.ImportOptions
+		switch c.DefaultQuery("duplicates", "flag") {
+		case "flag":
+		case "skip":
+			opts.SkipDuplicates = true
+		default:
+			c.JSON(400, gin.H{"error": "Invalid duplicates parameter"})
+			return
+		}
+
+		id, batch, err := repo.ImportUpload(c.Request.Context(), uploadId, opts)
+		if errors.Is(err, tracks.ErrUploadNotFound) || errors.Is(err, tracks.ErrInvalidUpload) {
+			respondUploadError(c, "import upload", err)
+			return
+		}
+		result := newTrackImportResult(upload.Filename, id, batch, err)
+		// The upload is kept if finishing can be retried, and the batch of an
+		// archive deletes it once unpacked
+		if batch == nil && !result.retryable {
+			if err := repo.DeleteUpload(c.Request.Context(), uploadId); err != nil {
+				slog.Error("delete finished upload", "error", err)
+			}
+		}
+
+		status := 200
+		if result.Status == trackImportCreated {
+			status = 202
+		}
+		c.JSON(status, gin.H{
+			"data": result,
+		})
+	}
+}
+
+func deleteUpload(repo TracksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uploadId := c.Param("id")
+		if _, ok := authorizeUploadOwner(c, repo, uploadId); !ok {
+			return
+		}
+
+		if err := repo.DeleteUpload(c.Request.Context(), uploadId); err != nil {
+			respondUploadError(c, "delete upload", err)
+			return
+		}
+
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
+
+// authorizeUploadOwner gets the upload if it belongs to the user, otherwise
+// responding with an error.
+func authorizeUploadOwner(c *gin.Context, repo TracksRepo, uploadId string) (tracks.Upload, bool) {
+	userId, ok := getUserID(c)
+	if !ok {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return tracks.Upload{}, false
+	}
+
+	upload, err := repo.GetUpload(c.Request.Context(), uploadId)
+	if err != nil {
+		respondUploadError(c, "get upload", err)
+		return tracks.Upload{}, false
+	}
+	if upload.OwnerID != userId {
+		// Don't reveal that another user's upload exists
+		c.JSON(404, gin.H{"error": "Upload not found"})
+		return tracks.Upload{}, false
+	}
+
+	return upload, true
+}
+
+func respondUploadError(c *gin.Context, action string, err error) {
+	var offsetErr tracks.UploadOffsetError
+	switch {
+	case errors.As(err, &offsetErr):
+		c.JSON(409, gin.H{"error": err.Error(), "offset": offsetErr.Offset})
+	case errors.Is(err, tracks.ErrUploadNotFound):
+		c.JSON(404, gin.H{"error": "Upload not found"})
+	case errors.Is(err, tracks.ErrImportTooLarge):
+		c.JSON(413, gin.H{"error": "File too large"})
+	case errors.Is(err, tracks.ErrInvalidUpload):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, tracks.ErrTooManyUploads):
+		c.JSON(429, gin.H{"error": err.Error()})
+	default:
+		slog.Error(action, "error", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+	}
+}
@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/webhooks"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+)
+
+type WebhooksRepo interface {
+	Create(ctx context.Context, ownerID string, url string, events []string) (webhooks.Webhook, error)
+	ListMine(ctx context.Context, ownerID string) ([]webhooks.Webhook, error)
+	Delete(ctx context.Context, ownerID string, id string) error
+	ListDeliveries(ctx context.Context, ownerID string, id string) ([]webhooks.Delivery, error)
+}
+
+func registerWebhooksRoutes(
+	r gin.IRouter,
+	repo WebhooksRepo,
+) {
+	r.GET("/webhooks/my", getMyWebhooks(repo))
+	r.POST("/webhooks", postWebhook(repo))
+	r.DELETE("/webhooks/:id", deleteWebhook(repo))
+	r.GET("/webhooks/:id/deliveries", getWebhookDeliveries(repo))
+}
+
+func getMyWebhooks(repo WebhooksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		data, err := repo.ListMine(c.Request.Context(), userId)
+		if err != nil {
+			respondWebhookError(c, "list webhooks", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func postWebhook(repo WebhooksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var payload struct {
+			URL    string   `json:"url" binding:"required"`
+			Events []string `json:"events" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		data, err := repo.Create(c.Request.Context(), userId, payload.URL, payload.Events)
+		if err != nil {
+			respondWebhookError(c, "create webhook", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func deleteWebhook(repo WebhooksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		err := repo.Delete(c.Request.Context(), userId, c.Param("id"))
+		if err != nil {
+			respondWebhookError(c, "delete webhook", err)
+			return
+		}
+
+		c.JSON(200, gin.H{"data": gin.H{}})
+	}
+}
+
+func getWebhookDeliveries(repo WebhooksRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := getUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		data, err := repo.ListDeliveries(c.Request.Context(), userId, c.Param("id"))
+		if err != nil {
+			respondWebhookError(c, "list webhook deliveries", err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": data,
+		})
+	}
+}
+
+func respondWebhookError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, webhooks.ErrInvalidWebhook):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, webhooks.ErrWebhookNotFound):
+		c.JSON(404, gin.H{"error": "Webhook not found"})
+	default:
+		slog.Error(action, "error", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+	}
+}
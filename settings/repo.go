@@ -4,11 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paulmach/orb"
 )
 
+const privacyZoneIdPrefix = "pz"
+
+var ErrPrivacyZoneNotFound = fmt.Errorf("privacy zone not found")
+
 type Repo struct {
 	db *pgxpool.Pool
 	q  *db.Queries
@@ -35,3 +42,62 @@ func (r *Repo) SetUnitSettings(ctx context.Context, userID string, value json.Ra
 		Value:  value,
 	})
 }
+
+// PrivacyZone is an area where the user's tracks are masked when shown to
+// anyone else.
+type PrivacyZone struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Center       orb.Point `json:"center"`
+	RadiusMeters float64   `json:"radiusMeters"`
+}
+
+func (r *Repo) ListPrivacyZones(ctx context.Context, userID string) ([]PrivacyZone, error) {
+	zones, err := r.q.ListPrivacyZones(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PrivacyZone, 0, len(zones))
+	for _, z := range zones {
+		out = append(out, toPrivacyZone(z))
+	}
+	return out, nil
+}
+
+func (r *Repo) CreatePrivacyZone(ctx context.Context, userID string, zone PrivacyZone) (PrivacyZone, error) {
+	created, err := r.q.InsertPrivacyZone(ctx, db.InsertPrivacyZoneParams{
+		UserID:       userID,
+		Name:         zone.Name,
+		Lng:          zone.Center.Lon(),
+		Lat:          zone.Center.Lat(),
+		RadiusMeters: zone.RadiusMeters,
+	})
+	if err != nil {
+		return PrivacyZone{}, err
+	}
+	return toPrivacyZone(created), nil
+}
+
+func (r *Repo) DeletePrivacyZone(ctx context.Context, userID string, id string) error {
+	zoneID, err := ids.Unmarshal(privacyZoneIdPrefix, id)
+	if err != nil {
+		return ErrPrivacyZoneNotFound
+	}
+	n, err := r.q.DeletePrivacyZone(ctx, db.DeletePrivacyZoneParams{ID: zoneID, UserID: userID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPrivacyZoneNotFound
+	}
+	return nil
+}
+
+func toPrivacyZone(data db.PrivacyZone) PrivacyZone {
+	return PrivacyZone{
+		ID:           ids.Marshal(privacyZoneIdPrefix, data.ID),
+		Name:         data.Name,
+		Center:       orb.Point{data.Lng, data.Lat},
+		RadiusMeters: data.RadiusMeters,
+	}
+}
@@ -0,0 +1,689 @@
+package tracks
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/blobs"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxArchiveSize is the largest archive that can be imported. Exports from
+	// services like Strava are often hundreds of files.
+	MaxArchiveSize = 100 * 1024 * 1024
+	// MaxUploadedArchiveSize is the largest archive that can be imported
+	// with a chunked upload, which is read a chunk at a time rather than
+	// held in memory.
+	MaxUploadedArchiveSize = 1024 * 1024 * 1024
+
+	maxArchiveEntries = 2000
+	// Includes entries that aren't track files
+	maxArchiveFiles = 10000
+	// Unpacking stops once the entries add up to more than this many times
+	// the largest archive allowed
+	maxArchiveUnpackedFactor = 5
+	// Larger entries that compress better than this are assumed to be zip
+	// bombs. Track files usually compress around 10x.
+	maxArchiveCompressionRatio = 100
+	minArchiveRatioCheckSize   = 1024 * 1024
+
+	batchIdPrefix = "tib"
+)
+
+// archiveImportMaxAttempts is how many times unpacking an archive is tried
+// before the batch is marked failed. Each attempt resumes where the last
+// left off.
+const archiveImportMaxAttempts = 5
+
+// archiveImportTimeout is how long an attempt at unpacking an archive can
+// take. The largest archives have thousands of files.
+const archiveImportTimeout = 30 * time.Minute
+
+var archiveTrackExtensions = []string{"gpx", "kml", "fit", "tcx"}
+
+var ErrInvalidArchive = fmt.Errorf("invalid archive")
+var ErrImportBatchNotFound = fmt.Errorf("import batch not found")
+
+// ImportBatch is the imports of the files in an archive.
+type ImportBatch struct {
+	ID        string              `json:"id"`
+	OwnerID   string              `json:"ownerID"`
+	Filename  string              `json:"filename"`
+	StartedAt time.Time           `json:"startedAt"`
+	Progress  ImportBatchProgress `json:"progress"`
+	// UnpackedAt is set once every file in the archive is part of the
+	// batch. Until then the total in Progress can grow.
+	UnpackedAt *time.Time `json:"unpackedAt"`
+	// FailedAt is set if the archive couldn't be unpacked, along with
+	// Error. The files imported before it failed are kept.
+	FailedAt *time.Time `json:"failedAt"`
+	Error    string     `json:"error,omitempty"`
+	// DuplicateOfIDs are the earlier imports of files in the archive, which
+	// aren't imported again
+	DuplicateOfIDs []string `json:"duplicateOfIDs"`
+	// Skipped are the entries that aren't track files or couldn't be read
+	Skipped []SkippedArchiveEntry `json:"skipped"`
+	// Imports is only included when getting a single batch
+	Imports []Import `json:"imports,omitempty"`
+}
+
+type ImportBatchProgress struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+	Pending   int `json:"pending"`
+}
+
+type SkippedArchiveEntry struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type archiveEntry struct {
+	Name string
+	Data []byte
+}
+
+// ImportArchive creates a batch for the track files in a ZIP archive,
+// including gzipped ones, and schedules the archive to be unpacked. The
+// batch can be followed with GetImportBatch or import events. Importing the
+// same archive again gives the same batch, resuming it if it failed.
+func (r *Repo) ImportArchive(ctx context.Context, ownerID string, filename string, data []byte, opts ImportOptions) (ImportBatch, error) {
+	if len(data) > MaxArchiveSize {
+		return ImportBatch{}, ErrImportTooLarge
+	}
+	if _, err := openArchive(bytes.NewReader(data), int64(len(data))); err != nil {
+		return ImportBatch{}, err
+	}
+	hash := hashImport(ownerID, filename, data)
+	blobKey := importBatchBlobKey(hash)
+	return r.queueBatch(ctx, db.InsertTrackImportBatchParams{
+		OwnerID:        ownerID,
+		Filename:       filename,
+		Hash:           hash,
+		BlobKey:        &blobKey,
+		ByteSize:       int64(len(data)),
+		SkipDuplicates: opts.SkipDuplicates,
+	}, data)
+}
+
+// queueBatch creates a batch and schedules its archive to be unpacked. If
+// the archive was already sent the existing batch is returned instead, and
+// scheduled again if it failed. The archive is written to the blob store if
+// data is set.
+func (r *Repo) queueBatch(ctx context.Context, params db.InsertTrackImportBatchParams, data []byte) (ImportBatch, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return ImportBatch{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	// Locked so that a concurrent retry can't schedule it twice
+	var existing db.TrackImportBatch
+	if params.UploadID != nil {
+		existing, err = q.GetTrackImportBatchByUploadForUpdate(ctx, params.UploadID)
+	} else {
+		existing, err = q.GetTrackImportBatchByHashForUpdate(ctx, params.Hash)
+	}
+	found := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return ImportBatch{}, err
+	}
+	if found && !existing.FailedAt.Valid {
+		return r.importBatchWith(ctx, q, existing.ID)
+	}
+
+	if data != nil {
+		// Written first so the batch never refers to a missing blob
+		if err := r.blobs.Put(ctx, *params.BlobKey, data); err != nil {
+			return ImportBatch{}, err
+		}
+	}
+
+	batchID := existing.ID
+	if found {
+		err = q.ResetTrackImportBatchFailure(ctx, db.ResetTrackImportBatchFailureParams{
+			ID:             batchID,
+			SkipDuplicates: params.SkipDuplicates,
+		})
+	} else {
+		batchID, err = q.InsertTrackImportBatch(ctx, params)
+	}
+	if err != nil {
+		return ImportBatch{}, err
+	}
+
+	if _, err := r.river.InsertTx(ctx, tx, &ArchiveImportArgs{BatchID: batchID}, nil); err != nil {
+		return ImportBatch{}, err
+	}
+
+	out, err := r.importBatchWith(ctx, q, batchID)
+	if err != nil {
+		return ImportBatch{}, err
+	}
+	return out, tx.Commit(ctx)
+}
+
+// unpackBatch imports each track file in the archive of the batch. The files
+// an earlier attempt imported are passed over, so a retry resumes where it
+// left off.
+func (r *Repo) unpackBatch(ctx context.Context, batch db.TrackImportBatch) error {
+	ra, maxSize, err := r.openBatchArchive(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	duplicateOfIDs := make([]int64, 0)
+	skipped, err := unpackArchive(ra, batch.ByteSize, maxArchiveUnpackedFactor*maxSize, func(entry archiveEntry) error {
+		hash := hashImport(batch.OwnerID, entry.Name, entry.Data)
+		err := r.importArchiveEntry(ctx, batch, hash, entry)
+		if err == nil || !isDuplicateImport(err) {
+			return err
+		}
+		existingID, err := r.q.GetTrackImportIDByContentHash(ctx, hash)
+		if err != nil {
+			return err
+		}
+		existing, err := r.q.GetTrackImport(ctx, existingID)
+		if err != nil {
+			return err
+		}
+		if existing.BatchID != nil && *existing.BatchID == batch.ID {
+			// Imported by an earlier attempt
+			return nil
+		}
+		duplicateOfIDs = append(duplicateOfIDs, existingID)
+		return nil
+	})
+	if errors.Is(err, ErrInvalidArchive) {
+		return permanentImportError{err: err, message: err.Error()}
+	} else if err != nil {
+		return err
+	}
+
+	progress, err := getBatchProgress(ctx, r.q, batch.ID)
+	if err != nil {
+		return err
+	}
+	if progress.Total == 0 && len(duplicateOfIDs) == 0 {
+		err := fmt.Errorf("%w: no track files found", ErrInvalidArchive)
+		return permanentImportError{err: err, message: err.Error()}
+	}
+
+	skippedJSON, err := json.Marshal(skipped)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	n, err := q.SetTrackImportBatchUnpacked(ctx, db.SetTrackImportBatchUnpackedParams{
+		ID:             batch.ID,
+		Skipped:        skippedJSON,
+		DuplicateOfIds: duplicateOfIDs,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	event, err := withBatchProgress(ctx, q, &batch.ID, ImportEvent{Status: ImportQueued})
+	if err != nil {
+		return err
+	}
+	if err := notifyImportEvent(ctx, q, batch.OwnerID, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.releaseBatchArchive(ctx, batch)
+	return nil
+}
+
+// importArchiveEntry imports a file from an archive in a transaction of its
+// own, so that the files before are kept if the archive fails partway.
+func (r *Repo) importArchiveEntry(ctx context.Context, batch db.TrackImportBatch, hash []byte, entry archiveEntry) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = r.insertImport(ctx, tx, db.InsertTrackImportParams{
+		OwnerID:        batch.OwnerID,
+		Hash:           hash,
+		Filename:       entry.Name,
+		SkipDuplicates: batch.SkipDuplicates,
+		BatchID:        &batch.ID,
+	}, entry.Data)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// markBatchFailed records that the archive of the batch can never be
+// unpacked and tells the user.
+func (r *Repo) markBatchFailed(ctx context.Context, batch db.TrackImportBatch, message string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	n, err := q.MarkTrackImportBatchFailed(ctx, db.MarkTrackImportBatchFailedParams{
+		ID:    batch.ID,
+		Error: &message,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	event, err := withBatchProgress(ctx, q, &batch.ID, ImportEvent{
+		Status: ImportFailed,
+		Error:  message,
+	})
+	if err != nil {
+		return err
+	}
+	if err := notifyImportEvent(ctx, q, batch.OwnerID, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	// An upload is kept until it expires, so that finishing it can be
+	// retried, but an archive sent directly is sent again
+	if batch.UploadID == nil {
+		r.releaseBatchArchive(ctx, batch)
+	}
+	return nil
+}
+
+// openBatchArchive reads the archive of the batch from wherever it was
+// stored, returning the largest size an archive stored there can be.
+func (r *Repo) openBatchArchive(ctx context.Context, batch db.TrackImportBatch) (io.ReaderAt, int64, error) {
+	if batch.UploadID != nil {
+		ra, err := r.openUpload(ctx, *batch.UploadID, batch.ByteSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		return ra, MaxUploadedArchiveSize, nil
+	}
+	if batch.BlobKey == nil {
+		return nil, 0, permanentImportError{err: fmt.Errorf("batch %d has no archive", batch.ID)}
+	}
+	data, err := r.blobs.Get(ctx, *batch.BlobKey)
+	if errors.Is(err, blobs.ErrNotFound) {
+		return nil, 0, permanentImportError{err: fmt.Errorf("get archive: %w", err)}
+	} else if err != nil {
+		return nil, 0, fmt.Errorf("get archive: %w", err)
+	}
+	return bytes.NewReader(data), MaxArchiveSize, nil
+}
+
+// releaseBatchArchive deletes the archive of the batch once it is no longer
+// needed. A failure only leaves an orphaned blob behind.
+func (r *Repo) releaseBatchArchive(ctx context.Context, batch db.TrackImportBatch) {
+	if batch.UploadID != nil {
+		if err := deleteUpload(ctx, r.pool, r.blobs, *batch.UploadID); err != nil {
+			slog.Warn("delete unpacked upload", "upload", *batch.UploadID, "error", err)
+		}
+		return
+	}
+	r.deleteBlobs(ctx, batch.BlobKey)
+}
+
+// importBatchBlobKey is where an archive sent directly is stored until it is
+// unpacked.
+func importBatchBlobKey(hash []byte) string {
+	return fmt.Sprintf("track-import-batches/%x", hash)
+}
+
+type ArchiveImportArgs struct {
+	BatchID int64 `json:"batchID"`
+}
+
+func (ArchiveImportArgs) Kind() string { return "tracks_archive_import" }
+
+func (ArchiveImportArgs) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{
+		MaxAttempts: archiveImportMaxAttempts,
+		UniqueOpts: river.UniqueOpts{
+			ByArgs: true,
+			// Unlike the default, a completed job doesn't prevent a failed
+			// batch from being retried
+			ByState: []rivertype.JobState{
+				rivertype.JobStateAvailable,
+				rivertype.JobStateRunning,
+				rivertype.JobStateRetryable,
+				rivertype.JobStateScheduled,
+			},
+		},
+	}
+}
+
+// ArchiveImportWorker unpacks the archive of a batch, importing each of its
+// files.
+type ArchiveImportWorker struct {
+	db    *pgxpool.Pool
+	blobs BlobStore
+	river.WorkerDefaults[ArchiveImportArgs]
+}
+
+func AddArchiveImportWorker(workers *river.Workers, db *pgxpool.Pool, blobs BlobStore) {
+	river.AddWorker[ArchiveImportArgs](workers, &ArchiveImportWorker{db: db, blobs: blobs})
+}
+
+func (w *ArchiveImportWorker) Timeout(*river.Job[ArchiveImportArgs]) time.Duration {
+	return archiveImportTimeout
+}
+
+func (w *ArchiveImportWorker) Work(ctx context.Context, job *river.Job[ArchiveImportArgs]) error {
+	l := slog.With("job", job.ID, "batch", job.Args.BatchID)
+	repo := NewRepo(w.db, river.ClientFromContext[pgx.Tx](ctx), w.blobs)
+
+	batch, err := repo.q.GetTrackImportBatch(ctx, job.Args.BatchID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Info("batch deleted")
+			return nil
+		}
+		return err
+	}
+	if batch.UnpackedAt.Valid || batch.FailedAt.Valid {
+		l.Info("already done")
+		return nil
+	}
+
+	err = repo.unpackBatch(ctx, batch)
+	if err == nil {
+		l.Info("unpacked archive")
+		return nil
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	message, permanent := classifyImportError(err)
+	if !permanent && job.Attempt < job.MaxAttempts {
+		l.Warn("unpack archive attempt failed", "attempt", job.Attempt, "error", err)
+		return err
+	}
+	l.Info("unpack archive failed", "error", err)
+	if err := repo.markBatchFailed(ctx, batch, message); err != nil {
+		return err
+	}
+	return river.JobCancel(err)
+}
+
+// GetImportBatch gets a batch by the ID ImportArchive returned, including
+// its imports.
+func (r *Repo) GetImportBatch(ctx context.Context, id string) (ImportBatch, error) {
+	batchID, err := ids.Unmarshal(batchIdPrefix, id)
+	if err != nil {
+		return ImportBatch{}, ErrImportBatchNotFound
+	}
+
+	out, err := r.importBatchWith(ctx, r.q, batchID)
+	if err != nil {
+		return ImportBatch{}, err
+	}
+
+	rows, err := r.q.ListTrackImportBatchImports(ctx, &batchID)
+	if err != nil {
+		return ImportBatch{}, err
+	}
+	out.Imports = make([]Import, 0, len(rows))
+	for _, i := range rows {
+		out.Imports = append(out.Imports, Import{
+			ID:          ids.MarshalHash(importIdPrefix, i.Hash),
+			OwnerID:     i.OwnerID,
+			StartedAt:   i.InsertedAt.Time,
+			CompletedAt: pgTimestampToNullable(i.CompletedAt),
+			FailedAt:    pgTimestampToNullable(i.FailedAt),
+			CancelledAt: pgTimestampToNullable(i.CancelledAt),
+			Error:       stringFromNullable(i.Error),
+			Filename:    i.Filename,
+			ByteSize:    int(i.ByteSize),
+			Attempts:    int(i.Attempts),
+			BatchID:     marshalBatchID(i.BatchID),
+
+			SkippedDuplicateOfIDs: marshalTrackIDs(i.SkippedDuplicateOfIds),
+		})
+	}
+	return out, nil
+}
+
+func (r *Repo) importBatchWith(ctx context.Context, q *db.Queries, batchID int64) (ImportBatch, error) {
+	batch, err := q.GetTrackImportBatch(ctx, batchID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ImportBatch{}, ErrImportBatchNotFound
+		}
+		return ImportBatch{}, err
+	}
+	progress, err := getBatchProgress(ctx, q, batchID)
+	if err != nil {
+		return ImportBatch{}, err
+	}
+	duplicateHashes, err := q.ListTrackImportHashes(ctx, batch.DuplicateOfIds)
+	if err != nil {
+		return ImportBatch{}, err
+	}
+	duplicateOfIDs := make([]string, 0, len(duplicateHashes))
+	for _, hash := range duplicateHashes {
+		duplicateOfIDs = append(duplicateOfIDs, ids.MarshalHash(importIdPrefix, hash))
+	}
+	skipped := make([]SkippedArchiveEntry, 0)
+	if batch.Skipped != nil {
+		if err := json.Unmarshal(batch.Skipped, &skipped); err != nil {
+			return ImportBatch{}, err
+		}
+	}
+	return ImportBatch{
+		ID:             ids.Marshal(batchIdPrefix, batch.ID),
+		OwnerID:        batch.OwnerID,
+		Filename:       batch.Filename,
+		StartedAt:      batch.InsertedAt.Time,
+		Progress:       progress,
+		UnpackedAt:     pgTimestamptzToNullable(batch.UnpackedAt),
+		FailedAt:       pgTimestamptzToNullable(batch.FailedAt),
+		Error:          stringFromNullable(batch.Error),
+		DuplicateOfIDs: duplicateOfIDs,
+		Skipped:        skipped,
+	}, nil
+}
+
+func getBatchProgress(ctx context.Context, q *db.Queries, batchID int64) (ImportBatchProgress, error) {
+	row, err := q.GetTrackImportBatchProgress(ctx, &batchID)
+	if err != nil {
+		return ImportBatchProgress{}, err
+	}
+	return ImportBatchProgress{
+		Total:     int(row.Total),
+		Completed: int(row.Completed),
+		Failed:    int(row.Failed),
+		Cancelled: int(row.Cancelled),
+		Pending:   int(row.Total - row.Completed - row.Failed - row.Cancelled),
+	}, nil
+}
+
+// withBatchProgress adds the progress of the batch the import is part of to
+// an event about it.
+func withBatchProgress(ctx context.Context, q *db.Queries, batchID *int64, event ImportEvent) (ImportEvent, error) {
+	if batchID == nil {
+		return event, nil
+	}
+	progress, err := getBatchProgress(ctx, q, *batchID)
+	if err != nil {
+		return ImportEvent{}, err
+	}
+	event.BatchID = ids.Marshal(batchIdPrefix, *batchID)
+	event.Batch = &progress
+	return event, nil
+}
+
+func marshalBatchID(batchID *int64) string {
+	if batchID == nil {
+		return ""
+	}
+	return ids.Marshal(batchIdPrefix, *batchID)
+}
+
+// unpackArchive calls visit with each track file in the archive, returning
+// the entries it skipped. The whole archive is rejected if it looks like a
+// zip bomb.
+func unpackArchive(ra io.ReaderAt, size int64, maxUnpackedSize int64, visit func(archiveEntry) error) ([]SkippedArchiveEntry, error) {
+	src := &archiveSource{ra: ra}
+	zr, err := openArchive(src, size)
+	if err != nil {
+		if src.err != nil {
+			return nil, src.err
+		}
+		return nil, err
+	}
+
+	skipped := make([]SkippedArchiveEntry, 0)
+	var entries int
+	var unpackedSize int64
+	for _, f := range zr.File {
+		name := path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		gzipped := strings.HasSuffix(strings.ToLower(name), ".gz")
+		if gzipped {
+			name = name[:len(name)-len(".gz")]
+		}
+		ext := strings.ToLower(path.Ext(name))
+		if !slices.Contains(archiveTrackExtensions, strings.TrimPrefix(ext, ".")) {
+			skipped = append(skipped, SkippedArchiveEntry{Name: f.Name, Reason: "Not a track file"})
+			continue
+		}
+
+		entries++
+		if entries > maxArchiveEntries {
+			return nil, fmt.Errorf("%w: more than %d track files", ErrInvalidArchive, maxArchiveEntries)
+		}
+
+		entryData, err := readArchiveEntry(f, gzipped)
+		if src.err != nil {
+			// Not a problem with the entry, so it shouldn't be skipped
+			return nil, src.err
+		} else if errors.Is(err, ErrImportTooLarge) {
+			skipped = append(skipped, SkippedArchiveEntry{Name: f.Name, Reason: "File too large"})
+			continue
+		} else if err != nil {
+			skipped = append(skipped, SkippedArchiveEntry{Name: f.Name, Reason: "Could not be read"})
+			continue
+		}
+
+		if len(entryData) >= minArchiveRatioCheckSize &&
+			uint64(len(entryData)) > maxArchiveCompressionRatio*f.CompressedSize64 {
+			return nil, fmt.Errorf("%w: suspicious compression ratio", ErrInvalidArchive)
+		}
+		unpackedSize += int64(len(entryData))
+		if unpackedSize > maxUnpackedSize {
+			return nil, fmt.Errorf("%w: too large when unpacked", ErrInvalidArchive)
+		}
+
+		if err := visit(archiveEntry{Name: name, Data: entryData}); err != nil {
+			return nil, err
+		}
+	}
+	return skipped, nil
+}
+
+// openArchive reads the directory of a ZIP archive, which is quick enough to
+// check before the archive is unpacked.
+func openArchive(ra io.ReaderAt, size int64) (*zip.Reader, error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a zip file", ErrInvalidArchive)
+	}
+	if len(zr.File) > maxArchiveFiles {
+		return nil, fmt.Errorf("%w: more than %d files", ErrInvalidArchive, maxArchiveFiles)
+	}
+	return zr, nil
+}
+
+// archiveSource records the first error reading the archive itself, such as
+// from the blob store, so that it isn't mistaken for a corrupt entry.
+type archiveSource struct {
+	ra  io.ReaderAt
+	err error
+}
+
+func (s *archiveSource) ReadAt(p []byte, off int64) (int, error) {
+	n, err := s.ra.ReadAt(p, off)
+	if err != nil && err != io.EOF && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// readArchiveEntry unpacks an entry, stopping as soon as it is known to be
+// too large rather than trusting the size in the header.
+func readArchiveEntry(f *zip.File, gzipped bool) ([]byte, error) {
+	if f.UncompressedSize64 > maxImportSize && !gzipped {
+		return nil, ErrImportTooLarge
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if gzipped {
+		gr, err := gzip.NewReader(rc)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportSize {
+		return nil, ErrImportTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,217 @@
+package tracks
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/dzfranklin/plantopo-api/blobs"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/riverqueue/river/rivertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func makeZip(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func unpackTestArchive(data []byte, visit func(archiveEntry) error) ([]SkippedArchiveEntry, error) {
+	return unpackArchive(bytes.NewReader(data), int64(len(data)), maxArchiveUnpackedFactor*MaxArchiveSize, visit)
+}
+
+func TestUnpackArchive(t *testing.T) {
+	data := makeZip(t, map[string][]byte{
+		"route.gpx":                   sampleGPX(),
+		"activities/123.fit.gz":       gzipBytes(t, []byte("fit data")),
+		"activities.csv":              []byte("id,name"),
+		"__MACOSX/._route.gpx":        []byte("resource fork"),
+		"activities/.hidden.gpx":      []byte("hidden"),
+		"activities/too-large.gpx":    make([]byte, maxImportSize+1),
+		"activities/invalid.gpx.gz":   []byte("not gzip"),
+		"activities/nested/other.GPX": sampleGPX(),
+	})
+
+	got := make(map[string][]byte)
+	skipped, err := unpackTestArchive(data, func(entry archiveEntry) error {
+		got[entry.Name] = entry.Data
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]byte{
+		"route.gpx": sampleGPX(),
+		"123.fit":   []byte("fit data"),
+		"other.GPX": sampleGPX(),
+	}, got)
+	assert.ElementsMatch(t, []SkippedArchiveEntry{
+		{Name: "activities.csv", Reason: "Not a track file"},
+		{Name: "activities/too-large.gpx", Reason: "File too large"},
+		{Name: "activities/invalid.gpx.gz", Reason: "Could not be read"},
+	}, skipped)
+}
+
+func TestUnpackArchiveRejectsZipBomb(t *testing.T) {
+	data := makeZip(t, map[string][]byte{
+		"bomb.gpx.gz": gzipBytes(t, make([]byte, maxImportSize)),
+	})
+	_, err := unpackTestArchive(data, func(entry archiveEntry) error {
+		t.Fatal("should not visit")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+}
+
+func TestUnpackArchiveRejectsTooLargeUnpacked(t *testing.T) {
+	data := makeZip(t, map[string][]byte{
+		"a.gpx": sampleGPX(),
+		"b.gpx": sampleGPX(),
+	})
+	_, err := unpackArchive(bytes.NewReader(data), int64(len(data)), int64(len(sampleGPX())+1), func(entry archiveEntry) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+}
+
+func TestUnpackArchiveRejectsNonZip(t *testing.T) {
+	_, err := unpackTestArchive(sampleGPX(), func(entry archiveEntry) error { return nil })
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+}
+
+func unpackTestBatch(t *testing.T, r *Repo, id string) error {
+	t.Helper()
+	ctx := context.Background()
+	batchID, err := ids.Unmarshal(batchIdPrefix, id)
+	require.NoError(t, err)
+	batch, err := r.q.GetTrackImportBatch(ctx, batchID)
+	require.NoError(t, err)
+	return r.unpackBatch(ctx, batch)
+}
+
+func TestImportArchive(t *testing.T) {
+	ctx := context.Background()
+	driver, r := newSubjectWithDriver(t)
+
+	other := sampleGPX()
+	other = append(other, '\n')
+	data := makeZip(t, map[string][]byte{
+		"a.gpx":     sampleGPX(),
+		"b.gpx":     other,
+		"notes.txt": []byte("notes"),
+	})
+
+	got, err := r.ImportArchive(ctx, "user_1", "export.zip", data, ImportOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, got.UnpackedAt)
+	assert.Equal(t, 0, got.Progress.Total)
+	batchID, err := ids.Unmarshal(batchIdPrefix, got.ID)
+	require.NoError(t, err)
+	rivertest.RequireInserted(ctx, t, driver, &ArchiveImportArgs{BatchID: batchID}, nil)
+
+	// Sending the same archive again gives the same batch
+	again, err := r.ImportArchive(ctx, "user_1", "export.zip", data, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, got.ID, again.ID)
+
+	require.NoError(t, unpackTestBatch(t, r, got.ID))
+	batch, err := r.GetImportBatch(ctx, got.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "export.zip", batch.Filename)
+	assert.NotNil(t, batch.UnpackedAt)
+	assert.Equal(t, 2, batch.Progress.Total)
+	assert.Equal(t, 2, batch.Progress.Pending)
+	assert.Empty(t, batch.DuplicateOfIDs)
+	assert.Len(t, batch.Skipped, 1)
+	require.Len(t, batch.Imports, 2)
+	assert.Equal(t, got.ID, batch.Imports[0].BatchID)
+
+	// The archive is deleted once unpacked
+	_, err = r.blobs.Get(ctx, importBatchBlobKey(hashImport("user_1", "export.zip", data)))
+	assert.ErrorIs(t, err, blobs.ErrNotFound)
+
+	third := append(other, '\n')
+	mixed, err := r.ImportArchive(ctx, "user_1", "export2.zip", makeZip(t, map[string][]byte{
+		"a.gpx": sampleGPX(),
+		"c.gpx": third,
+	}), ImportOptions{})
+	require.NoError(t, err)
+	require.NoError(t, unpackTestBatch(t, r, mixed.ID))
+	mixed, err = r.GetImportBatch(ctx, mixed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mixed.Progress.Total)
+	assert.Len(t, mixed.DuplicateOfIDs, 1)
+
+	_, err = r.ImportArchive(ctx, "user_1", "invalid.zip", sampleGPX(), ImportOptions{})
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	empty, err := r.ImportArchive(ctx, "user_1", "empty.zip", makeZip(t, map[string][]byte{"notes.txt": nil}), ImportOptions{})
+	require.NoError(t, err)
+	err = unpackTestBatch(t, r, empty.ID)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+	_, permanent := classifyImportError(err)
+	assert.True(t, permanent)
+}
+
+func TestImportArchiveResumes(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	other := sampleGPX()
+	other = append(other, '\n')
+	data := makeZip(t, map[string][]byte{
+		"a.gpx": sampleGPX(),
+		"b.gpx": other,
+	})
+
+	got, err := r.ImportArchive(ctx, "user_1", "export.zip", data, ImportOptions{})
+	require.NoError(t, err)
+	batchID, err := ids.Unmarshal(batchIdPrefix, got.ID)
+	require.NoError(t, err)
+	batch, err := r.q.GetTrackImportBatch(ctx, batchID)
+	require.NoError(t, err)
+
+	// An earlier attempt imported one file before failing
+	entry := archiveEntry{Name: "a.gpx", Data: sampleGPX()}
+	require.NoError(t, r.importArchiveEntry(ctx, batch, hashImport("user_1", entry.Name, entry.Data), entry))
+	require.NoError(t, r.markBatchFailed(ctx, batch, "Something went wrong"))
+
+	failed, err := r.GetImportBatch(ctx, got.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, failed.FailedAt)
+	assert.Equal(t, "Something went wrong", failed.Error)
+	assert.Equal(t, 1, failed.Progress.Total)
+
+	// Sending the archive again resumes the batch
+	again, err := r.ImportArchive(ctx, "user_1", "export.zip", data, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, got.ID, again.ID)
+	assert.Nil(t, again.FailedAt)
+
+	require.NoError(t, unpackTestBatch(t, r, got.ID))
+	resumed, err := r.GetImportBatch(ctx, got.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, resumed.UnpackedAt)
+	assert.Equal(t, 2, resumed.Progress.Total)
+	assert.Empty(t, resumed.DuplicateOfIDs)
+}
@@ -0,0 +1,304 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/jackc/pgx/v5"
+	"slices"
+	"time"
+)
+
+const collectionIdPrefix = "c"
+
+var ErrCollectionNotFound = fmt.Errorf("collection not found")
+var ErrInvalidCollection = fmt.Errorf("invalid collection")
+
+// Collection is a named grouping of a user's tracks in a manual order.
+type Collection struct {
+	ID           string    `json:"id"`
+	OwnerID      string    `json:"ownerID"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description,omitempty"`
+	CoverTrackID string    `json:"coverTrackID,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	TrackIDs     []string  `json:"trackIDs"`
+}
+
+type CollectionInput struct {
+	Name         string `json:"name" binding:"required"`
+	Description  string `json:"description"`
+	CoverTrackID string `json:"coverTrackID"`
+}
+
+func (r *Repo) ListMyCollections(ctx context.Context, userID string) ([]Collection, error) {
+	collections, err := r.q.ListCollections(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Collection, 0, len(collections))
+	for _, row := range collections {
+		out = append(out, toCollection(row.Collection, row.TrackIds))
+	}
+	return out, nil
+}
+
+func (r *Repo) GetCollection(ctx context.Context, id string) (Collection, error) {
+	cID, err := ids.Unmarshal(collectionIdPrefix, id)
+	if err != nil {
+		return Collection{}, ErrCollectionNotFound
+	}
+	return r.getCollection(ctx, r.q, cID)
+}
+
+func (r *Repo) getCollection(ctx context.Context, q *db.Queries, cID int64) (Collection, error) {
+	c, err := q.GetCollection(ctx, cID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Collection{}, ErrCollectionNotFound
+		}
+		return Collection{}, err
+	}
+	trackIDs, err := q.ListCollectionTrackIDs(ctx, cID)
+	if err != nil {
+		return Collection{}, err
+	}
+	return toCollection(c, trackIDs), nil
+}
+
+func (r *Repo) IsCollectionOwner(ctx context.Context, userID string, id string) (bool, error) {
+	cID, err := ids.Unmarshal(collectionIdPrefix, id)
+	if err != nil {
+		return false, nil
+	}
+	owner, err := r.q.GetCollectionOwner(ctx, cID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return owner == userID, nil
+}
+
+func (r *Repo) CreateCollection(ctx context.Context, ownerID string, input CollectionInput) (Collection, error) {
+	if input.CoverTrackID != "" {
+		return Collection{}, fmt.Errorf("%w: a new collection has no tracks to use as a cover", ErrInvalidCollection)
+	}
+	c, err := r.q.InsertCollection(ctx, db.InsertCollectionParams{
+		OwnerID:     ownerID,
+		Name:        input.Name,
+		Description: input.Description,
+	})
+	if err != nil {
+		return Collection{}, err
+	}
+	return toCollection(c, nil), nil
+}
+
+// UpdateCollection replaces the details of the collection. The cover track
+// must be a member of the collection.
+func (r *Repo) UpdateCollection(ctx context.Context, id string, input CollectionInput) (Collection, error) {
+	cID, err := ids.Unmarshal(collectionIdPrefix, id)
+	if err != nil {
+		return Collection{}, ErrCollectionNotFound
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Collection{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	var coverID *int64
+	if input.CoverTrackID != "" {
+		tID, err := ids.Unmarshal(trackIdPrefix, input.CoverTrackID)
+		if err != nil {
+			return Collection{}, fmt.Errorf("%w: invalid cover track", ErrInvalidCollection)
+		}
+		members, err := q.ListCollectionTrackIDs(ctx, cID)
+		if err != nil {
+			return Collection{}, err
+		}
+		if !slices.Contains(members, tID) {
+			return Collection{}, fmt.Errorf("%w: cover track is not in the collection", ErrInvalidCollection)
+		}
+		coverID = &tID
+	}
+
+	_, err = q.UpdateCollection(ctx, db.UpdateCollectionParams{
+		ID:           cID,
+		Name:         input.Name,
+		Description:  input.Description,
+		CoverTrackID: coverID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Collection{}, ErrCollectionNotFound
+		}
+		return Collection{}, err
+	}
+
+	c, err := r.getCollection(ctx, q, cID)
+	if err != nil {
+		return Collection{}, err
+	}
+	return c, tx.Commit(ctx)
+}
+
+func (r *Repo) DeleteCollection(ctx context.Context, id string) error {
+	cID, err := ids.Unmarshal(collectionIdPrefix, id)
+	if err != nil {
+		return ErrCollectionNotFound
+	}
+	return r.q.DeleteCollection(ctx, cID)
+}
+
+// AddToCollection appends the track to the end of the collection. Adding a
+// track that is already a member does nothing.
+func (r *Repo) AddToCollection(ctx context.Context, id string, trackID string) (Collection, error) {
+	cID, err := ids.Unmarshal(collectionIdPrefix, id)
+	if err != nil {
+		return Collection{}, ErrCollectionNotFound
+	}
+	tID, err := ids.Unmarshal(trackIdPrefix, trackID)
+	if err != nil {
+		return Collection{}, ErrTrackNotFound
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Collection{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	// Serialize appends so positions aren't duplicated
+	if err := q.LockCollection(ctx, cID); err != nil {
+		return Collection{}, err
+	}
+
+	err = q.AddCollectionTrack(ctx, db.AddCollectionTrackParams{CollectionID: cID, TrackID: tID})
+	if err != nil {
+		return Collection{}, err
+	}
+
+	c, err := r.getCollection(ctx, q, cID)
+	if err != nil {
+		return Collection{}, err
+	}
+	return c, tx.Commit(ctx)
+}
+
+func (r *Repo) RemoveFromCollection(ctx context.Context, id string, trackID string) (Collection, error) {
+	cID, err := ids.Unmarshal(collectionIdPrefix, id)
+	if err != nil {
+		return Collection{}, ErrCollectionNotFound
+	}
+	tID, err := ids.Unmarshal(trackIdPrefix, trackID)
+	if err != nil {
+		return Collection{}, ErrTrackNotFound
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Collection{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	n, err := q.RemoveCollectionTrack(ctx, db.RemoveCollectionTrackParams{CollectionID: cID, TrackID: tID})
+	if err != nil {
+		return Collection{}, err
+	}
+	if n == 0 {
+		return Collection{}, ErrTrackNotFound
+	}
+
+	c, err := r.getCollection(ctx, q, cID)
+	if err != nil {
+		return Collection{}, err
+	}
+	if c.CoverTrackID == trackID {
+		_, err = q.UpdateCollection(ctx, db.UpdateCollectionParams{
+			ID:          cID,
+			Name:        c.Name,
+			Description: c.Description,
+		})
+		if err != nil {
+			return Collection{}, err
+		}
+		c.CoverTrackID = ""
+	}
+	return c, tx.Commit(ctx)
+}
+
+// ReorderCollection sets the manual order of the collection. The track IDs
+// must be exactly the current members.
+func (r *Repo) ReorderCollection(ctx context.Context, id string, trackIDs []string) (Collection, error) {
+	cID, err := ids.Unmarshal(collectionIdPrefix, id)
+	if err != nil {
+		return Collection{}, ErrCollectionNotFound
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Collection{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	members, err := q.ListCollectionTrackIDs(ctx, cID)
+	if err != nil {
+		return Collection{}, err
+	}
+	if len(members) != len(trackIDs) {
+		return Collection{}, fmt.Errorf("%w: order must list every track in the collection once", ErrInvalidCollection)
+	}
+
+	seen := make(map[int64]struct{}, len(trackIDs))
+	for i, trackID := range trackIDs {
+		tID, err := ids.Unmarshal(trackIdPrefix, trackID)
+		if err != nil {
+			return Collection{}, fmt.Errorf("%w: invalid track", ErrInvalidCollection)
+		}
+		if _, ok := seen[tID]; ok || !slices.Contains(members, tID) {
+			return Collection{}, fmt.Errorf("%w: order must list every track in the collection once", ErrInvalidCollection)
+		}
+		seen[tID] = struct{}{}
+
+		err = q.SetCollectionTrackPosition(ctx, db.SetCollectionTrackPositionParams{
+			CollectionID: cID,
+			TrackID:      tID,
+			Position:     int32(i),
+		})
+		if err != nil {
+			return Collection{}, err
+		}
+	}
+
+	c, err := r.getCollection(ctx, q, cID)
+	if err != nil {
+		return Collection{}, err
+	}
+	return c, tx.Commit(ctx)
+}
+
+func toCollection(data db.Collection, trackIDs []int64) Collection {
+	out := Collection{
+		ID:           ids.Marshal(collectionIdPrefix, data.ID),
+		OwnerID:      data.OwnerID,
+		Name:         data.Name,
+		Description:  data.Description,
+		CoverTrackID: ids.MarshalNullable(trackIdPrefix, data.CoverTrackID),
+		CreatedAt:    data.CreatedAt.Time,
+		TrackIDs:     make([]string, 0, len(trackIDs)),
+	}
+	for _, tID := range trackIDs {
+		out.TrackIDs = append(out.TrackIDs, ids.Marshal(trackIdPrefix, tID))
+	}
+	return out
+}
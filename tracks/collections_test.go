@@ -0,0 +1,90 @@
+package tracks
+
+import (
+	"context"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCollectionMembershipAndOrder(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	var trackIDs []string
+	for _, ts := range []string{"2024-06-12T09:00:00Z", "2024-06-13T09:00:00Z", "2024-06-14T09:00:00Z"} {
+		f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}})
+		f.Properties.CoordinateProperties()["times"] = []interface{}{ts, ts}
+		trackIDs = append(trackIDs, insertTestTrack(t, r, "user_1", *f))
+	}
+
+	c, err := r.CreateCollection(ctx, "user_1", CollectionInput{Name: "Munros"})
+	require.NoError(t, err)
+
+	for _, id := range trackIDs {
+		c, err = r.AddToCollection(ctx, c.ID, id)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, trackIDs, c.TrackIDs)
+
+	reordered := []string{trackIDs[2], trackIDs[0], trackIDs[1]}
+	c, err = r.ReorderCollection(ctx, c.ID, reordered)
+	require.NoError(t, err)
+	assert.Equal(t, reordered, c.TrackIDs)
+
+	_, err = r.ReorderCollection(ctx, c.ID, trackIDs[:2])
+	require.ErrorIs(t, err, ErrInvalidCollection)
+
+	byPosition, err := r.ListMyTracks(ctx, "user_1", ListOptions{OrderBy: OrderByPosition, CollectionID: c.ID})
+	require.NoError(t, err)
+	require.Len(t, byPosition, 3)
+	assert.Equal(t, reordered[0], byPosition[0].ID)
+
+	c, err = r.UpdateCollection(ctx, c.ID, CollectionInput{Name: "Munros", CoverTrackID: trackIDs[0]})
+	require.NoError(t, err)
+	assert.Equal(t, trackIDs[0], c.CoverTrackID)
+
+	require.NoError(t, r.Delete(ctx, trackIDs[0]))
+
+	c, err = r.GetCollection(ctx, c.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{trackIDs[2], trackIDs[1]}, c.TrackIDs)
+	assert.Empty(t, c.CoverTrackID)
+}
+
+func TestSplitKeepsCollectionOrder(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}, {-4.0, 56.002}, {-4.0, 56.003}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:01:00Z",
+		"2024-06-12T09:02:00Z",
+		"2024-06-12T09:03:00Z",
+	}
+	first := insertTestTrack(t, r, "user_1", *f)
+
+	g := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}})
+	g.Properties.CoordinateProperties()["times"] = []interface{}{"2024-06-13T09:00:00Z", "2024-06-13T09:01:00Z"}
+	last := insertTestTrack(t, r, "user_1", *g)
+
+	c, err := r.CreateCollection(ctx, "user_1", CollectionInput{Name: "Munros"})
+	require.NoError(t, err)
+	_, err = r.AddToCollection(ctx, c.ID, first)
+	require.NoError(t, err)
+	_, err = r.AddToCollection(ctx, c.ID, last)
+	require.NoError(t, err)
+
+	parts, err := r.SplitAtTime(ctx, first, time.Date(2024, 6, 12, 9, 1, 30, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+
+	list, err := r.ListMyCollections(ctx, "user_1")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, []string{first, parts[1].ID, last}, list[0].TrackIDs)
+}
@@ -0,0 +1,127 @@
+package tracks
+
+import (
+	"context"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/analysis"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"math"
+	"time"
+)
+
+// Tracks are only compared if they start within this distance of each other
+const duplicateMaxStartMeters = 2000
+
+var ErrDuplicateNotFound = fmt.Errorf("duplicate not found")
+
+// ImportOptions configures how an import is processed.
+type ImportOptions struct {
+	// SkipDuplicates skips tracks that are likely duplicates of one the user
+	// already has. Otherwise they are imported and flagged for the user to
+	// resolve.
+	SkipDuplicates bool
+}
+
+// SuspectedDuplicate is a track flagged as likely being a second copy of
+// another, for example the same activity exported from two apps.
+type SuspectedDuplicate struct {
+	TrackID         string    `json:"trackID"`
+	TrackName       string    `json:"trackName"`
+	DuplicateOfID   string    `json:"duplicateOfID"`
+	DuplicateOfName string    `json:"duplicateOfName"`
+	FrechetMeters   float64   `json:"frechetMeters"`
+	DetectedAt      time.Time `json:"detectedAt"`
+}
+
+// ListMyDuplicates lists the user's suspected duplicates that they haven't
+// resolved, newest first. Deleting either track resolves a suspected
+// duplicate, as does dismissing it.
+func (r *Repo) ListMyDuplicates(ctx context.Context, userID string) ([]SuspectedDuplicate, error) {
+	rows, err := r.q.ListMyTrackDuplicates(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SuspectedDuplicate, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, SuspectedDuplicate{
+			TrackID:         ids.Marshal(trackIdPrefix, row.TrackID),
+			TrackName:       stringFromNullable(row.TrackName),
+			DuplicateOfID:   ids.Marshal(trackIdPrefix, row.DuplicateOfID),
+			DuplicateOfName: stringFromNullable(row.DuplicateOfName),
+			FrechetMeters:   row.FrechetMeters,
+			DetectedAt:      row.DetectedAt.Time,
+		})
+	}
+	return out, nil
+}
+
+// DismissDuplicate marks a suspected duplicate as not actually a duplicate.
+func (r *Repo) DismissDuplicate(ctx context.Context, userID string, trackID string, duplicateOfID string) error {
+	tID, err := ids.Unmarshal(trackIdPrefix, trackID)
+	if err != nil {
+		return ErrTrackNotFound
+	}
+	otherID, err := ids.Unmarshal(trackIdPrefix, duplicateOfID)
+	if err != nil {
+		return ErrDuplicateNotFound
+	}
+	n, err := r.q.DismissTrackDuplicate(ctx, db.DismissTrackDuplicateParams{
+		TrackID:       tID,
+		DuplicateOfID: otherID,
+		OwnerID:       userID,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDuplicateNotFound
+	}
+	return nil
+}
+
+// findDuplicate finds the user's track most similar to the feature if any
+// is likely a duplicate of it. Tracks without times of their own have their
+// upload time stored as the time, so they are compared on shape alone.
+func findDuplicate(ctx context.Context, q *db.Queries, ownerID string, f geojson.Feature) (int64, float64, bool, error) {
+	line, ok := f.Geometry.(orb.LineString)
+	if !ok || len(line) == 0 {
+		return 0, 0, false, nil
+	}
+
+	start := line[0]
+	padLat := duplicateMaxStartMeters / analysis.MetersPerDegreeLat
+	padLng := padLat / math.Max(math.Cos(start.Lat()*math.Pi/180), 0.01)
+	params := db.ListDuplicateCandidatesParams{
+		OwnerID: &ownerID,
+		MinLat:  start.Lat() - padLat,
+		MaxLat:  start.Lat() + padLat,
+		MinLng:  start.Lon() - padLng,
+		MaxLng:  start.Lon() + padLng,
+	}
+	if startTime, _, ok := analysis.TrackTimeRange(f); ok {
+		params.MinTime = pgtype.Timestamptz{Time: startTime.Add(-analysis.DuplicateMaxStartDiff), Valid: true}
+		params.MaxTime = pgtype.Timestamptz{Time: startTime.Add(analysis.DuplicateMaxStartDiff), Valid: true}
+	}
+	candidates, err := q.ListDuplicateCandidates(ctx, params)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	var bestID int64
+	bestDist := math.Inf(1)
+	for _, c := range candidates {
+		dist, ok := analysis.LikelyDuplicate(f, c.Geojson)
+		if ok && dist < bestDist {
+			bestID = c.ID
+			bestDist = dist
+		}
+	}
+	if bestID == 0 {
+		return 0, 0, false, nil
+	}
+	return bestID, bestDist, true, nil
+}
@@ -0,0 +1,90 @@
+package tracks
+
+import (
+	"context"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFindAndDismissDuplicate(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-3.99, 56.005}, {-3.98, 56.01}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:10:00Z",
+		"2024-06-12T09:20:00Z",
+	}
+	originalID := insertTestTrack(t, r, "user_1", *f)
+	otherUsersID := insertTestTrack(t, r, "user_2", *f)
+
+	copyID := insertTestTrack(t, r, "user_1", *f)
+	copyTID, err := ids.Unmarshal(trackIdPrefix, copyID)
+	require.NoError(t, err)
+
+	duplicateOf, _, ok, err := findDuplicate(ctx, r.q, "user_1", *f)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.NotEqual(t, otherUsersID, ids.Marshal(trackIdPrefix, duplicateOf))
+
+	originalTID, err := ids.Unmarshal(trackIdPrefix, originalID)
+	require.NoError(t, err)
+	require.NoError(t, r.q.InsertTrackDuplicate(ctx, db.InsertTrackDuplicateParams{
+		TrackID:       copyTID,
+		DuplicateOfID: originalTID,
+		OwnerID:       "user_1",
+	}))
+
+	got, err := r.ListMyDuplicates(ctx, "user_1")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, copyID, got[0].TrackID)
+	assert.Equal(t, originalID, got[0].DuplicateOfID)
+
+	others, err := r.ListMyDuplicates(ctx, "user_2")
+	require.NoError(t, err)
+	assert.Empty(t, others)
+
+	require.ErrorIs(t, r.DismissDuplicate(ctx, "user_2", copyID, originalID), ErrDuplicateNotFound)
+	require.NoError(t, r.DismissDuplicate(ctx, "user_1", copyID, originalID))
+	require.ErrorIs(t, r.DismissDuplicate(ctx, "user_1", copyID, originalID), ErrDuplicateNotFound)
+
+	got, err = r.ListMyDuplicates(ctx, "user_1")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestFindDuplicateWithoutTimes(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	// Recorded without times, so the upload time was stored as the time
+	owner := "user_1"
+	uploadTime := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	existing, err := r.q.InsertImportedTrack(ctx, db.InsertImportedTrackParams{
+		OwnerID:    &owner,
+		UploadTime: uploadTime,
+		Time:       uploadTime,
+		Geojson:    *geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-3.99, 56.005}, {-3.98, 56.01}}),
+	})
+	require.NoError(t, err)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-3.99, 56.005}, {-3.98, 56.01}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:10:00Z",
+		"2024-06-12T09:20:00Z",
+	}
+	duplicateOf, _, ok, err := findDuplicate(ctx, r.q, owner, *f)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, existing, duplicateOf)
+}
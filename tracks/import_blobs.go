@@ -0,0 +1,140 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/blobs"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"log/slog"
+	"time"
+)
+
+// moveImportDataBatchSize is how many imports are moved at once. Each can be
+// up to maxImportSize.
+const moveImportDataBatchSize = 10
+
+// BlobStore holds the original files of imports, which used to be kept in
+// the track_imports table. See the blobs package for implementations.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns blobs.ErrNotFound if there is no such blob
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// importBlobKey is where the file of the import is stored. As the hash covers
+// the owner and contents, uploading the same file again reuses the key.
+func importBlobKey(hash []byte) string {
+	return fmt.Sprintf("track-imports/%x", hash)
+}
+
+// loadImportData gets the file of the import, from wherever it is stored.
+func loadImportData(ctx context.Context, store BlobStore, row db.TrackImport) ([]byte, error) {
+	if row.BlobKey == nil {
+		// Not moved out of the database yet
+		return row.Data, nil
+	}
+	data, err := store.Get(ctx, *row.BlobKey)
+	if errors.Is(err, blobs.ErrNotFound) {
+		return nil, permanentImportError{err: fmt.Errorf("get import data: %w", err)}
+	} else if err != nil {
+		return nil, fmt.Errorf("get import data: %w", err)
+	}
+	return data, nil
+}
+
+// deleteImportIfUnused deletes the import once none of its tracks are left,
+// returning the key of its blob so that it can be deleted after the
+// transaction commits.
+func deleteImportIfUnused(ctx context.Context, q *db.Queries, importID int64) (*string, error) {
+	key, err := q.DeleteTrackImportIfUnused(ctx, importID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return key, err
+}
+
+// deleteBlobs removes blobs nothing refers to any more. A failure only leaves
+// an orphaned blob behind, so it is logged rather than returned.
+func (r *Repo) deleteBlobs(ctx context.Context, keys ...*string) {
+	for _, key := range keys {
+		if key == nil {
+			continue
+		}
+		if err := r.blobs.Delete(ctx, *key); err != nil {
+			slog.Warn("delete import blob", "key", *key, "error", err)
+		}
+	}
+}
+
+type MoveImportDataArgs struct{}
+
+func (MoveImportDataArgs) Kind() string { return "tracks_move_import_data" }
+
+// MoveImportDataWorker moves the files of imports from before the blob store
+// out of the database.
+type MoveImportDataWorker struct {
+	db    *pgxpool.Pool
+	blobs BlobStore
+	river.WorkerDefaults[MoveImportDataArgs]
+}
+
+func AddMoveImportDataWorker(workers *river.Workers, db *pgxpool.Pool, blobs BlobStore) {
+	river.AddWorker[MoveImportDataArgs](workers, &MoveImportDataWorker{db: db, blobs: blobs})
+}
+
+// MoveImportDataJob runs the move on start, and daily in case an old
+// replica wrote to the data column during a deploy.
+func MoveImportDataJob() *river.PeriodicJob {
+	return river.NewPeriodicJob(
+		river.PeriodicInterval(24*time.Hour),
+		func() (river.JobArgs, *river.InsertOpts) {
+			return MoveImportDataArgs{}, nil
+		},
+		&river.PeriodicJobOpts{RunOnStart: true},
+	)
+}
+
+func (w *MoveImportDataWorker) Work(ctx context.Context, job *river.Job[MoveImportDataArgs]) error {
+	n, err := moveImportData(ctx, db.New(w.db), w.blobs)
+	if n > 0 {
+		slog.Info("moved import data to blob store", "count", n)
+	}
+	return err
+}
+
+// moveImportData moves imports until there are none left. Each is committed
+// on its own, so an interrupted run picks up where it left off.
+func moveImportData(ctx context.Context, q *db.Queries, store BlobStore) (int, error) {
+	var moved int
+	for {
+		rows, err := q.ListUnmovedTrackImports(ctx, moveImportDataBatchSize)
+		if err != nil {
+			return moved, err
+		}
+		if len(rows) == 0 {
+			return moved, nil
+		}
+
+		for _, row := range rows {
+			key := importBlobKey(row.Hash)
+			if err := store.Put(ctx, key, row.Data); err != nil {
+				return moved, fmt.Errorf("put import %d: %w", row.ID, err)
+			}
+			// If the import was cancelled in the meantime the blob is left
+			// behind, as a new upload of the same file could be using it
+			n, err := q.SetTrackImportBlobKey(ctx, db.SetTrackImportBlobKeyParams{
+				ID:      row.ID,
+				BlobKey: &key,
+			})
+			if err != nil {
+				return moved, err
+			}
+			moved += int(n)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package tracks
+
+import (
+	"context"
+	"github.com/dzfranklin/plantopo-api/blobs"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/testsupport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMoveImportData(t *testing.T) {
+	ctx := context.Background()
+	pool := testsupport.NewDB(t)
+	q := db.New(pool)
+	store := newTestBlobStore(t)
+
+	// Imports from before the blob store
+	var legacyIDs []int64
+	for i := range moveImportDataBatchSize + 2 {
+		var id int64
+		err := pool.QueryRow(ctx, `
+			INSERT INTO track_imports (owner_id, filename, data, byte_size, hash)
+			VALUES ('user_1', 'file.gpx', $1, $2, $3)
+			RETURNING id`,
+			sampleGPX(), len(sampleGPX()), []byte{byte(i)},
+		).Scan(&id)
+		require.NoError(t, err)
+		legacyIDs = append(legacyIDs, id)
+	}
+
+	moved, err := moveImportData(ctx, q, store)
+	require.NoError(t, err)
+	assert.Equal(t, len(legacyIDs), moved)
+
+	for _, id := range legacyIDs {
+		row, err := q.GetTrackImport(ctx, id)
+		require.NoError(t, err)
+		assert.Nil(t, row.Data)
+		require.NotNil(t, row.BlobKey)
+
+		data, err := loadImportData(ctx, store, row)
+		require.NoError(t, err)
+		assert.Equal(t, sampleGPX(), data)
+	}
+
+	moved, err = moveImportData(ctx, q, store)
+	require.NoError(t, err)
+	assert.Equal(t, 0, moved)
+}
+
+func TestLoadImportDataMissingBlob(t *testing.T) {
+	key := importBlobKey([]byte("missing"))
+	_, err := loadImportData(context.Background(), newTestBlobStore(t), db.TrackImport{BlobKey: &key})
+	assert.ErrorIs(t, err, blobs.ErrNotFound)
+	_, permanent := classifyImportError(err)
+	assert.True(t, permanent)
+}
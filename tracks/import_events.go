@@ -0,0 +1,167 @@
+package tracks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	ImportQueued     = "queued"
+	ImportConverting = "converting"
+	ImportAnalysing  = "analysing"
+	ImportCompleted  = "completed"
+	ImportFailed     = "failed"
+	ImportCancelled  = "cancelled"
+)
+
+const importEventsChannel = "track_import_events"
+
+// Postgres rejects notification payloads of 8000 bytes or more
+const maxImportEventPayload = 7900
+
+const (
+	importEventsBuffer         = 16
+	importEventsReconnectDelay = 5 * time.Second
+)
+
+// ImportEvent is a step in the lifecycle of an import.
+type ImportEvent struct {
+	// ImportID is omitted when the event is about a whole archive
+	ImportID string    `json:"importID,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Status   string    `json:"status"`
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error,omitempty"`
+	// TrackIDs is set when the import completes. If there are too many to
+	// send they are omitted and must be fetched with the import status.
+	TrackIDs        []string `json:"trackIDs,omitempty"`
+	TrackIDsOmitted bool     `json:"trackIDsOmitted,omitempty"`
+	// BatchID and Batch are set if the import is part of an archive, or the
+	// event is about the whole archive
+	BatchID string               `json:"batchID,omitempty"`
+	Batch   *ImportBatchProgress `json:"batch,omitempty"`
+}
+
+type importEventPayload struct {
+	OwnerID string      `json:"ownerID"`
+	Event   ImportEvent `json:"event"`
+}
+
+// notifyImportEvent publishes an import event to every API replica. If q is
+// in a transaction the event is only sent if it commits.
+func notifyImportEvent(ctx context.Context, q *db.Queries, ownerID string, event ImportEvent) error {
+	event.Time = time.Now()
+	payload, err := json.Marshal(importEventPayload{OwnerID: ownerID, Event: event})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxImportEventPayload {
+		event.TrackIDs = nil
+		event.TrackIDsOmitted = true
+		payload, err = json.Marshal(importEventPayload{OwnerID: ownerID, Event: event})
+		if err != nil {
+			return err
+		}
+	}
+	return q.NotifyTrackImportEvent(ctx, string(payload))
+}
+
+// ImportEvents relays the import events published by any replica to
+// subscribers on this one.
+type ImportEvents struct {
+	pool        *pgxpool.Pool
+	mu          sync.Mutex
+	subscribers map[string]map[chan ImportEvent]struct{}
+}
+
+func NewImportEvents(pool *pgxpool.Pool) *ImportEvents {
+	return &ImportEvents{
+		pool:        pool,
+		subscribers: make(map[string]map[chan ImportEvent]struct{}),
+	}
+}
+
+// Run listens for events until the context is cancelled, reconnecting if
+// the connection is lost.
+func (e *ImportEvents) Run(ctx context.Context) {
+	for {
+		err := e.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("listen for import events", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(importEventsReconnectDelay):
+		}
+	}
+}
+
+func (e *ImportEvents) listen(ctx context.Context) error {
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is taken out of the pool so that it can't be reused
+	// while still listening
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+importEventsChannel); err != nil {
+		return err
+	}
+	for {
+		n, err := pgConn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var payload importEventPayload
+		if err := json.Unmarshal([]byte(n.Payload), &payload); err != nil {
+			slog.Error("invalid import event", "error", err)
+			continue
+		}
+		e.dispatch(payload.OwnerID, payload.Event)
+	}
+}
+
+// Subscribe receives the user's import events until unsubscribe is called.
+// Events are dropped if the subscriber falls behind.
+func (e *ImportEvents) Subscribe(userID string) (<-chan ImportEvent, func()) {
+	ch := make(chan ImportEvent, importEventsBuffer)
+
+	e.mu.Lock()
+	if e.subscribers[userID] == nil {
+		e.subscribers[userID] = make(map[chan ImportEvent]struct{})
+	}
+	e.subscribers[userID][ch] = struct{}{}
+	e.mu.Unlock()
+
+	unsubscribe := func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.subscribers[userID], ch)
+		if len(e.subscribers[userID]) == 0 {
+			delete(e.subscribers, userID)
+		}
+	}
+	return ch, unsubscribe
+}
+
+func (e *ImportEvents) dispatch(userID string, event ImportEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ch := range e.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("dropping import event for slow subscriber", "import", event.ImportID)
+		}
+	}
+}
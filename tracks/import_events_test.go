@@ -0,0 +1,67 @@
+package tracks
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestImportEventsDispatch(t *testing.T) {
+	e := NewImportEvents(nil)
+	mine, unsubscribe := e.Subscribe("user_1")
+	others, unsubscribeOthers := e.Subscribe("user_2")
+	defer unsubscribeOthers()
+
+	e.dispatch("user_1", ImportEvent{ImportID: "ti_01", Status: ImportConverting})
+	select {
+	case got := <-mine:
+		assert.Equal(t, "ti_01", got.ImportID)
+		assert.Equal(t, ImportConverting, got.Status)
+	default:
+		t.Fatal("expected event")
+	}
+	assert.Empty(t, others)
+
+	// A slow subscriber doesn't block others
+	for i := 0; i < importEventsBuffer+1; i++ {
+		e.dispatch("user_1", ImportEvent{ImportID: "ti_01", Status: ImportAnalysing})
+	}
+	assert.Len(t, mine, importEventsBuffer)
+
+	unsubscribe()
+	assert.NotContains(t, e.subscribers, "user_1")
+}
+
+func TestImportEventsAcrossConnections(t *testing.T) {
+	r := newSubject(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := NewImportEvents(r.pool)
+	go e.Run(ctx)
+	ch, unsubscribe := e.Subscribe("user_1")
+	defer unsubscribe()
+
+	// Keep notifying until the listener is connected
+	deadline := time.After(5 * time.Second)
+	for {
+		err := notifyImportEvent(ctx, r.q, "user_1", ImportEvent{
+			ImportID: "ti_01",
+			Status:   ImportCompleted,
+			TrackIDs: []string{"t_1"},
+		})
+		require.NoError(t, err)
+
+		select {
+		case got := <-ch:
+			assert.Equal(t, ImportCompleted, got.Status)
+			assert.Equal(t, []string{"t_1"}, got.TrackIDs)
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
@@ -7,17 +7,22 @@ import (
 	"fmt"
 	"github.com/dzfranklin/plantopo-api/analysis"
 	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/dzfranklin/plantopo-api/webhooks"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/paulmach/orb/geojson"
 	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivertype"
 	"log/slog"
+	"regexp"
 	"strings"
 	"time"
+	"unicode"
 )
 
-// TODO: This isn't properly responding to soft stops
-
 type ToGeoJSON interface {
 	Convert(ctx context.Context, filename string, data []byte) (json.RawMessage, error)
 }
@@ -32,63 +37,185 @@ type ImportWorkerArgs struct {
 
 func (ImportWorkerArgs) Kind() string { return "tracks_import" }
 
-func (ImportWorkerArgs) InsertOps() river.InsertOpts {
+// importMaxAttempts is how many times an import is tried before it is marked
+// failed. Retries are for errors that might not happen again, like the
+// converter being down.
+const importMaxAttempts = 5
+
+// importTimeout is how long an attempt at an import can take. Large files
+// spend most of it looking up elevations.
+const importTimeout = 5 * time.Minute
+
+// importFailedMessage is shown to the user in place of errors that might
+// reveal internal details.
+const importFailedMessage = "Something went wrong processing this file"
+
+const importTimedOutMessage = "Processing this file took too long"
+
+func (ImportWorkerArgs) InsertOpts() river.InsertOpts {
 	return river.InsertOpts{
+		MaxAttempts: importMaxAttempts,
 		UniqueOpts: river.UniqueOpts{
 			ByArgs: true,
+			// Unlike the default, a completed job doesn't prevent the import
+			// from being retried
+			ByState: []rivertype.JobState{
+				rivertype.JobStateAvailable,
+				rivertype.JobStateRunning,
+				rivertype.JobStateRetryable,
+				rivertype.JobStateScheduled,
+			},
 		},
 	}
 }
 
 type ImportWorker struct {
 	db        *pgxpool.Pool
+	blobs     BlobStore
 	toGeoJSON ToGeoJSON
 	analyzer  Analyzer
+	softStop  <-chan struct{}
+	timeout   time.Duration
 	river.WorkerDefaults[ImportWorkerArgs]
 }
 
-func AddImportWorker(workers *river.Workers, db *pgxpool.Pool, toGeoJSON ToGeoJSON, analyzer Analyzer) {
-	river.AddWorker[ImportWorkerArgs](workers, &ImportWorker{db: db, toGeoJSON: toGeoJSON, analyzer: analyzer})
+// AddImportWorker registers the import worker. softStop should be closed when
+// the client begins a graceful shutdown, so that imports in progress save
+// their work and make way.
+func AddImportWorker(workers *river.Workers, db *pgxpool.Pool, blobs BlobStore, toGeoJSON ToGeoJSON, analyzer Analyzer, softStop <-chan struct{}) {
+	river.AddWorker[ImportWorkerArgs](workers, &ImportWorker{
+		db:        db,
+		blobs:     blobs,
+		toGeoJSON: toGeoJSON,
+		analyzer:  analyzer,
+		softStop:  softStop,
+		timeout:   importTimeout,
+	})
+}
+
+func (w *ImportWorker) Timeout(*river.Job[ImportWorkerArgs]) time.Duration {
+	return w.timeout
+}
+
+// errImportSoftStopped is returned when the import was checkpointed because
+// of a soft stop.
+var errImportSoftStopped = fmt.Errorf("import soft stopped")
+
+// importCheckpoint is the work done on an import so far, saved so that the
+// import can resume where it left off.
+type importCheckpoint struct {
+	// Hydrated holds the analysed tracks by their index in the converted
+	// file
+	Hydrated map[int]geojson.Feature `json:"hydrated"`
 }
 
 func (w *ImportWorker) Work(ctx context.Context, job *river.Job[ImportWorkerArgs]) error {
 	importId := job.Args.Id
-	uploadTime := job.CreatedAt
 	l := slog.With("job", job.ID, "import", importId, "created_at", job.CreatedAt)
 
 	q := db.New(w.db)
 	data, err := q.GetTrackImport(ctx, importId)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Info("import deleted")
+			return nil
+		}
 		l.Error("get track import", "error", err)
 		return err
 	}
 
-	if data.CompletedAt.Valid || data.FailedAt.Valid {
+	if data.CompletedAt.Valid || data.FailedAt.Valid || data.CancelledAt.Valid {
 		l.Info("already done")
 		return nil
 	}
 
-	rawGeojson, err := w.toGeoJSON.Convert(ctx, data.Filename, data.Data)
+	err = q.SetTrackImportAttempts(ctx, db.SetTrackImportAttemptsParams{
+		ID:       importId,
+		Attempts: int32(job.Attempt),
+	})
 	if err != nil {
-		invalidConversionErr := InvalidConversionInputError{}
-		if errors.As(err, &invalidConversionErr) {
-			l.Info("invalid conversion input", "error", invalidConversionErr.Message)
-			return q.MarkTrackImportFailed(ctx, db.MarkTrackImportFailedParams{
-				ID:    importId,
-				Error: &invalidConversionErr.Message,
-			})
+		return err
+	}
+
+	err = w.process(ctx, l, q, job, data)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, errImportSoftStopped) {
+		l.Info("checkpointed for soft stop")
+		// Snoozing doesn't use up an attempt
+		return river.JobSnooze(0)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// Cancelled or shutting down, so the error says nothing about the
+		// import
+		return err
+	}
+	timedOut := ctx.Err() != nil
+	if timedOut && job.Attempt < job.MaxAttempts {
+		l.Warn("import attempt timed out", "attempt", job.Attempt)
+		return err
+	}
+
+	// The failure is recorded even if the attempt ran out of time
+	markCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	message, permanent := classifyImportError(err)
+	if timedOut {
+		message = importTimedOutMessage
+	}
+	if permanent {
+		l.Info("import failed", "error", err)
+		return markImportFailed(markCtx, w.db, data, message)
+	}
+	if job.Attempt >= job.MaxAttempts {
+		l.Error("import failed on final attempt", "attempt", job.Attempt, "error", err)
+		if markErr := markImportFailed(markCtx, w.db, data, message); markErr != nil {
+			return markErr
 		}
-		l.Error("convert import to geojson", "error", err)
 		return err
 	}
+	l.Warn("import attempt failed", "attempt", job.Attempt, "error", err)
+	return err
+}
 
-	trackFeatures, err := geojson.UnmarshalFeatureCollection(rawGeojson)
+// process converts the import and inserts its tracks. The tracks are inserted
+// in the same transaction that completes the job, so that a crash can't leave
+// an import half done.
+func (w *ImportWorker) process(ctx context.Context, l *slog.Logger, q *db.Queries, job *river.Job[ImportWorkerArgs], data db.TrackImport) error {
+	importId := data.ID
+	uploadTime := job.CreatedAt
+	publicImportID := ids.MarshalHash(importIdPrefix, data.Hash)
+
+	checkpoint := importCheckpoint{Hydrated: make(map[int]geojson.Feature)}
+	if data.Checkpoint != nil {
+		if err := json.Unmarshal(data.Checkpoint, &checkpoint); err != nil {
+			l.Warn("ignoring invalid checkpoint", "error", err)
+			checkpoint = importCheckpoint{Hydrated: make(map[int]geojson.Feature)}
+		} else {
+			l.Info("resuming from checkpoint", "hydrated", len(checkpoint.Hydrated))
+		}
+	}
+
+	fileData, err := loadImportData(ctx, w.blobs, data)
 	if err != nil {
-		err = fmt.Errorf("invalid geojson: %w", err)
-		l.Error("unmarshal geojson", "error", err)
 		return err
 	}
 
+	w.notifyProgress(ctx, q, data.OwnerID, ImportEvent{ImportID: publicImportID, Status: ImportConverting})
+	rawGeojson, err := w.toGeoJSON.Convert(ctx, data.Filename, fileData)
+	if err != nil {
+		return fmt.Errorf("convert import to geojson: %w", err)
+	}
+
+	trackFeatures, err := geojson.UnmarshalFeatureCollection(rawGeojson)
+	if err != nil {
+		// The converter won't give a different answer next time
+		return permanentImportError{err: fmt.Errorf("unmarshal converted geojson: %w", err)}
+	}
+
+	w.notifyProgress(ctx, q, data.OwnerID, ImportEvent{ImportID: publicImportID, Status: ImportAnalysing})
 	var tracks []db.InsertImportedTrackParams
 	for i, rawFeature := range trackFeatures.Features {
 		if rawFeature.Geometry.GeoJSONType() != "LineString" {
@@ -96,21 +223,45 @@ func (w *ImportWorker) Work(ctx context.Context, job *river.Job[ImportWorkerArgs
 			continue
 		}
 
-		feature, err := w.analyzer.HydrateTrack(ctx, *rawFeature)
-		if err != nil {
-			l.Error("hydrate track", "i", i, "error", err)
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+		if w.softStopped() {
+			return w.saveCheckpoint(ctx, q, importId, checkpoint)
+		}
+
+		feature, ok := checkpoint.Hydrated[i]
+		if !ok {
+			feature, err = w.analyzer.HydrateTrack(ctx, *rawFeature)
+			if err != nil {
+				return fmt.Errorf("hydrate track %d: %w", i, err)
+			}
+			checkpoint.Hydrated[i] = feature
+		}
+
+		var original *geojson.Feature
+		if trimmed, ok := analysis.TrimIdle(feature); ok {
+			untrimmed := feature
+			original = &untrimmed
+			feature = trimmed
+		}
 
 		name := importName(data.Filename, &feature)
 		trackTime := importTrackTime(&feature, uploadTime)
+		var activityType *string
+		if v, ok := feature.Properties["activityType"].(string); ok {
+			activityType = &v
+		}
 		track := db.InsertImportedTrackParams{
-			OwnerID:    &data.OwnerID,
-			Name:       &name,
-			UploadTime: pgtype.Timestamp{Time: uploadTime, Valid: true},
-			Time:       pgtype.Timestamp{Time: trackTime, Valid: true},
-			Geojson:    feature,
-			ImportID:   &importId,
+			OwnerID:         &data.OwnerID,
+			Name:            &name,
+			UploadTime:      pgtype.Timestamptz{Time: uploadTime, Valid: true},
+			Time:            pgtype.Timestamptz{Time: trackTime, Valid: true},
+			Geojson:         feature,
+			ImportID:        &importId,
+			OriginalGeojson: original,
+			ActivityType:    activityType,
+			Timezone:        trackTimezone(feature),
 		}
 		tracks = append(tracks, track)
 	}
@@ -120,38 +271,244 @@ func (w *ImportWorker) Work(ctx context.Context, job *river.Job[ImportWorkerArgs
 		return err
 	}
 	defer completeTx.Rollback(ctx)
+	tq := q.WithTx(completeTx)
 
-	err = q.MarkTrackImportCompleted(ctx, importId)
+	n, err := tq.MarkTrackImportCompleted(ctx, importId)
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		l.Info("cancelled while processing")
+		return nil
+	}
+
+	var trackIDs []string
+	var skippedDuplicateOf []int64
+	for i, track := range tracks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	for _, track := range tracks {
-		_, err = q.InsertImportedTrack(ctx, track)
+		duplicateOf, frechet, isDuplicate, err := findDuplicate(ctx, tq, data.OwnerID, track.Geojson)
 		if err != nil {
 			return err
 		}
+		if isDuplicate && data.SkipDuplicates {
+			l.Info("skipping duplicate track", "i", i, "duplicate_of", duplicateOf)
+			skippedDuplicateOf = append(skippedDuplicateOf, duplicateOf)
+			continue
+		}
+
+		trackID, err := tq.InsertImportedTrack(ctx, track)
+		if err != nil {
+			return err
+		}
+		if err := refreshSummits(ctx, tq, trackID); err != nil {
+			return err
+		}
+		trackIDs = append(trackIDs, ids.Marshal(trackIdPrefix, trackID))
+		err = enqueueTrackCreated(ctx, river.ClientFromContext[pgx.Tx](ctx), completeTx,
+			track.OwnerID, trackID, track.Name, track.Time, track.ActivityType)
+		if err != nil {
+			return err
+		}
+
+		if isDuplicate {
+			err := tq.InsertTrackDuplicate(ctx, db.InsertTrackDuplicateParams{
+				TrackID:       trackID,
+				DuplicateOfID: duplicateOf,
+				OwnerID:       data.OwnerID,
+				FrechetMeters: frechet,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(skippedDuplicateOf) > 0 {
+		err := tq.SetTrackImportSkippedDuplicates(ctx, db.SetTrackImportSkippedDuplicatesParams{
+			ID:                    importId,
+			SkippedDuplicateOfIds: skippedDuplicateOf,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	event, err := withBatchProgress(ctx, tq, data.BatchID, ImportEvent{
+		ImportID: publicImportID,
+		Status:   ImportCompleted,
+		TrackIDs: trackIDs,
+	})
+	if err != nil {
+		return err
+	}
+	if err := notifyImportEvent(ctx, tq, data.OwnerID, event); err != nil {
+		return err
+	}
+
+	if _, err := river.JobCompleteTx[*riverpgxv5.Driver](ctx, completeTx, job); err != nil {
+		return err
 	}
 
 	return completeTx.Commit(ctx)
 }
 
+func (w *ImportWorker) softStopped() bool {
+	select {
+	case <-w.softStop:
+		return true
+	default:
+		return false
+	}
+}
+
+// saveCheckpoint saves the tracks analysed so far so that the next attempt
+// can skip them.
+func (w *ImportWorker) saveCheckpoint(ctx context.Context, q *db.Queries, importId int64, checkpoint importCheckpoint) error {
+	value, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	err = q.SetTrackImportCheckpoint(ctx, db.SetTrackImportCheckpointParams{
+		ID:         importId,
+		Checkpoint: value,
+	})
+	if err != nil {
+		return err
+	}
+	return errImportSoftStopped
+}
+
+// permanentImportError is an error that retrying the import won't fix.
+type permanentImportError struct {
+	err error
+	// message is shown to the user if set
+	message string
+}
+
+func (e permanentImportError) Error() string { return e.err.Error() }
+
+func (e permanentImportError) Unwrap() error { return e.err }
+
+// classifyImportError picks the message to show the user and reports whether
+// the import could succeed if tried again.
+func classifyImportError(err error) (string, bool) {
+	invalidConversionErr := InvalidConversionInputError{}
+	if errors.As(err, &invalidConversionErr) {
+		return invalidConversionErr.Message, true
+	}
+	permanentErr := permanentImportError{}
+	if errors.As(err, &permanentErr) {
+		if permanentErr.message != "" {
+			return permanentErr.message, true
+		}
+		return importFailedMessage, true
+	}
+	return importFailedMessage, false
+}
+
+// markImportFailed records that the import can never succeed and tells the
+// user.
+func markImportFailed(ctx context.Context, pool *pgxpool.Pool, data db.TrackImport, message string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := db.New(tx)
+
+	n, err := q.MarkTrackImportFailed(ctx, db.MarkTrackImportFailedParams{
+		ID:    data.ID,
+		Error: &message,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// Cancelled while processing
+		return nil
+	}
+
+	publicImportID := ids.MarshalHash(importIdPrefix, data.Hash)
+	event, err := withBatchProgress(ctx, q, data.BatchID, ImportEvent{
+		ImportID: publicImportID,
+		URL:      stringFromNullable(data.SourceUrl),
+		Status:   ImportFailed,
+		Error:    message,
+	})
+	if err != nil {
+		return err
+	}
+	if err := notifyImportEvent(ctx, q, data.OwnerID, event); err != nil {
+		return err
+	}
+
+	err = webhooks.Enqueue(ctx, river.ClientFromContext[pgx.Tx](ctx), tx, data.OwnerID, webhooks.EventImportFailed, importWebhookData{
+		ID:       publicImportID,
+		Filename: data.Filename,
+		Error:    message,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// notifyProgress publishes an event that only reports progress, which isn't
+// worth failing the import over.
+func (w *ImportWorker) notifyProgress(ctx context.Context, q *db.Queries, ownerID string, event ImportEvent) {
+	if err := notifyImportEvent(ctx, q, ownerID, event); err != nil {
+		slog.Warn("notify import progress", "import", event.ImportID, "error", err)
+	}
+}
+
+// importName picks a name for an imported track, preferring the name in the
+// file. Names that are only a date or an ID, as many devices and apps
+// generate, are passed over for one based on the places along the track.
 func importName(filename string, track *geojson.Feature) string {
-	if prop, ok := track.Properties["name"].(string); ok {
+	prop, hasProp := track.Properties["name"].(string)
+	if hasProp && !isGenericName(prop) {
 		return prop
 	}
 
-	if !strings.Contains(filename, ".") {
-		return filename
+	stem := filename
+	if strings.Contains(filename, ".") {
+		stem = filename[:strings.LastIndex(filename, ".")]
+	}
+	if !isGenericName(stem) {
+		return stem
+	}
+
+	if name, ok := analysis.PlacesName(*track); ok {
+		return name
+	}
+	if hasProp {
+		return prop
 	}
-	return filename[:strings.LastIndex(filename, ".")]
+	return stem
 }
 
+var genericNameWords = regexp.MustCompile(`(?i)\b(activity|track|route|export|` +
+	`jan(uary)?|feb(ruary)?|mar(ch)?|apr(il)?|may|june?|july?|aug(ust)?|sep(t(ember)?)?|oct(ober)?|nov(ember)?|dec(ember)?)\b`)
+
+// isGenericName reports whether a name says nothing about the track, such as
+// "6/12/2024" or "activity_1234567".
+func isGenericName(name string) bool {
+	name = genericNameWords.ReplaceAllString(strings.ReplaceAll(name, "_", " "), "")
+	return !strings.ContainsFunc(name, unicode.IsLetter)
+}
+
+// importTrackTime is the time the track says it was recorded, or else the
+// time of its first point.
 func importTrackTime(track *geojson.Feature, fallback time.Time) time.Time {
-	t, ok := analysis.ParseSloppyRecentTime(track.Properties["time"])
-	if ok {
+	if t, ok := analysis.ParseSloppyRecentTime(track.Properties["time"]); ok {
 		return t
-	} else {
-		return fallback
 	}
+	if start, _, ok := analysis.TrackTimeRange(*track); ok {
+		return start
+	}
+	return fallback
 }
@@ -3,11 +3,15 @@ package tracks
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/dzfranklin/plantopo-api/db"
 	"github.com/dzfranklin/plantopo-api/testsupport"
+	"github.com/jackc/pgx/v5"
 	"github.com/paulmach/orb"
 	"github.com/paulmach/orb/geojson"
 	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
 	"github.com/riverqueue/river/rivertype"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -55,38 +59,205 @@ func (a *MockAnalyzer) HydrateTrack(_ context.Context, f geojson.Feature) (geojs
 func TestImportWorker(t *testing.T) {
 	ctx := context.Background()
 	pool := testsupport.NewDB(t)
-	q := db.New(pool)
 	analyzer := &MockAnalyzer{}
-	w := &ImportWorker{db: pool, toGeoJSON: &MockToGeoJSON{}, analyzer: analyzer}
+
+	workers := river.NewWorkers()
+	store := newTestBlobStore(t)
+	AddImportWorker(workers, pool, store, &MockToGeoJSON{}, analyzer, make(chan struct{}))
+	client, err := river.NewClient[pgx.Tx](riverpgxv5.New(pool), &river.Config{
+		Queues:  map[string]river.QueueConfig{river.QueueDefault: {MaxWorkers: 1}},
+		Workers: workers,
+	})
+	require.NoError(t, err)
+	completed, cancelSubscription := client.Subscribe(river.EventKindJobCompleted)
+	defer cancelSubscription()
+	require.NoError(t, client.Start(ctx))
+	defer client.Stop(ctx)
 
 	owner := "user_1"
-	_, err := q.InsertTrackImport(ctx, db.InsertTrackImportParams{
-		OwnerID:  owner,
+	r := NewRepo(pool, client, store)
+	id, err := r.Import(ctx, owner, "file.gpx", sampleGPX(), ImportOptions{})
+	require.NoError(t, err)
+
+	select {
+	case <-completed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for import")
+	}
+
+	require.True(t, analyzer.called)
+
+	gotTracks, err := r.ListMyTracks(ctx, owner, ListOptions{OrderBy: OrderByTime})
+	require.NoError(t, err)
+	require.Len(t, gotTracks, 1)
+
+	got := gotTracks[0]
+	require.Equal(t, owner, got.OwnerID)
+	require.Equal(t, "6/12/2024", got.Name)
+	require.Equal(t, "2024-06-12T09:03:59Z", got.Time.Format(time.RFC3339))
+	require.Equal(t, 3, len(got.Geojson.Geometry.(orb.LineString)))
+
+	status, err := r.ImportStatus(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, status.CompletedAt)
+	require.Equal(t, 1, status.Attempts)
+}
+
+func TestImportWorkerRecordsSkippedDuplicates(t *testing.T) {
+	ctx := context.Background()
+	pool := testsupport.NewDB(t)
+
+	workers := river.NewWorkers()
+	store := newTestBlobStore(t)
+	AddImportWorker(workers, pool, store, &MockToGeoJSON{}, &MockAnalyzer{}, make(chan struct{}))
+	client, err := river.NewClient[pgx.Tx](riverpgxv5.New(pool), &river.Config{
+		Queues:  map[string]river.QueueConfig{river.QueueDefault: {MaxWorkers: 1}},
+		Workers: workers,
+	})
+	require.NoError(t, err)
+	completed, cancelSubscription := client.Subscribe(river.EventKindJobCompleted)
+	defer cancelSubscription()
+	require.NoError(t, client.Start(ctx))
+	defer client.Stop(ctx)
+
+	r := NewRepo(pool, client, store)
+	importAndWait := func(filename string, data []byte, opts ImportOptions) Import {
+		id, err := r.Import(ctx, "user_1", filename, data, opts)
+		require.NoError(t, err)
+		select {
+		case <-completed:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for import")
+		}
+		status, err := r.ImportStatus(ctx, id)
+		require.NoError(t, err)
+		return status
+	}
+
+	// The mock converts every file to the same track
+	first := importAndWait("file.gpx", sampleGPX(), ImportOptions{})
+	require.Len(t, first.TrackIDs, 1)
+
+	second := importAndWait("copy.gpx", []byte("a copy from another app"), ImportOptions{SkipDuplicates: true})
+	require.NotNil(t, second.CompletedAt)
+	require.Empty(t, second.TrackIDs)
+	require.Equal(t, first.TrackIDs, second.SkippedDuplicateOfIDs)
+
+	recent, err := r.ListMyPendingOrRecentImports(ctx, "user_1")
+	require.NoError(t, err)
+	require.Len(t, recent, 2)
+	require.Equal(t, first.TrackIDs, recent[0].SkippedDuplicateOfIDs)
+}
+
+// slowToGeoJSON takes longer than any attempt is allowed.
+type slowToGeoJSON struct{}
+
+func (slowToGeoJSON) Convert(ctx context.Context, _ string, _ []byte) (json.RawMessage, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestImportWorkerTimeoutOnFinalAttempt(t *testing.T) {
+	ctx := context.Background()
+	pool := testsupport.NewDB(t)
+	q := db.New(pool)
+
+	workers := river.NewWorkers()
+	store := newTestBlobStore(t)
+	river.AddWorker[ImportWorkerArgs](workers, &ImportWorker{
+		db:        pool,
+		blobs:     store,
+		toGeoJSON: slowToGeoJSON{},
+		analyzer:  &MockAnalyzer{},
+		softStop:  make(chan struct{}),
+		timeout:   100 * time.Millisecond,
+	})
+	client, err := river.NewClient[pgx.Tx](riverpgxv5.New(pool), &river.Config{
+		Queues:  map[string]river.QueueConfig{river.QueueDefault: {MaxWorkers: 1}},
+		Workers: workers,
+	})
+	require.NoError(t, err)
+	failed, cancelSubscription := client.Subscribe(river.EventKindJobFailed)
+	defer cancelSubscription()
+	require.NoError(t, client.Start(ctx))
+	defer client.Stop(ctx)
+
+	blobKey := importBlobKey([]byte("sample_hash"))
+	require.NoError(t, store.Put(ctx, blobKey, sampleGPX()))
+	importId, err := q.InsertTrackImport(ctx, db.InsertTrackImportParams{
+		OwnerID:  "user_1",
+		Filename: "file.gpx",
+		BlobKey:  &blobKey,
+		ByteSize: int64(len(sampleGPX())),
+		Hash:     []byte("sample_hash"),
+	})
+	require.NoError(t, err)
+	_, err = client.Insert(ctx, ImportWorkerArgs{Id: importId}, &river.InsertOpts{MaxAttempts: 1})
+	require.NoError(t, err)
+
+	select {
+	case <-failed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for import to fail")
+	}
+
+	data, err := q.GetTrackImport(ctx, importId)
+	require.NoError(t, err)
+	require.True(t, data.FailedAt.Valid)
+	require.Equal(t, importTimedOutMessage, *data.Error)
+}
+
+func TestImportWorkerSoftStop(t *testing.T) {
+	ctx := context.Background()
+	pool := testsupport.NewDB(t)
+	q := db.New(pool)
+	analyzer := &MockAnalyzer{}
+	softStop := make(chan struct{})
+	close(softStop)
+	store := newTestBlobStore(t)
+	w := &ImportWorker{db: pool, blobs: store, toGeoJSON: &MockToGeoJSON{}, analyzer: analyzer, softStop: softStop}
+
+	blobKey := importBlobKey([]byte("sample_hash"))
+	require.NoError(t, store.Put(ctx, blobKey, sampleGPX()))
+	importId, err := q.InsertTrackImport(ctx, db.InsertTrackImportParams{
+		OwnerID:  "user_1",
 		Filename: "file.gpx",
-		Data:     sampleGPX(),
+		BlobKey:  &blobKey,
+		ByteSize: int64(len(sampleGPX())),
 		Hash:     []byte("sample_hash"),
 	})
 	require.NoError(t, err)
 
 	err = w.Work(ctx, &river.Job[ImportWorkerArgs]{
-		Args: ImportWorkerArgs{Id: 1},
+		Args: ImportWorkerArgs{Id: importId},
 		JobRow: &rivertype.JobRow{
-			ID: 1,
+			ID:          1,
+			Attempt:     1,
+			MaxAttempts: importMaxAttempts,
 		},
 	})
+	require.Error(t, err)
+	require.False(t, analyzer.called)
+
+	data, err := q.GetTrackImport(ctx, importId)
 	require.NoError(t, err)
+	require.NotNil(t, data.Checkpoint)
+	require.False(t, data.CompletedAt.Valid)
+	require.False(t, data.FailedAt.Valid)
+}
 
-	require.True(t, analyzer.called)
+func TestClassifyImportError(t *testing.T) {
+	message, permanent := classifyImportError(fmt.Errorf("convert: %w", InvalidConversionInputError{"Invalid GPX file"}))
+	require.True(t, permanent)
+	require.Equal(t, "Invalid GPX file", message)
 
-	gotTracks, err := q.ListTracksOrderByTime(ctx, &owner)
-	require.NoError(t, err)
-	require.Len(t, gotTracks, 1)
+	message, permanent = classifyImportError(permanentImportError{err: errors.New("unmarshal: unexpected EOF")})
+	require.True(t, permanent)
+	require.Equal(t, importFailedMessage, message)
 
-	got := gotTracks[0]
-	require.Equal(t, owner, *got.OwnerID)
-	require.Equal(t, "6/12/2024", *got.Name)
-	require.Equal(t, "2024-06-12T09:03:59Z", got.Time.Time.Format(time.RFC3339))
-	require.Equal(t, 3, len(got.Geojson.Geometry.(orb.LineString)))
+	message, permanent = classifyImportError(errors.New("dial tcp 10.0.0.3:80: connection refused"))
+	require.False(t, permanent)
+	require.Equal(t, importFailedMessage, message)
 }
 
 func TestImportName(t *testing.T) {
@@ -120,6 +291,24 @@ func TestImportName(t *testing.T) {
 			`{"type": "Feature"}`,
 			"filename",
 		},
+		{
+			"prefers filename over date property",
+			"Ben Lawers.gpx",
+			`{"type": "Feature", "properties":{"name":"6/12/2024"}}`,
+			"Ben Lawers",
+		},
+		{
+			"prefers places over date property and generic filename",
+			"activity_123456.fit",
+			`{"type": "Feature", "properties":{"name":"Jun 12, 2024","startPlaceName":"Lawers","summitPlaceName":"Ben Lawers"}}`,
+			"Ben Lawers from Lawers",
+		},
+		{
+			"falls back to date property without places",
+			"activity_123456.fit",
+			`{"type": "Feature", "properties":{"name":"6/12/2024"}}`,
+			"6/12/2024",
+		},
 	}
 
 	for _, c := range cases {
@@ -130,3 +319,19 @@ func TestImportName(t *testing.T) {
 		})
 	}
 }
+
+func TestImportTrackTime(t *testing.T) {
+	uploadTime := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	track, err := geojson.UnmarshalFeature([]byte(`{"type": "Feature", "properties": {"time": 1718182800000}}`))
+	require.NoError(t, err)
+	require.Equal(t, "2024-06-12T09:00:00Z", importTrackTime(track, uploadTime).Format(time.RFC3339))
+
+	track, err = geojson.UnmarshalFeature([]byte(`{"type": "Feature", "properties": {"coordinateProperties": {"times": [null, "2024-06-12T10:00:00+01:00"]}}}`))
+	require.NoError(t, err)
+	require.True(t, importTrackTime(track, uploadTime).Equal(time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC)))
+
+	track, err = geojson.UnmarshalFeature([]byte(`{"type": "Feature"}`))
+	require.NoError(t, err)
+	require.Equal(t, uploadTime, importTrackTime(track, uploadTime))
+}
@@ -0,0 +1,177 @@
+package tracks
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPeaks = fmt.Errorf("invalid peaks")
+
+// Column names accepted in a peaks CSV, lowercased
+var (
+	peakNameColumns      = []string{"name"}
+	peakLatColumns       = []string{"lat", "latitude"}
+	peakLngColumns       = []string{"lng", "lon", "long", "longitude"}
+	peakElevationColumns = []string{"elevation", "elevationmeters", "elevation_meters", "metres", "meters", "height"}
+)
+
+// Property names accepted for the elevation of a peak in GeoJSON
+var peakElevationProperties = []string{"elevationMeters", "elevation", "ele", "height"}
+
+// LoadPeaks replaces the peaks in the dataset with those in the file and
+// redetects the summits of every track. The file is either a CSV with a
+// header row or a GeoJSON FeatureCollection of points, chosen by its
+// extension. It returns the number of peaks loaded.
+func (r *Repo) LoadPeaks(ctx context.Context, dataset string, filename string, data io.Reader) (int, error) {
+	if dataset == "" {
+		return 0, fmt.Errorf("%w: missing dataset", ErrInvalidPeaks)
+	}
+
+	var peaks []db.InsertPeaksParams
+	var err error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		peaks, err = parsePeaksCSV(dataset, data)
+	case ".geojson", ".json":
+		peaks, err = parsePeaksGeoJSON(dataset, data)
+	default:
+		return 0, fmt.Errorf("%w: unsupported file type %s", ErrInvalidPeaks, filename)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	if err := q.DeletePeakDataset(ctx, dataset); err != nil {
+		return 0, err
+	}
+	n, err := q.InsertPeaks(ctx, peaks)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	if _, err := r.RefreshAllSummits(ctx); err != nil {
+		return int(n), fmt.Errorf("refresh summits: %w", err)
+	}
+	return int(n), nil
+}
+
+func parsePeaksCSV(dataset string, data io.Reader) ([]db.InsertPeaksParams, error) {
+	reader := csv.NewReader(data)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: read header: %w", ErrInvalidPeaks, err)
+	}
+	nameCol := findColumn(header, peakNameColumns)
+	latCol := findColumn(header, peakLatColumns)
+	lngCol := findColumn(header, peakLngColumns)
+	elevationCol := findColumn(header, peakElevationColumns)
+	if nameCol < 0 || latCol < 0 || lngCol < 0 {
+		return nil, fmt.Errorf("%w: expected name, latitude and longitude columns", ErrInvalidPeaks)
+	}
+
+	var out []db.InsertPeaksParams
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPeaks, err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		lat, err := strconv.ParseFloat(record[latCol], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid latitude", ErrInvalidPeaks, line)
+		}
+		lng, err := strconv.ParseFloat(record[lngCol], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid longitude", ErrInvalidPeaks, line)
+		}
+		peak := db.InsertPeaksParams{
+			Dataset: dataset,
+			Name:    record[nameCol],
+			Lng:     lng,
+			Lat:     lat,
+		}
+		if elevationCol >= 0 && record[elevationCol] != "" {
+			elevation, err := strconv.ParseFloat(record[elevationCol], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: invalid elevation", ErrInvalidPeaks, line)
+			}
+			peak.ElevationMeters = &elevation
+		}
+		out = append(out, peak)
+	}
+	return out, nil
+}
+
+func findColumn(header []string, names []string) int {
+	for i, col := range header {
+		col = strings.ToLower(strings.TrimSpace(col))
+		for _, name := range names {
+			if col == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parsePeaksGeoJSON(dataset string, data io.Reader) ([]db.InsertPeaksParams, error) {
+	raw, err := io.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPeaks, err)
+	}
+
+	out := make([]db.InsertPeaksParams, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		pt, ok := f.Geometry.(orb.Point)
+		if !ok {
+			return nil, fmt.Errorf("%w: feature %d is not a point", ErrInvalidPeaks, i)
+		}
+		name, ok := f.Properties["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: feature %d has no name", ErrInvalidPeaks, i)
+		}
+		peak := db.InsertPeaksParams{
+			Dataset: dataset,
+			Name:    name,
+			Lng:     pt.Lon(),
+			Lat:     pt.Lat(),
+		}
+		for _, key := range peakElevationProperties {
+			if elevation, ok := f.Properties[key].(float64); ok {
+				peak.ElevationMeters = &elevation
+				break
+			}
+		}
+		out = append(out, peak)
+	}
+	return out, nil
+}
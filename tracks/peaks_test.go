@@ -0,0 +1,74 @@
+package tracks
+
+import (
+	"context"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeaksCSV(t *testing.T) {
+	got, err := parsePeaksCSV("munros", strings.NewReader("Name,Metres,Latitude,Longitude\n"+
+		"Ben Lawers,1214,56.5449,-4.2214\n"+
+		"Beinn Ghlas,,56.5372,-4.2372\n"))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "Ben Lawers", got[0].Name)
+	assert.Equal(t, "munros", got[0].Dataset)
+	assert.Equal(t, -4.2214, got[0].Lng)
+	require.NotNil(t, got[0].ElevationMeters)
+	assert.Equal(t, 1214.0, *got[0].ElevationMeters)
+	assert.Nil(t, got[1].ElevationMeters)
+
+	_, err = parsePeaksCSV("munros", strings.NewReader("Name,Height\nBen Lawers,1214\n"))
+	assert.ErrorIs(t, err, ErrInvalidPeaks)
+}
+
+func TestParsePeaksGeoJSON(t *testing.T) {
+	got, err := parsePeaksGeoJSON("wainwrights", strings.NewReader(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"name":"Helvellyn","ele":950},"geometry":{"type":"Point","coordinates":[-3.0164,54.5270]}}
+	]}`))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "Helvellyn", got[0].Name)
+	assert.Equal(t, 54.527, got[0].Lat)
+	require.NotNil(t, got[0].ElevationMeters)
+	assert.Equal(t, 950.0, *got[0].ElevationMeters)
+}
+
+func TestLoadPeaksAndBag(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	newTrack := func(ts string) string {
+		f := geojson.NewFeature(orb.LineString{{-4.2, 56.52}, {-4.2214, 56.5448}})
+		f.Properties.CoordinateProperties()["times"] = []interface{}{ts, ts}
+		return insertTestTrack(t, r, "user_1", *f)
+	}
+	first := newTrack("2023-05-01T12:00:00Z")
+	newTrack("2024-06-12T12:00:00Z")
+
+	n, err := r.LoadPeaks(ctx, "munros", "munros.csv", strings.NewReader("name,lat,lng\nBen Lawers,56.5449,-4.2214\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	summits, err := r.ListSummits(ctx, first)
+	require.NoError(t, err)
+	require.Len(t, summits, 1)
+	assert.Equal(t, "Ben Lawers", summits[0].Peak.Name)
+
+	bagged, err := r.ListMyBaggedPeaks(ctx, "user_1", "munros")
+	require.NoError(t, err)
+	require.Len(t, bagged, 1)
+	assert.Equal(t, 2, bagged[0].Visits)
+	assert.Equal(t, time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC), bagged[0].FirstVisitedAt)
+	assert.Equal(t, time.Date(2024, 6, 12, 12, 0, 0, 0, time.UTC), bagged[0].LatestVisitedAt)
+
+	bagged, err = r.ListMyBaggedPeaks(ctx, "user_1", "wainwrights")
+	require.NoError(t, err)
+	assert.Empty(t, bagged)
+}
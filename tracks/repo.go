@@ -5,43 +5,84 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"github.com/dzfranklin/plantopo-api/analysis"
 	"github.com/dzfranklin/plantopo-api/db"
 	"github.com/dzfranklin/plantopo-api/ids"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paulmach/orb"
 	"github.com/paulmach/orb/geojson"
 	"github.com/riverqueue/river"
 	"log/slog"
+	"sort"
+	"strings"
 	"time"
 )
 
 const (
 	maxImportSize = 10 * 1024 * 1024
 
+	// Tracks are processed in batches of this size when refreshing derived
+	// data for every track
+	backfillBatchSize = 500
+
 	trackIdPrefix  = "t"
 	importIdPrefix = "ti"
+	// Import used to return IDs of the form trackimport_<n>
+	legacyImportIdPrefix = "trackimport"
 )
 
 var ErrTrackNotFound = fmt.Errorf("track not found")
+var ErrInvalidSplit = fmt.Errorf("invalid split point")
+var ErrInvalidMerge = fmt.Errorf("invalid merge")
+var ErrInvalidActivityType = fmt.Errorf("invalid activity type")
+var ErrImportTooLarge = fmt.Errorf("import too large")
+var ErrImportNotFound = fmt.Errorf("import not found")
+var ErrInvalidImportAction = fmt.Errorf("invalid import action")
+
+// DuplicateImportError is returned when the user has already uploaded the
+// same file.
+type DuplicateImportError struct {
+	Existing Import
+}
+
+func (e DuplicateImportError) Error() string {
+	return "duplicate import of " + e.Existing.Filename
+}
 
 type Repo struct {
 	pool  *pgxpool.Pool
 	river *river.Client[pgx.Tx]
+	blobs BlobStore
 	q     *db.Queries
 }
 
-func NewRepo(pool *pgxpool.Pool, river *river.Client[pgx.Tx]) *Repo {
-	return &Repo{pool: pool, q: db.New(pool), river: river}
+func NewRepo(pool *pgxpool.Pool, river *river.Client[pgx.Tx], blobs BlobStore) *Repo {
+	return &Repo{pool: pool, q: db.New(pool), river: river, blobs: blobs}
 }
 
 type Track struct {
-	ID         string          `json:"id"`
-	OwnerID    string          `json:"ownerID,omitempty"`
-	Name       string          `json:"name,omitempty"`
-	UploadTime time.Time       `json:"uploadTime"`
-	Time       *time.Time      `json:"time,omitempty"`
-	Geojson    geojson.Feature `json:"geojson"`
+	ID         string     `json:"id"`
+	OwnerID    string     `json:"ownerID,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	UploadTime time.Time  `json:"uploadTime"`
+	Time       *time.Time `json:"time,omitempty"`
+	// Timezone is the IANA timezone at the start of the track
+	Timezone string `json:"timezone,omitempty"`
+	// LocalStartTime and LocalEndTime are in the timezone of the track
+	LocalStartTime *time.Time      `json:"localStartTime,omitempty"`
+	LocalEndTime   *time.Time      `json:"localEndTime,omitempty"`
+	ActivityType   string          `json:"activityType,omitempty"`
+	Geojson        geojson.Feature `json:"geojson"`
+	// Trimmed is true if the original geometry can be restored
+	Trimmed       bool     `json:"trimmed,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	SuggestedTags []string `json:"suggestedTags,omitempty"`
+	// Estimates are calibrated against the owner's tracks when read, and only
+	// included for the owner
+	Estimates *analysis.TrackEstimates `json:"estimates,omitempty"`
 }
 
 type Import struct {
@@ -50,9 +91,23 @@ type Import struct {
 	StartedAt   time.Time  `json:"startedAt"`
 	CompletedAt *time.Time `json:"completedAt,omitempty"`
 	FailedAt    *time.Time `json:"failedAt,omitempty"`
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
 	Error       string     `json:"error,omitempty"`
 	Filename    string     `json:"filename"`
 	ByteSize    int        `json:"byteSize"`
+	// Attempts is how many times processing the import has been tried
+	Attempts int `json:"attempts"`
+	// BatchID is set if the file was part of an archive
+	BatchID string `json:"batchID,omitempty"`
+	// TrackIDs is only included when getting a single import
+	TrackIDs []string `json:"trackIDs,omitempty"`
+	// SkippedDuplicateOfIDs are the existing tracks that tracks in the file
+	// were skipped as duplicates of
+	SkippedDuplicateOfIDs []string `json:"skippedDuplicateOfIDs,omitempty"`
+	// DuplicateOfID is set if the file downloaded for an import from a URL
+	// had already been imported, and is only included when getting a single
+	// import
+	DuplicateOfID string `json:"duplicateOfID,omitempty"`
 }
 
 func (r *Repo) Get(ctx context.Context, id string) (Track, error) {
@@ -67,9 +122,73 @@ func (r *Repo) Get(ctx context.Context, id string) (Track, error) {
 		}
 		return Track{}, err
 	}
+	out := []Track{toTrack(track)}
+	if err := r.attachTags(ctx, out); err != nil {
+		return Track{}, err
+	}
+	if err := r.attachEstimates(ctx, out); err != nil {
+		return Track{}, err
+	}
+	return out[0], nil
+}
+
+// GetMasked gets the track as it should be shown to anyone but its owner,
+// with the points inside the owner's privacy zones removed. A track entirely
+// inside the zones is reported as not found.
+func (r *Repo) GetMasked(ctx context.Context, id string) (Track, error) {
+	tid, err := ids.Unmarshal(trackIdPrefix, id)
+	if err != nil {
+		return Track{}, err
+	}
+	track, err := r.q.GetTrack(ctx, tid)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Track{}, ErrTrackNotFound
+		}
+		return Track{}, err
+	}
+
+	if track.OwnerID != nil {
+		zones, err := r.q.ListPrivacyZones(ctx, *track.OwnerID)
+		if err != nil {
+			return Track{}, err
+		}
+		if len(zones) > 0 {
+			var analysisZones []analysis.PrivacyZone
+			for _, z := range zones {
+				analysisZones = append(analysisZones, analysis.PrivacyZone{
+					Center:       orb.Point{z.Lng, z.Lat},
+					RadiusMeters: z.RadiusMeters,
+				})
+			}
+			original := track.Geojson
+			var visible bool
+			track.Geojson, visible = analysis.MaskPrivacyZones(track.Geojson, analysisZones)
+			if !visible {
+				return Track{}, ErrTrackNotFound
+			}
+			track.Name = maskedTrackName(track.Name, original, track.Geojson)
+		}
+	}
+
 	return toTrack(track), nil
 }
 
+// maskedTrackName renames a track that was named after its places so the
+// name doesn't give away a place label that masking removed.
+func maskedTrackName(name *string, original geojson.Feature, masked geojson.Feature) *string {
+	if name == nil {
+		return nil
+	}
+	if placesName, ok := analysis.PlacesName(original); !ok || placesName != *name {
+		return name
+	}
+	if maskedName, ok := analysis.PlacesName(masked); ok {
+		return &maskedName
+	}
+	return nil
+}
+
 func (r *Repo) Delete(ctx context.Context, id string) error {
 	tID, err := ids.Unmarshal(trackIdPrefix, id)
 	if err != nil {
@@ -87,18 +206,483 @@ func (r *Repo) Delete(ctx context.Context, id string) error {
 	if err != nil {
 		return err
 	}
+	ownerID, err := q.GetTrackOwner(ctx, tID)
+	if err != nil {
+		return err
+	}
 
 	if err := q.DeleteTrack(ctx, tID); err != nil {
 		return err
 	}
 
+	var blobKey *string
 	if tiID != nil {
-		if err := q.DeleteTrackImport(ctx, *tiID); err != nil {
+		blobKey, err = deleteImportIfUnused(ctx, q, *tiID)
+		if err != nil {
 			return err
 		}
 	}
 
-	return tx.Commit(ctx)
+	if err := enqueueTrackDeleted(ctx, r.river, tx, ownerID, tID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.deleteBlobs(ctx, blobKey)
+	return nil
+}
+
+// Trim removes idle periods from the start and end of the track, keeping the
+// original so it can be restored. If there is nothing to trim the track is
+// returned unchanged.
+func (r *Repo) Trim(ctx context.Context, id string) (Track, error) {
+	tID, err := ids.Unmarshal(trackIdPrefix, id)
+	if err != nil {
+		return Track{}, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Track{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	track, err := q.GetTrack(ctx, tID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Track{}, ErrTrackNotFound
+		}
+		return Track{}, err
+	}
+
+	trimmed, ok := analysis.TrimIdle(track.Geojson)
+	if !ok {
+		return toTrack(track), nil
+	}
+
+	err = q.TrimTrack(ctx, db.TrimTrackParams{
+		ID:      tID,
+		Geojson: trimmed,
+		Time:    editedTrackTime(&trimmed, track.Time),
+	})
+	if err != nil {
+		return Track{}, err
+	}
+	if err := refreshSummits(ctx, q, tID); err != nil {
+		return Track{}, err
+	}
+
+	track, err = q.GetTrack(ctx, tID)
+	if err != nil {
+		return Track{}, err
+	}
+
+	return toTrack(track), tx.Commit(ctx)
+}
+
+// RestoreOriginal undoes any trimming of the track.
+func (r *Repo) RestoreOriginal(ctx context.Context, id string) (Track, error) {
+	tID, err := ids.Unmarshal(trackIdPrefix, id)
+	if err != nil {
+		return Track{}, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Track{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	track, err := q.GetTrack(ctx, tID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Track{}, ErrTrackNotFound
+		}
+		return Track{}, err
+	}
+	if track.OriginalGeojson == nil {
+		return toTrack(track), nil
+	}
+
+	// Trimming the start changes the time, so it is recomputed from the
+	// original
+	err = q.RestoreTrackOriginal(ctx, db.RestoreTrackOriginalParams{
+		ID:   tID,
+		Time: editedTrackTime(track.OriginalGeojson, track.Time),
+	})
+	if err != nil {
+		return Track{}, err
+	}
+	track, err = q.GetTrack(ctx, tID)
+	if err != nil {
+		return Track{}, err
+	}
+	if err := refreshSummits(ctx, q, tID); err != nil {
+		return Track{}, err
+	}
+
+	return toTrack(track), tx.Commit(ctx)
+}
+
+// Split splits the track in two at the point index. The track keeps the first
+// part and a new track is created for the second.
+func (r *Repo) Split(ctx context.Context, id string, index int) ([]Track, error) {
+	return r.split(ctx, id, func(geojson.Feature) (int, bool) {
+		return index, true
+	})
+}
+
+// SplitAtTime splits the track at the first point recorded at or after t.
+func (r *Repo) SplitAtTime(ctx context.Context, id string, t time.Time) ([]Track, error) {
+	return r.split(ctx, id, func(f geojson.Feature) (int, bool) {
+		return analysis.IndexAtTime(f, t)
+	})
+}
+
+func (r *Repo) split(ctx context.Context, id string, findIndex func(geojson.Feature) (int, bool)) ([]Track, error) {
+	tID, err := ids.Unmarshal(trackIdPrefix, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	track, err := q.GetTrack(ctx, tID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTrackNotFound
+		}
+		return nil, err
+	}
+
+	index, ok := findIndex(track.Geojson)
+	if !ok {
+		return nil, ErrInvalidSplit
+	}
+	first, second, err := analysis.SplitTrack(track.Geojson, index)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSplit, err)
+	}
+	firstOriginal, secondOriginal, err := splitOriginal(track, index, first, second)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.UpdateTrackGeojson(ctx, db.UpdateTrackGeojsonParams{
+		ID:              tID,
+		Geojson:         first,
+		Time:            editedTrackTime(&first, track.Time),
+		OriginalGeojson: firstOriginal,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	secondName := stringFromNullable(track.Name) + " (2)"
+	secondTime := editedTrackTime(&second, track.Time)
+	secondID, err := q.InsertImportedTrack(ctx, db.InsertImportedTrackParams{
+		OwnerID:         track.OwnerID,
+		Name:            &secondName,
+		UploadTime:      track.UploadTime,
+		Time:            secondTime,
+		Geojson:         second,
+		ImportID:        track.ImportID,
+		OriginalGeojson: secondOriginal,
+		ActivityType:    track.ActivityType,
+		Timezone:        trackTimezone(second),
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = enqueueTrackCreated(ctx, r.river, tx, track.OwnerID, secondID, &secondName, secondTime, track.ActivityType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the second half in the same collections, immediately after the first
+	if err := q.MakeRoomAfterCollectionTrack(ctx, tID); err != nil {
+		return nil, err
+	}
+	err = q.InsertCollectionTrackAfter(ctx, db.InsertCollectionTrackAfterParams{
+		NewTrackID: secondID,
+		TrackID:    tID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = q.CopyTrackTags(ctx, db.CopyTrackTagsParams{
+		ToTrackID:    secondID,
+		FromTrackIds: []int64{tID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Track, 0, 2)
+	for _, partID := range []int64{tID, secondID} {
+		if err := refreshSummits(ctx, q, partID); err != nil {
+			return nil, err
+		}
+		part, err := q.GetTrack(ctx, partID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, toTrack(part))
+	}
+
+	return out, tx.Commit(ctx)
+}
+
+// Merge combines the tracks into a single new track in chronological order,
+// deleting the originals.
+func (r *Repo) Merge(ctx context.Context, trackIDs []string) (Track, error) {
+	if len(trackIDs) < 2 {
+		return Track{}, fmt.Errorf("%w: need at least two tracks", ErrInvalidMerge)
+	}
+	seen := make(map[string]struct{}, len(trackIDs))
+	for _, id := range trackIDs {
+		if _, ok := seen[id]; ok {
+			return Track{}, fmt.Errorf("%w: duplicate track %s", ErrInvalidMerge, id)
+		}
+		seen[id] = struct{}{}
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Track{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	var sources []db.Track
+	for _, id := range trackIDs {
+		tID, err := ids.Unmarshal(trackIdPrefix, id)
+		if err != nil {
+			return Track{}, err
+		}
+		track, err := q.GetTrack(ctx, tID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return Track{}, ErrTrackNotFound
+			}
+			return Track{}, err
+		}
+		sources = append(sources, track)
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Time.Time.Before(sources[j].Time.Time)
+	})
+
+	features := make([]geojson.Feature, 0, len(sources))
+	for _, track := range sources {
+		features = append(features, track.Geojson)
+	}
+	merged, err := analysis.MergeTracks(features)
+	if err != nil {
+		return Track{}, err
+	}
+
+	mergedOriginal, err := mergeOriginals(sources)
+	if err != nil {
+		return Track{}, err
+	}
+
+	first := sources[0]
+	mergedID, err := q.InsertImportedTrack(ctx, db.InsertImportedTrackParams{
+		OwnerID:         first.OwnerID,
+		Name:            first.Name,
+		UploadTime:      first.UploadTime,
+		Time:            editedTrackTime(&merged, first.Time),
+		Geojson:         merged,
+		ImportID:        first.ImportID,
+		OriginalGeojson: mergedOriginal,
+		ActivityType:    first.ActivityType,
+		Timezone:        trackTimezone(merged),
+	})
+	if err != nil {
+		return Track{}, err
+	}
+
+	sourceIDs := make([]int64, 0, len(sources))
+	for _, track := range sources {
+		sourceIDs = append(sourceIDs, track.ID)
+	}
+	err = q.CopyCollectionMemberships(ctx, db.CopyCollectionMembershipsParams{
+		MergedTrackID:  mergedID,
+		SourceTrackIds: sourceIDs,
+	})
+	if err != nil {
+		return Track{}, err
+	}
+	err = q.CopyTrackTags(ctx, db.CopyTrackTagsParams{
+		ToTrackID:    mergedID,
+		FromTrackIds: sourceIDs,
+	})
+	if err != nil {
+		return Track{}, err
+	}
+
+	var blobKeys []*string
+	for _, track := range sources {
+		if err := q.DeleteTrack(ctx, track.ID); err != nil {
+			return Track{}, err
+		}
+		if track.ImportID != nil {
+			blobKey, err := deleteImportIfUnused(ctx, q, *track.ImportID)
+			if err != nil {
+				return Track{}, err
+			}
+			blobKeys = append(blobKeys, blobKey)
+		}
+		if err := enqueueTrackDeleted(ctx, r.river, tx, track.OwnerID, track.ID); err != nil {
+			return Track{}, err
+		}
+	}
+
+	if err := refreshSummits(ctx, q, mergedID); err != nil {
+		return Track{}, err
+	}
+
+	track, err := q.GetTrack(ctx, mergedID)
+	if err != nil {
+		return Track{}, err
+	}
+	err = enqueueTrackCreated(ctx, r.river, tx, track.OwnerID, track.ID, track.Name, track.Time, track.ActivityType)
+	if err != nil {
+		return Track{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Track{}, err
+	}
+	r.deleteBlobs(ctx, blobKeys...)
+	return toTrack(track), nil
+}
+
+// splitOriginal splits the untrimmed original of a track where the track is
+// split, so that each part can still be restored. A part that wasn't trimmed
+// gets no original.
+func splitOriginal(track db.Track, index int, first, second geojson.Feature) (*geojson.Feature, *geojson.Feature, error) {
+	if track.OriginalGeojson == nil {
+		return nil, nil, nil
+	}
+	offset, ok := analysis.SliceOffset(*track.OriginalGeojson, track.Geojson)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: the track no longer matches its original, restore it first", ErrInvalidSplit)
+	}
+	originalFirst, originalSecond, err := analysis.SplitTrack(*track.OriginalGeojson, offset+index)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidSplit, err)
+	}
+	return trimmedOriginal(originalFirst, first), trimmedOriginal(originalSecond, second), nil
+}
+
+// mergeOriginals merges the untrimmed originals of the tracks, or returns nil
+// if none of them were trimmed.
+func mergeOriginals(sources []db.Track) (*geojson.Feature, error) {
+	var trimmed bool
+	originals := make([]geojson.Feature, 0, len(sources))
+	for _, track := range sources {
+		if track.OriginalGeojson != nil {
+			trimmed = true
+			originals = append(originals, *track.OriginalGeojson)
+		} else {
+			originals = append(originals, track.Geojson)
+		}
+	}
+	if !trimmed {
+		return nil, nil
+	}
+	merged, err := analysis.MergeTracks(originals)
+	if err != nil {
+		return nil, err
+	}
+	return &merged, nil
+}
+
+// trimmedOriginal returns the original if it has points that f doesn't.
+func trimmedOriginal(original geojson.Feature, f geojson.Feature) *geojson.Feature {
+	originalLine, _ := original.Geometry.(orb.LineString)
+	line, _ := f.Geometry.(orb.LineString)
+	if len(originalLine) == len(line) {
+		return nil
+	}
+	return &original
+}
+
+func editedTrackTime(f *geojson.Feature, fallback pgtype.Timestamptz) pgtype.Timestamptz {
+	if t, ok := analysis.ParseSloppyRecentTime(f.Properties["time"]); ok {
+		return pgtype.Timestamptz{Time: t, Valid: true}
+	}
+	return fallback
+}
+
+func trackTimezone(f geojson.Feature) *string {
+	if tz, ok := analysis.TrackTimezone(f); ok {
+		return &tz
+	}
+	return nil
+}
+
+// SetActivityType overrides the detected activity type of the track,
+// recomputing the stats that depend on it.
+func (r *Repo) SetActivityType(ctx context.Context, id string, activityType string) (Track, error) {
+	tID, err := ids.Unmarshal(trackIdPrefix, id)
+	if err != nil {
+		return Track{}, err
+	}
+	parsed, ok := analysis.ParseActivityType(activityType)
+	if !ok {
+		return Track{}, ErrInvalidActivityType
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Track{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	track, err := q.GetTrack(ctx, tID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Track{}, ErrTrackNotFound
+		}
+		return Track{}, err
+	}
+
+	// The override applies to the original too so restoring it doesn't undo
+	// the user's choice
+	analysis.SetActivityType(track.Geojson, parsed, analysis.ActivityTypeFromUser)
+	if track.OriginalGeojson != nil {
+		analysis.SetActivityType(*track.OriginalGeojson, parsed, analysis.ActivityTypeFromUser)
+	}
+	value := string(parsed)
+	err = q.UpdateTrackActivityType(ctx, db.UpdateTrackActivityTypeParams{
+		ID:              tID,
+		ActivityType:    &value,
+		Geojson:         track.Geojson,
+		OriginalGeojson: track.OriginalGeojson,
+	})
+	if err != nil {
+		return Track{}, err
+	}
+
+	track, err = q.GetTrack(ctx, tID)
+	if err != nil {
+		return Track{}, err
+	}
+	return toTrack(track), tx.Commit(ctx)
 }
 
 func (r *Repo) IsOwner(ctx context.Context, userId string, trackId string) (bool, error) {
@@ -121,23 +705,183 @@ func (r *Repo) IsOwner(ctx context.Context, userId string, trackId string) (bool
 	return *owner == userId, nil
 }
 
-func (r *Repo) ListMyTracksOrderByTime(ctx context.Context, userID string) ([]Track, error) {
-	tracks, err := r.q.ListTracksOrderByTime(ctx, &userID)
+// BackfillTimezones resolves the timezone of any tracks imported before
+// timezones were stored. It returns the number of tracks updated.
+func (r *Repo) BackfillTimezones(ctx context.Context) (int, error) {
+	var after int64
+	var total int
+	for {
+		tracks, err := r.q.ListTracksMissingTimezone(ctx, db.ListTracksMissingTimezoneParams{
+			ID:    after,
+			Limit: backfillBatchSize,
+		})
+		if err != nil {
+			return total, err
+		}
+		if len(tracks) == 0 {
+			return total, nil
+		}
+		for _, t := range tracks {
+			tz := trackTimezone(t.Geojson)
+			if tz == nil {
+				continue
+			}
+			err := r.q.SetTrackTimezone(ctx, db.SetTrackTimezoneParams{ID: t.ID, Timezone: tz})
+			if err != nil {
+				return total, err
+			}
+			total++
+		}
+		after = tracks[len(tracks)-1].ID
+	}
+}
+
+// MovingTimeCalibration calibrates moving time estimates against the user's
+// own recorded tracks.
+func (r *Repo) MovingTimeCalibration(ctx context.Context, userID string) (analysis.Calibration, error) {
+	rows, err := r.q.ListTrackMovingTimeSamples(ctx, &userID)
+	if err != nil {
+		return analysis.Calibration{}, err
+	}
+	samples := make([]analysis.CalibrationSample, 0, len(rows))
+	for _, row := range rows {
+		samples = append(samples, analysis.CalibrationSample{
+			MovingSecs:   row.MovingSecs,
+			NaismithSecs: row.NaismithSecs,
+			ToblerSecs:   row.ToblerSecs,
+		})
+	}
+	return analysis.Calibrate(samples), nil
+}
+
+// attachEstimates sets the calibrated estimates of tracks that have them,
+// calibrating once per owner.
+func (r *Repo) attachEstimates(ctx context.Context, tracks []Track) error {
+	calibrations := make(map[string]analysis.Calibration)
+	for i := range tracks {
+		t := &tracks[i]
+		if t.OwnerID == "" {
+			continue
+		}
+		calibration, ok := calibrations[t.OwnerID]
+		if !ok {
+			var err error
+			calibration, err = r.MovingTimeCalibration(ctx, t.OwnerID)
+			if err != nil {
+				return err
+			}
+			calibrations[t.OwnerID] = calibration
+		}
+		if estimates, ok := calibration.Estimates(t.Geojson); ok {
+			t.Estimates = &estimates
+		}
+	}
+	return nil
+}
+
+// BackfillEstimates stores the raw moving time estimates of any tracks
+// imported before they were computed. It returns the number of tracks
+// updated.
+func (r *Repo) BackfillEstimates(ctx context.Context) (int, error) {
+	var after int64
+	var total int
+	for {
+		tracks, err := r.q.ListTracksMissingEstimates(ctx, db.ListTracksMissingEstimatesParams{
+			ID:    after,
+			Limit: backfillBatchSize,
+		})
+		if err != nil {
+			return total, err
+		}
+		if len(tracks) == 0 {
+			return total, nil
+		}
+		for _, t := range tracks {
+			analysis.RecomputeStats(t.Geojson)
+			if _, ok := t.Geojson.Properties["naismithSecs"]; !ok {
+				continue
+			}
+			err := r.q.SetTrackGeojson(ctx, db.SetTrackGeojsonParams{ID: t.ID, Geojson: t.Geojson})
+			if err != nil {
+				return total, err
+			}
+			total++
+		}
+		after = tracks[len(tracks)-1].ID
+	}
+}
+
+type ListOrder string
+
+const (
+	OrderByTime ListOrder = "time"
+	// OrderByPosition orders by the manual order of a collection
+	OrderByPosition ListOrder = "position"
+)
+
+var ErrInvalidListOptions = fmt.Errorf("invalid list options")
+
+type ListOptions struct {
+	OrderBy      ListOrder
+	CollectionID string
+	Tags         []string
+	// MatchAllTags requires tracks to have every tag rather than any
+	MatchAllTags bool
+}
+
+func (r *Repo) ListMyTracks(ctx context.Context, userID string, opts ListOptions) ([]Track, error) {
+	tags, err := normalizeTags(opts.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidListOptions, err)
+	}
+	params := db.ListTracksParams{
+		OwnerID:      &userID,
+		Tags:         tags,
+		MatchAllTags: opts.MatchAllTags,
+	}
+
+	if opts.CollectionID != "" {
+		cID, err := ids.Unmarshal(collectionIdPrefix, opts.CollectionID)
+		if err != nil {
+			return nil, ErrCollectionNotFound
+		}
+		params.CollectionID = &cID
+	}
+
+	switch opts.OrderBy {
+	case OrderByTime:
+	case OrderByPosition:
+		if params.CollectionID == nil {
+			return nil, fmt.Errorf("%w: ordering by position requires a collection", ErrInvalidListOptions)
+		}
+		params.OrderByPosition = true
+	default:
+		return nil, fmt.Errorf("%w: invalid order", ErrInvalidListOptions)
+	}
+
+	tracks, err := r.q.ListTracks(ctx, params)
 	if err != nil {
 		return nil, err
 	}
-	out := make([]Track, 0)
+	out := make([]Track, 0, len(tracks))
 	for _, t := range tracks {
 		out = append(out, toTrack(t))
 	}
+	if err := r.attachTags(ctx, out); err != nil {
+		return nil, err
+	}
+	if err := r.attachEstimates(ctx, out); err != nil {
+		return nil, err
+	}
 	return out, nil
 }
 
-func (r *Repo) Import(ctx context.Context, ownerID string, filename string, data []byte) (string, error) {
+func (r *Repo) Import(ctx context.Context, ownerID string, filename string, data []byte, opts ImportOptions) (string, error) {
 	if len(data) > maxImportSize {
 		slog.Warn("import too large", "size", len(data), "max", maxImportSize)
-		return "", fmt.Errorf("import too large")
+		return "", ErrImportTooLarge
 	}
+	hash := hashImport(ownerID, filename, data)
 
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
@@ -146,17 +890,21 @@ func (r *Repo) Import(ctx context.Context, ownerID string, filename string, data
 	defer tx.Rollback(ctx)
 	q := r.q.WithTx(tx)
 
-	id, err := q.InsertTrackImport(ctx, db.InsertTrackImportParams{
-		OwnerID:  ownerID,
-		Hash:     hashImport(ownerID, filename, data),
-		Filename: filename,
-		Data:     data,
-	})
+	err = r.insertImport(ctx, tx, db.InsertTrackImportParams{
+		OwnerID:        ownerID,
+		Hash:           hash,
+		Filename:       filename,
+		SkipDuplicates: opts.SkipDuplicates,
+	}, data)
 	if err != nil {
+		if isDuplicateImport(err) {
+			return "", r.duplicateImportError(ctx, hash)
+		}
 		return "", err
 	}
 
-	_, err = r.river.InsertTx(ctx, tx, &ImportWorkerArgs{Id: id}, nil)
+	publicID := ids.MarshalHash(importIdPrefix, hash)
+	err = notifyImportEvent(ctx, q, ownerID, ImportEvent{ImportID: publicID, Status: ImportQueued})
 	if err != nil {
 		return "", err
 	}
@@ -166,32 +914,254 @@ func (r *Repo) Import(ctx context.Context, ownerID string, filename string, data
 		return "", err
 	}
 
-	return fmt.Sprintf("trackimport_%d", id), nil
+	return publicID, nil
+}
+
+// RetryImport processes a failed import again, for example after the
+// converter was down.
+func (r *Repo) RetryImport(ctx context.Context, id string) (Import, error) {
+	importId, err := r.unmarshalImportID(ctx, id)
+	if err != nil {
+		return Import{}, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Import{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	n, err := q.ResetTrackImportFailure(ctx, importId)
+	if err != nil {
+		return Import{}, err
+	}
+	if n == 0 {
+		return Import{}, fmt.Errorf("%w: only failed imports can be retried", ErrInvalidImportAction)
+	}
+
+	data, err := q.GetTrackImport(ctx, importId)
+	if err != nil {
+		return Import{}, err
+	}
+	if data.BlobKey == nil && data.SourceUrl != nil {
+		// The download failed, so start again from there
+		err = r.enqueueURLImport(ctx, tx, importId, data.OwnerID, *data.SourceUrl)
+	} else {
+		err = r.enqueueImport(ctx, tx, importId)
+	}
+	if err != nil {
+		return Import{}, err
+	}
+
+	out, err := r.importStatusWith(ctx, q, importId)
+	if err != nil {
+		return Import{}, err
+	}
+	err = notifyImportEvent(ctx, q, out.OwnerID, ImportEvent{ImportID: out.ID, Status: ImportQueued})
+	if err != nil {
+		return Import{}, err
+	}
+
+	return out, tx.Commit(ctx)
 }
 
+// CancelImport stops processing an import that hasn't finished and discards
+// the uploaded file.
+func (r *Repo) CancelImport(ctx context.Context, id string) (Import, error) {
+	importId, err := r.unmarshalImportID(ctx, id)
+	if err != nil {
+		return Import{}, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Import{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	cancelled, err := q.CancelTrackImport(ctx, importId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Import{}, fmt.Errorf("%w: only pending imports can be cancelled", ErrInvalidImportAction)
+		}
+		return Import{}, err
+	}
+
+	if cancelled.JobID != nil {
+		_, err := r.river.JobCancelTx(ctx, tx, *cancelled.JobID)
+		if err != nil && !errors.Is(err, river.ErrNotFound) {
+			return Import{}, err
+		}
+	}
+
+	out, err := r.importStatusWith(ctx, q, importId)
+	if err != nil {
+		return Import{}, err
+	}
+	err = notifyImportEvent(ctx, q, out.OwnerID, ImportEvent{ImportID: out.ID, Status: ImportCancelled})
+	if err != nil {
+		return Import{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Import{}, err
+	}
+	r.deleteBlobs(ctx, cancelled.BlobKey)
+	return out, nil
+}
+
+// insertImport stores the file, adds the import and schedules it to be
+// processed. If the file was already imported the error satisfies
+// isDuplicateImport, and tx is aborted.
+func (r *Repo) insertImport(ctx context.Context, tx pgx.Tx, params db.InsertTrackImportParams, data []byte) error {
+	q := r.q.WithTx(tx)
+
+	// Written first so the import never refers to a missing blob. If the
+	// transaction fails the blob is orphaned, unless the file is uploaded
+	// again.
+	blobKey := importBlobKey(params.Hash)
+	if err := r.blobs.Put(ctx, blobKey, data); err != nil {
+		return err
+	}
+	params.BlobKey = &blobKey
+	params.ByteSize = int64(len(data))
+	params.ContentHash = params.Hash
+
+	// A cancelled upload of the same file can be started again
+	if err := q.DeleteCancelledTrackImport(ctx, params.Hash); err != nil {
+		return err
+	}
+
+	id, err := q.InsertTrackImport(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	return r.enqueueImport(ctx, tx, id)
+}
+
+func isDuplicateImport(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(pgErr.ConstraintName == "track_imports_hash_idx" || pgErr.ConstraintName == "track_imports_content_hash_idx")
+}
+
+// enqueueImport schedules the import to be processed, recording the job so
+// that it can be cancelled.
+func (r *Repo) enqueueImport(ctx context.Context, tx pgx.Tx, importId int64) error {
+	res, err := r.river.InsertTx(ctx, tx, &ImportWorkerArgs{Id: importId}, nil)
+	if err != nil {
+		return err
+	}
+	return r.q.WithTx(tx).SetTrackImportJob(ctx, db.SetTrackImportJobParams{
+		ID:    importId,
+		JobID: &res.Job.ID,
+	})
+}
+
+// duplicateImportError looks up the existing import of the file with the
+// hash. It must be called outside the transaction the conflicting insert
+// aborted.
+func (r *Repo) duplicateImportError(ctx context.Context, contentHash []byte) error {
+	id, err := r.q.GetTrackImportIDByContentHash(ctx, contentHash)
+	if err != nil {
+		return err
+	}
+	existing, err := r.importStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	return DuplicateImportError{Existing: existing}
+}
+
+// ImportStatus gets an import by the ID Import returned, including the IDs
+// of the tracks it created so far.
 func (r *Repo) ImportStatus(ctx context.Context, id string) (Import, error) {
-	importId, err := ids.Unmarshal(importIdPrefix, id)
+	importId, err := r.unmarshalImportID(ctx, id)
 	if err != nil {
 		return Import{}, err
 	}
+	return r.importStatus(ctx, importId)
+}
+
+// unmarshalImportID accepts the hash-based IDs the API hands out, as well
+// as the trackimport_<n> IDs Import used to return.
+func (r *Repo) unmarshalImportID(ctx context.Context, id string) (int64, error) {
+	if strings.HasPrefix(id, legacyImportIdPrefix+"_") {
+		importId, err := ids.Unmarshal(legacyImportIdPrefix, id)
+		if err != nil {
+			return 0, ErrImportNotFound
+		}
+		return importId, nil
+	}
+
+	hash, err := ids.UnmarshalHash(importIdPrefix, id)
+	if err != nil {
+		return 0, ErrImportNotFound
+	}
+	importId, err := r.q.GetTrackImportIDByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrImportNotFound
+		}
+		return 0, err
+	}
+	return importId, nil
+}
+
+func (r *Repo) importStatus(ctx context.Context, importId int64) (Import, error) {
+	return r.importStatusWith(ctx, r.q, importId)
+}
 
-	data, err := r.q.GetTrackImportStatus(ctx, importId)
+func (r *Repo) importStatusWith(ctx context.Context, q *db.Queries, importId int64) (Import, error) {
+	data, err := q.GetTrackImportStatus(ctx, importId)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Import{}, ErrImportNotFound
+		}
 		return Import{}, err
 	}
 
+	trackIDs, err := q.ListImportTrackIDs(ctx, &importId)
+	if err != nil {
+		return Import{}, err
+	}
 	return Import{
 		ID:          ids.MarshalHash(importIdPrefix, data.Hash),
 		OwnerID:     data.OwnerID,
 		StartedAt:   data.InsertedAt.Time,
 		CompletedAt: pgTimestampToNullable(data.CompletedAt),
 		FailedAt:    pgTimestampToNullable(data.FailedAt),
+		CancelledAt: pgTimestampToNullable(data.CancelledAt),
 		Error:       stringFromNullable(data.Error),
 		Filename:    data.Filename,
 		ByteSize:    int(data.ByteSize),
+		Attempts:    int(data.Attempts),
+		BatchID:     marshalBatchID(data.BatchID),
+		TrackIDs:    marshalTrackIDs(trackIDs),
+
+		SkippedDuplicateOfIDs: marshalTrackIDs(data.SkippedDuplicateOfIds),
+		DuplicateOfID:         marshalImportHash(data.DuplicateOfHash),
 	}, nil
 }
 
+func marshalTrackIDs(trackIDs []int64) []string {
+	out := make([]string, 0, len(trackIDs))
+	for _, tID := range trackIDs {
+		out = append(out, ids.Marshal(trackIdPrefix, tID))
+	}
+	return out
+}
+
+func marshalImportHash(hash []byte) string {
+	if hash == nil {
+		return ""
+	}
+	return ids.MarshalHash(importIdPrefix, hash)
+}
+
 func (r *Repo) ListMyPendingOrRecentImports(ctx context.Context, userID string) ([]Import, error) {
 	imports, err := r.q.ListMyPendingOrRecentImports(ctx, userID)
 	if err != nil {
@@ -205,23 +1175,47 @@ func (r *Repo) ListMyPendingOrRecentImports(ctx context.Context, userID string)
 			StartedAt:   i.InsertedAt.Time,
 			CompletedAt: pgTimestampToNullable(i.CompletedAt),
 			FailedAt:    pgTimestampToNullable(i.FailedAt),
+			CancelledAt: pgTimestampToNullable(i.CancelledAt),
 			Error:       stringFromNullable(i.Error),
 			Filename:    i.Filename,
 			ByteSize:    int(i.ByteSize),
+			Attempts:    int(i.Attempts),
+			BatchID:     marshalBatchID(i.BatchID),
+
+			SkippedDuplicateOfIDs: marshalTrackIDs(i.SkippedDuplicateOfIds),
 		})
 	}
 	return out, nil
 }
 
 func toTrack(data db.Track) Track {
-	return Track{
-		ID:         ids.Marshal(trackIdPrefix, data.ID),
-		OwnerID:    stringFromNullable(data.OwnerID),
-		Name:       stringFromNullable(data.Name),
-		UploadTime: data.UploadTime.Time,
-		Time:       pgTimestampToNullable(data.Time),
-		Geojson:    data.Geojson,
+	t := Track{
+		ID:            ids.Marshal(trackIdPrefix, data.ID),
+		OwnerID:       stringFromNullable(data.OwnerID),
+		Name:          stringFromNullable(data.Name),
+		UploadTime:    data.UploadTime.Time,
+		Time:          pgTimestamptzToNullable(data.Time),
+		Timezone:      stringFromNullable(data.Timezone),
+		ActivityType:  stringFromNullable(data.ActivityType),
+		Geojson:       data.Geojson,
+		Trimmed:       data.OriginalGeojson != nil,
+		SuggestedTags: suggestTags(data.Geojson),
+	}
+
+	if t.Timezone != "" {
+		loc, err := time.LoadLocation(t.Timezone)
+		if err == nil {
+			if start, end, ok := analysis.TrackTimeRange(data.Geojson); ok {
+				start, end = start.In(loc), end.In(loc)
+				t.LocalStartTime, t.LocalEndTime = &start, &end
+			} else if t.Time != nil {
+				start := t.Time.In(loc)
+				t.LocalStartTime = &start
+			}
+		}
 	}
+
+	return t
 }
 
 func pgTimestampToNullable(t pgtype.Timestamp) *time.Time {
@@ -231,6 +1225,13 @@ func pgTimestampToNullable(t pgtype.Timestamp) *time.Time {
 	return nil
 }
 
+func pgTimestamptzToNullable(t pgtype.Timestamptz) *time.Time {
+	if t.Valid {
+		return &t.Time
+	}
+	return nil
+}
+
 func stringFromNullable(s *string) string {
 	if s == nil {
 		return ""
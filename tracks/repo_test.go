@@ -2,15 +2,23 @@ package tracks
 
 import (
 	"context"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/analysis"
+	"github.com/dzfranklin/plantopo-api/blobs"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
 	"github.com/dzfranklin/plantopo-api/testsupport"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/riverdriver/riverpgxv5"
 	"github.com/riverqueue/river/rivertest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"strconv"
 	"testing"
+	"time"
 )
 
 func newSubject(t *testing.T) *Repo {
@@ -34,12 +42,19 @@ func newSubjectWithDriver(t *testing.T) (*riverpgxv5.Driver, *Repo) {
 		t.Fatal(err)
 	}
 
-	return driver, NewRepo(pool, riverClient)
+	return driver, NewRepo(pool, riverClient, newTestBlobStore(t))
+}
+
+func newTestBlobStore(t *testing.T) *blobs.Local {
+	t.Helper()
+	store, err := blobs.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	return store
 }
 
 func TestListOrderByTimeEmpty(t *testing.T) {
 	r := newSubject(t)
-	tracks, err := r.ListMyTracksOrderByTime(context.Background(), "user_1")
+	tracks, err := r.ListMyTracks(context.Background(), "user_1", ListOptions{OrderBy: OrderByTime})
 	require.NoError(t, err)
 	assert.Equal(t, 0, len(tracks))
 }
@@ -48,12 +63,361 @@ func TestImportEnqueues(t *testing.T) {
 	ctx := context.Background()
 	driver, r := newSubjectWithDriver(t)
 
-	id, err := r.Import(ctx, "user_1", "file.gpx", []byte("data"))
+	id, err := r.Import(ctx, "user_1", "file.gpx", []byte("data"), ImportOptions{})
 	require.NoError(t, err)
 	assert.NotEmpty(t, id)
 
-	idInt, err := strconv.ParseInt(id[len("trackimport_"):], 10, 64)
+	idInt, err := r.unmarshalImportID(ctx, id)
 	require.NoError(t, err)
 
 	rivertest.RequireInserted(ctx, t, driver, &ImportWorkerArgs{Id: idInt}, nil)
 }
+
+func TestImportStatus(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	id, err := r.Import(ctx, "user_1", "file.gpx", []byte("data"), ImportOptions{})
+	require.NoError(t, err)
+
+	got, err := r.ImportStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, "file.gpx", got.Filename)
+	assert.Empty(t, got.TrackIDs)
+
+	mine, err := r.ListMyPendingOrRecentImports(ctx, "user_1")
+	require.NoError(t, err)
+	require.Len(t, mine, 1)
+	assert.Equal(t, id, mine[0].ID)
+
+	importId, err := r.unmarshalImportID(ctx, id)
+	require.NoError(t, err)
+	legacy, err := r.ImportStatus(ctx, fmt.Sprintf("trackimport_%d", importId))
+	require.NoError(t, err)
+	assert.Equal(t, id, legacy.ID)
+
+	_, err = r.ImportStatus(ctx, "ti_00")
+	assert.ErrorIs(t, err, ErrImportNotFound)
+	_, err = r.ImportStatus(ctx, "nonsense")
+	assert.ErrorIs(t, err, ErrImportNotFound)
+}
+
+func TestImportDuplicate(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	_, err := r.Import(ctx, "user_1", "file.gpx", []byte("data"), ImportOptions{})
+	require.NoError(t, err)
+
+	_, err = r.Import(ctx, "user_1", "file.gpx", []byte("data"), ImportOptions{})
+	var duplicateErr DuplicateImportError
+	require.ErrorAs(t, err, &duplicateErr)
+	assert.Equal(t, "file.gpx", duplicateErr.Existing.Filename)
+	assert.Equal(t, "user_1", duplicateErr.Existing.OwnerID)
+
+	// The same file from another user isn't a duplicate
+	_, err = r.Import(ctx, "user_2", "file.gpx", []byte("data"), ImportOptions{})
+	require.NoError(t, err)
+}
+
+func TestCancelImport(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	id, err := r.Import(ctx, "user_1", "file.gpx", []byte("data"), ImportOptions{})
+	require.NoError(t, err)
+
+	importId, err := r.unmarshalImportID(ctx, id)
+	require.NoError(t, err)
+	row, err := r.q.GetTrackImport(ctx, importId)
+	require.NoError(t, err)
+	require.NotNil(t, row.BlobKey)
+
+	cancelled, err := r.CancelImport(ctx, id)
+	require.NoError(t, err)
+	assert.NotNil(t, cancelled.CancelledAt)
+
+	// The file is discarded
+	_, err = r.blobs.Get(ctx, *row.BlobKey)
+	assert.ErrorIs(t, err, blobs.ErrNotFound)
+
+	_, err = r.CancelImport(ctx, id)
+	assert.ErrorIs(t, err, ErrInvalidImportAction)
+	_, err = r.RetryImport(ctx, id)
+	assert.ErrorIs(t, err, ErrInvalidImportAction)
+
+	// A cancelled file can be uploaded again
+	_, err = r.Import(ctx, "user_1", "file.gpx", []byte("data"), ImportOptions{})
+	require.NoError(t, err)
+}
+
+func TestRetryImport(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	id, err := r.Import(ctx, "user_1", "file.gpx", []byte("data"), ImportOptions{})
+	require.NoError(t, err)
+
+	_, err = r.RetryImport(ctx, id)
+	assert.ErrorIs(t, err, ErrInvalidImportAction)
+
+	importId, err := r.unmarshalImportID(ctx, id)
+	require.NoError(t, err)
+	message := "failed"
+	_, err = r.q.MarkTrackImportFailed(ctx, db.MarkTrackImportFailedParams{ID: importId, Error: &message})
+	require.NoError(t, err)
+
+	retried, err := r.RetryImport(ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, retried.FailedAt)
+	assert.Empty(t, retried.Error)
+}
+
+func TestTrimAndRestoreOriginal(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.00001, 56.00001}, {-4.0, 56.001}, {-4.0, 56.002}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:05:00Z",
+		"2024-06-12T09:06:00Z",
+		"2024-06-12T09:07:00Z",
+	}
+	f.Properties["time"] = "2024-06-12T09:00:00Z"
+	id := insertTestTrack(t, r, "user_1", *f)
+
+	trimmed, err := r.Trim(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, trimmed.Trimmed)
+	assert.Len(t, trimmed.Geojson.Geometry, 3)
+	require.NotNil(t, trimmed.Time)
+	assert.Equal(t, time.Date(2024, 6, 12, 9, 5, 0, 0, time.UTC), trimmed.Time.UTC())
+
+	restored, err := r.RestoreOriginal(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, restored.Trimmed)
+	assert.Len(t, restored.Geojson.Geometry, 4)
+	require.NotNil(t, restored.Time)
+	assert.Equal(t, time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC), restored.Time.UTC())
+}
+
+func TestSetActivityTypeSurvivesRestore(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.00001, 56.00001}, {-4.0, 56.001}, {-4.0, 56.002}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:05:00Z",
+		"2024-06-12T09:06:00Z",
+		"2024-06-12T09:07:00Z",
+	}
+	id := insertTestTrack(t, r, "user_1", *f)
+
+	_, err := r.Trim(ctx, id)
+	require.NoError(t, err)
+	_, err = r.SetActivityType(ctx, id, "run")
+	require.NoError(t, err)
+
+	restored, err := r.RestoreOriginal(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, restored.Geojson.Geometry, 4)
+	assert.Equal(t, "run", restored.ActivityType)
+	assert.Equal(t, "run", restored.Geojson.Properties["activityType"])
+	assert.Equal(t, analysis.ActivityTypeFromUser, restored.Geojson.Properties["activityTypeSource"])
+}
+
+func TestSplitThenMerge(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}, {-4.0, 56.002}, {-4.0, 56.003}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:01:00Z",
+		"2024-06-12T09:02:00Z",
+		"2024-06-12T09:03:00Z",
+	}
+	id := insertTestTrack(t, r, "user_1", *f)
+
+	parts, err := r.SplitAtTime(ctx, id, time.Date(2024, 6, 12, 9, 1, 30, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	assert.Equal(t, id, parts[0].ID)
+	assert.Len(t, parts[0].Geojson.Geometry, 3)
+	assert.Len(t, parts[1].Geojson.Geometry, 2)
+	assert.Equal(t, "2024-06-12T09:02:00Z", parts[1].Time.Format(time.RFC3339))
+
+	merged, err := r.Merge(ctx, []string{parts[1].ID, parts[0].ID})
+	require.NoError(t, err)
+	assert.Len(t, merged.Geojson.Geometry, 5)
+	assert.Equal(t, "2024-06-12T09:00:00Z", merged.Time.Format(time.RFC3339))
+
+	list, err := r.ListMyTracks(ctx, "user_1", ListOptions{OrderBy: OrderByTime})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, merged.ID, list[0].ID)
+}
+
+func TestSplitAndMergeKeepOriginal(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.00001, 56.00001}, {-4.0, 56.001}, {-4.0, 56.002}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:05:00Z",
+		"2024-06-12T09:06:00Z",
+		"2024-06-12T09:07:00Z",
+	}
+	id := insertTestTrack(t, r, "user_1", *f)
+	_, err := r.Trim(ctx, id)
+	require.NoError(t, err)
+
+	// Only the start was trimmed, so only the first part can be restored
+	parts, err := r.Split(ctx, id, 1)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	assert.True(t, parts[0].Trimmed)
+	assert.False(t, parts[1].Trimmed)
+
+	merged, err := r.Merge(ctx, []string{parts[0].ID, parts[1].ID})
+	require.NoError(t, err)
+	assert.True(t, merged.Trimmed)
+
+	restored, err := r.RestoreOriginal(ctx, merged.ID)
+	require.NoError(t, err)
+	assert.Len(t, restored.Geojson.Geometry, 5)
+}
+
+func insertTestTrack(t *testing.T, r *Repo, owner string, f geojson.Feature) string {
+	t.Helper()
+	var trackTime pgtype.Timestamptz
+	if tt, ok := analysis.ParseSloppyRecentTime(f.Properties.CoordinateProperties()["times"].([]interface{})[0]); ok {
+		trackTime = pgtype.Timestamptz{Time: tt, Valid: true}
+	}
+	tID, err := r.q.InsertImportedTrack(context.Background(), db.InsertImportedTrackParams{
+		OwnerID:    &owner,
+		UploadTime: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		Time:       trackTime,
+		Geojson:    f,
+	})
+	require.NoError(t, err)
+	return ids.Marshal(trackIdPrefix, tID)
+}
+
+func TestGetMasked(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}, {-4.0, 56.002}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:01:00Z",
+		"2024-06-12T09:02:00Z",
+	}
+	id := insertTestTrack(t, r, "user_1", *f)
+
+	_, err := r.q.InsertPrivacyZone(ctx, db.InsertPrivacyZoneParams{
+		UserID:       "user_1",
+		Lng:          -4.0,
+		Lat:          56.0,
+		RadiusMeters: 50,
+	})
+	require.NoError(t, err)
+
+	masked, err := r.GetMasked(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, masked.Geojson.Geometry, 2)
+
+	unmasked, err := r.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, unmasked.Geojson.Geometry, 3)
+}
+
+func TestGetMaskedStartsInsideZone(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}, {-4.0, 56.002}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:01:00Z",
+		"2024-06-12T09:02:00Z",
+	}
+	f.Properties["startPlaceName"] = "Home"
+	f.Properties["endPlaceName"] = "Lawers"
+	owner := "user_1"
+	name := "Home to Lawers"
+	tID, err := r.q.InsertImportedTrack(ctx, db.InsertImportedTrackParams{
+		OwnerID:    &owner,
+		Name:       &name,
+		UploadTime: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		Geojson:    *f,
+	})
+	require.NoError(t, err)
+	id := ids.Marshal(trackIdPrefix, tID)
+
+	_, err = r.q.InsertPrivacyZone(ctx, db.InsertPrivacyZoneParams{
+		UserID:       owner,
+		Lng:          -4.0,
+		Lat:          56.0,
+		RadiusMeters: 50,
+	})
+	require.NoError(t, err)
+
+	masked, err := r.GetMasked(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Lawers", masked.Name)
+	assert.NotContains(t, masked.Geojson.Properties, "startPlaceName")
+	assert.Equal(t, "Lawers", masked.Geojson.Properties["endPlaceName"])
+
+	unmasked, err := r.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, name, unmasked.Name)
+}
+
+func TestBackfillEstimates(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	// Imported before estimates were stored
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.009}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{"2024-06-12T09:00:00Z", "2024-06-12T09:12:00Z"}
+	f.Properties.CoordinateProperties()["elevationMeters"] = []interface{}{100.0, 100.0}
+	id := insertTestTrack(t, r, "user_1", *f)
+
+	before, err := r.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, before.Estimates)
+
+	n, err := r.BackfillEstimates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	after, err := r.Get(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, after.Estimates)
+	assert.InDelta(t, 720, after.Estimates.NaismithSecs, 10)
+
+	n, err = r.BackfillEstimates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestToTrackLocalTimes(t *testing.T) {
+	f := geojson.NewFeature(orb.LineString{{-105.6836, 40.2549}, {-105.68, 40.26}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{"2024-06-12T14:00:00Z", "2024-06-12T18:30:00Z"}
+	tz := "America/Denver"
+
+	got := toTrack(db.Track{ID: 1, Geojson: *f, Timezone: &tz})
+	require.NotNil(t, got.LocalStartTime)
+	require.NotNil(t, got.LocalEndTime)
+	assert.Equal(t, "2024-06-12T08:00:00-06:00", got.LocalStartTime.Format(time.RFC3339))
+	assert.Equal(t, "2024-06-12T12:30:00-06:00", got.LocalEndTime.Format(time.RFC3339))
+
+	got = toTrack(db.Track{ID: 1, Geojson: *f})
+	assert.Nil(t, got.LocalStartTime)
+}
@@ -0,0 +1,79 @@
+package tracks
+
+import (
+	"context"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"strings"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+var ErrInvalidSearch = fmt.Errorf("invalid search")
+
+type SearchResult struct {
+	Track Track   `json:"track"`
+	Rank  float32 `json:"rank"`
+}
+
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	// NextOffset is nil when there are no more results
+	NextOffset *int `json:"nextOffset"`
+}
+
+// Search finds the user's tracks matching the query, best matches first.
+//
+// The query uses web search syntax (quoted phrases, "or", and "-" to
+// exclude) and is matched against the track name, description, tags, import
+// filename and the names of places along the track.
+func (r *Repo) Search(ctx context.Context, userID string, query string, limit int, offset int) (SearchPage, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return SearchPage{}, fmt.Errorf("%w: empty query", ErrInvalidSearch)
+	}
+	if limit <= 0 || limit > MaxSearchLimit {
+		return SearchPage{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearch, MaxSearchLimit)
+	}
+	if offset < 0 {
+		return SearchPage{}, fmt.Errorf("%w: negative offset", ErrInvalidSearch)
+	}
+
+	// Fetch one extra row to find out if there is another page
+	rows, err := r.q.SearchTracks(ctx, db.SearchTracksParams{
+		Query:      query,
+		OwnerID:    &userID,
+		PageLimit:  int32(limit + 1),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	var page SearchPage
+	if len(rows) > limit {
+		rows = rows[:limit]
+		next := offset + limit
+		page.NextOffset = &next
+	}
+
+	tracks := make([]Track, 0, len(rows))
+	for _, row := range rows {
+		tracks = append(tracks, toTrack(row.Track))
+	}
+	if err := r.attachTags(ctx, tracks); err != nil {
+		return SearchPage{}, err
+	}
+	if err := r.attachEstimates(ctx, tracks); err != nil {
+		return SearchPage{}, err
+	}
+
+	page.Results = make([]SearchResult, 0, len(rows))
+	for i, row := range rows {
+		page.Results = append(page.Results, SearchResult{Track: tracks[i], Rank: row.Rank})
+	}
+	return page, nil
+}
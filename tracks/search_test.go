@@ -0,0 +1,56 @@
+package tracks
+
+import (
+	"context"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	newTrack := func(owner string, desc string) string {
+		f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}})
+		f.Properties["desc"] = desc
+		f.Properties.CoordinateProperties()["times"] = []interface{}{"2024-06-12T09:00:00Z", "2024-06-12T09:00:00Z"}
+		return insertTestTrack(t, r, owner, *f)
+	}
+	lawers := newTrack("user_1", "Ben Lawers from the visitor centre")
+	ridge := newTrack("user_1", "Aonach Eagach ridge")
+	require.NoError(t, r.AddTag(ctx, "user_1", ridge, "scrambles"))
+	newTrack("user_2", "Ben Lawers again")
+
+	page, err := r.Search(ctx, "user_1", "lawers", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, lawers, page.Results[0].Track.ID)
+	assert.Nil(t, page.NextOffset)
+
+	page, err = r.Search(ctx, "user_1", "scramble", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, ridge, page.Results[0].Track.ID)
+	assert.Equal(t, []string{"scrambles"}, page.Results[0].Track.Tags)
+
+	// Removing the tag updates the index
+	require.NoError(t, r.RemoveTag(ctx, ridge, "scrambles"))
+	page, err = r.Search(ctx, "user_1", "scramble", 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, page.Results)
+
+	page, err = r.Search(ctx, "user_1", "ben or ridge", 1, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Results, 1)
+	require.NotNil(t, page.NextOffset)
+	page, err = r.Search(ctx, "user_1", "ben or ridge", 1, *page.NextOffset)
+	require.NoError(t, err)
+	require.Len(t, page.Results, 1)
+	assert.Nil(t, page.NextOffset)
+
+	_, err = r.Search(ctx, "user_1", "  ", 10, 0)
+	assert.ErrorIs(t, err, ErrInvalidSearch)
+}
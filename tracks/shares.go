@@ -0,0 +1,123 @@
+package tracks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"time"
+)
+
+const shareTokenBytes = 24
+
+var ErrShareNotFound = fmt.Errorf("share not found")
+
+// Share is a link the owner of a track has created to show it to people
+// without an account.
+type Share struct {
+	Token     string     `json:"token"`
+	TrackID   string     `json:"trackID"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// CreateShare creates an unguessable token giving access to the track. If
+// expiresAt is nil the share lasts until revoked.
+func (r *Repo) CreateShare(ctx context.Context, ownerID string, trackID string, expiresAt *time.Time) (Share, error) {
+	tID, err := ids.Unmarshal(trackIdPrefix, trackID)
+	if err != nil {
+		return Share{}, err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return Share{}, err
+	}
+
+	var expires pgtype.Timestamptz
+	if expiresAt != nil {
+		expires = pgtype.Timestamptz{Time: *expiresAt, Valid: true}
+	}
+
+	share, err := r.q.InsertTrackShare(ctx, db.InsertTrackShareParams{
+		Token:     token,
+		TrackID:   tID,
+		OwnerID:   ownerID,
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		return Share{}, err
+	}
+	return toShare(share), nil
+}
+
+func (r *Repo) ListMyActiveShares(ctx context.Context, ownerID string) ([]Share, error) {
+	shares, err := r.q.ListMyActiveTrackShares(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Share, 0, len(shares))
+	for _, s := range shares {
+		out = append(out, toShare(s))
+	}
+	return out, nil
+}
+
+func (r *Repo) RevokeShare(ctx context.Context, ownerID string, token string) error {
+	n, err := r.q.RevokeTrackShare(ctx, db.RevokeTrackShareParams{Token: token, OwnerID: ownerID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// GetShared gets the track a share gives access to, sanitised for viewing by
+// anyone.
+func (r *Repo) GetShared(ctx context.Context, token string) (Track, error) {
+	share, err := r.q.GetActiveTrackShare(ctx, token)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Track{}, ErrShareNotFound
+		}
+		return Track{}, err
+	}
+
+	track, err := r.GetMasked(ctx, ids.Marshal(trackIdPrefix, share.TrackID))
+	if err != nil {
+		if errors.Is(err, ErrTrackNotFound) {
+			return Track{}, ErrShareNotFound
+		}
+		return Track{}, err
+	}
+
+	track.OwnerID = ""
+	track.Trimmed = false
+	track.Tags = nil
+	track.SuggestedTags = nil
+	return track, nil
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func toShare(data db.TrackShare) Share {
+	return Share{
+		Token:     data.Token,
+		TrackID:   ids.Marshal(trackIdPrefix, data.TrackID),
+		CreatedAt: data.CreatedAt.Time,
+		ExpiresAt: pgTimestamptzToNullable(data.ExpiresAt),
+	}
+}
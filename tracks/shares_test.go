@@ -0,0 +1,56 @@
+package tracks
+
+import (
+	"context"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestShareLifecycle(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{"2024-06-12T09:00:00Z", "2024-06-12T09:01:00Z"}
+	id := insertTestTrack(t, r, "user_1", *f)
+
+	share, err := r.CreateShare(ctx, "user_1", id, nil)
+	require.NoError(t, err)
+	assert.Equal(t, id, share.TrackID)
+	assert.Len(t, share.Token, 32)
+
+	got, err := r.GetShared(ctx, share.Token)
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Empty(t, got.OwnerID)
+
+	active, err := r.ListMyActiveShares(ctx, "user_1")
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+
+	require.ErrorIs(t, r.RevokeShare(ctx, "user_2", share.Token), ErrShareNotFound)
+	require.NoError(t, r.RevokeShare(ctx, "user_1", share.Token))
+
+	_, err = r.GetShared(ctx, share.Token)
+	require.ErrorIs(t, err, ErrShareNotFound)
+}
+
+func TestShareExpiry(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{"2024-06-12T09:00:00Z", "2024-06-12T09:01:00Z"}
+	id := insertTestTrack(t, r, "user_1", *f)
+
+	expired := time.Now().Add(-time.Hour)
+	share, err := r.CreateShare(ctx, "user_1", id, &expired)
+	require.NoError(t, err)
+
+	_, err = r.GetShared(ctx, share.Token)
+	require.ErrorIs(t, err, ErrShareNotFound)
+}
@@ -0,0 +1,180 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"github.com/dzfranklin/plantopo-api/analysis"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+	"math"
+	"time"
+)
+
+const peakIdPrefix = "pk"
+
+type Peak struct {
+	ID              string    `json:"id"`
+	Dataset         string    `json:"dataset"`
+	Name            string    `json:"name"`
+	Point           orb.Point `json:"point"`
+	ElevationMeters *float64  `json:"elevationMeters"`
+}
+
+type Summit struct {
+	Peak      Peak      `json:"peak"`
+	VisitedAt time.Time `json:"visitedAt"`
+}
+
+// BaggedPeak is a peak the user has visited on any of their tracks.
+type BaggedPeak struct {
+	Peak            Peak      `json:"peak"`
+	FirstVisitedAt  time.Time `json:"firstVisitedAt"`
+	LatestVisitedAt time.Time `json:"latestVisitedAt"`
+	Visits          int       `json:"visits"`
+}
+
+// ListSummits lists the peaks visited by the track in the order they were
+// reached.
+func (r *Repo) ListSummits(ctx context.Context, trackID string) ([]Summit, error) {
+	tID, err := ids.Unmarshal(trackIdPrefix, trackID)
+	if err != nil {
+		return nil, ErrTrackNotFound
+	}
+	rows, err := r.q.ListTrackSummits(ctx, tID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Summit, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, Summit{Peak: toPeak(row.Peak), VisitedAt: row.VisitedAt.Time})
+	}
+	return out, nil
+}
+
+// ListMyBaggedPeaks lists the peaks visited on any of the user's tracks,
+// optionally only those in a dataset such as the Munros.
+func (r *Repo) ListMyBaggedPeaks(ctx context.Context, userID string, dataset string) ([]BaggedPeak, error) {
+	params := db.ListBaggedPeaksParams{OwnerID: &userID}
+	if dataset != "" {
+		params.Dataset = &dataset
+	}
+	rows, err := r.q.ListBaggedPeaks(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BaggedPeak, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, BaggedPeak{
+			Peak:            toPeak(row.Peak),
+			FirstVisitedAt:  row.FirstVisitedAt.Time,
+			LatestVisitedAt: row.LatestVisitedAt.Time,
+			Visits:          int(row.Visits),
+		})
+	}
+	return out, nil
+}
+
+// RefreshAllSummits redetects the summits of every track, for example after
+// a peaks dataset is loaded. It returns the number of tracks processed.
+func (r *Repo) RefreshAllSummits(ctx context.Context) (int, error) {
+	var after int64
+	var total int
+	for {
+		trackIDs, err := r.q.ListTrackIDsAfter(ctx, db.ListTrackIDsAfterParams{
+			ID:    after,
+			Limit: backfillBatchSize,
+		})
+		if err != nil {
+			return total, err
+		}
+		if len(trackIDs) == 0 {
+			return total, nil
+		}
+		for _, tID := range trackIDs {
+			if err := refreshSummits(ctx, r.q, tID); err != nil {
+				return total, err
+			}
+			total++
+		}
+		after = trackIDs[len(trackIDs)-1]
+	}
+}
+
+// refreshSummits redetects the summits visited by the track, which must be
+// called whenever its geometry changes.
+func refreshSummits(ctx context.Context, q *db.Queries, trackID int64) error {
+	track, err := q.GetTrack(ctx, trackID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTrackNotFound
+		}
+		return err
+	}
+
+	if err := q.DeleteTrackSummits(ctx, trackID); err != nil {
+		return err
+	}
+
+	line, ok := track.Geojson.Geometry.(orb.LineString)
+	if !ok || len(line) == 0 {
+		return nil
+	}
+	bound := line.Bound()
+	padLat := analysis.SummitMaxDistanceMeters / analysis.MetersPerDegreeLat
+	// Degrees of longitude are narrowest at the latitude furthest from the
+	// equator, which needs the most padding
+	maxAbsLat := math.Max(math.Abs(bound.Min.Lat()), math.Abs(bound.Max.Lat()))
+	padLng := padLat / math.Max(math.Cos(maxAbsLat*math.Pi/180), 0.01)
+	peaks, err := q.ListPeaksInBounds(ctx, db.ListPeaksInBoundsParams{
+		MinLat: bound.Min.Lat() - padLat,
+		MaxLat: bound.Max.Lat() + padLat,
+		MinLng: bound.Min.Lon() - padLng,
+		MaxLng: bound.Max.Lon() + padLng,
+	})
+	if err != nil {
+		return err
+	}
+	if len(peaks) == 0 {
+		return nil
+	}
+
+	candidates := make([]analysis.SummitCandidate, 0, len(peaks))
+	for _, p := range peaks {
+		candidates = append(candidates, analysis.SummitCandidate{
+			ID:              p.ID,
+			Point:           orb.Point{p.Lng, p.Lat},
+			ElevationMeters: p.ElevationMeters,
+		})
+	}
+
+	for _, visit := range analysis.DetectSummits(track.Geojson, candidates) {
+		visitedAt := track.Time
+		if !visit.Time.IsZero() {
+			visitedAt = pgtype.Timestamptz{Time: visit.Time, Valid: true}
+		} else if !visitedAt.Valid {
+			visitedAt = track.UploadTime
+		}
+		err := q.InsertTrackSummit(ctx, db.InsertTrackSummitParams{
+			TrackID:   trackID,
+			PeakID:    visit.ID,
+			VisitedAt: visitedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toPeak(p db.Peak) Peak {
+	return Peak{
+		ID:              ids.Marshal(peakIdPrefix, p.ID),
+		Dataset:         p.Dataset,
+		Name:            p.Name,
+		Point:           orb.Point{p.Lng, p.Lat},
+		ElevationMeters: p.ElevationMeters,
+	}
+}
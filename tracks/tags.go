@@ -0,0 +1,175 @@
+package tracks
+
+import (
+	"context"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/paulmach/orb/geojson"
+	"slices"
+	"strings"
+)
+
+const maxTagLength = 64
+
+var ErrInvalidTag = fmt.Errorf("invalid tag")
+var ErrTagNotFound = fmt.Errorf("tag not found")
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag trims and lowercases a tag so that equivalent tags match.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || len(tag) > maxTagLength {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+func (r *Repo) AddTag(ctx context.Context, ownerID string, trackID string, tag string) error {
+	tID, err := ids.Unmarshal(trackIdPrefix, trackID)
+	if err != nil {
+		return ErrTrackNotFound
+	}
+	tag, err = NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	return r.q.AddTrackTag(ctx, db.AddTrackTagParams{TrackID: tID, OwnerID: ownerID, Tag: tag})
+}
+
+func (r *Repo) RemoveTag(ctx context.Context, trackID string, tag string) error {
+	tID, err := ids.Unmarshal(trackIdPrefix, trackID)
+	if err != nil {
+		return ErrTrackNotFound
+	}
+	tag, err = NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	n, err := r.q.RemoveTrackTag(ctx, db.RemoveTrackTagParams{TrackID: tID, Tag: tag})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// RenameTag renames a tag across all the user's tracks, merging it into the
+// new tag where a track already has both.
+func (r *Repo) RenameTag(ctx context.Context, ownerID string, from string, to string) error {
+	from, err := NormalizeTag(from)
+	if err != nil {
+		return err
+	}
+	to, err = NormalizeTag(to)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	err = q.CopyTag(ctx, db.CopyTagParams{ToTag: to, OwnerID: ownerID, FromTag: from})
+	if err != nil {
+		return err
+	}
+
+	n, err := q.DeleteTag(ctx, db.DeleteTagParams{OwnerID: ownerID, Tag: from})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTagNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *Repo) ListMyTags(ctx context.Context, ownerID string) ([]TagCount, error) {
+	rows, err := r.q.ListMyTagCounts(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TagCount, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, TagCount{Tag: row.Tag, Count: int(row.Count)})
+	}
+	return out, nil
+}
+
+// attachTags loads the tags of the tracks and drops any suggestions that
+// have already been applied.
+func (r *Repo) attachTags(ctx context.Context, tracks []Track) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	tIDs := make([]int64, 0, len(tracks))
+	byID := make(map[int64]*Track, len(tracks))
+	for i := range tracks {
+		tID, err := ids.Unmarshal(trackIdPrefix, tracks[i].ID)
+		if err != nil {
+			return err
+		}
+		tIDs = append(tIDs, tID)
+		byID[tID] = &tracks[i]
+		tracks[i].Tags = []string{}
+	}
+
+	rows, err := r.q.ListTagsForTracks(ctx, tIDs)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if t, ok := byID[row.TrackID]; ok {
+			t.Tags = append(t.Tags, row.Tag)
+		}
+	}
+
+	for i := range tracks {
+		t := &tracks[i]
+		t.SuggestedTags = slices.DeleteFunc(t.SuggestedTags, func(tag string) bool {
+			return slices.Contains(t.Tags, tag)
+		})
+	}
+	return nil
+}
+
+// suggestTags suggests tags from the metadata of the imported file, such as
+// the GPX type element.
+func suggestTags(f geojson.Feature) []string {
+	var out []string
+	if v, ok := f.Properties["type"].(string); ok {
+		if tag, err := NormalizeTag(v); err == nil {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package tracks
+
+import (
+	"context"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	got, err := NormalizeTag("  Munros ")
+	require.NoError(t, err)
+	assert.Equal(t, "munros", got)
+
+	_, err = NormalizeTag("   ")
+	assert.ErrorIs(t, err, ErrInvalidTag)
+}
+
+func TestSuggestTags(t *testing.T) {
+	f := geojson.NewFeature(orb.LineString{{0, 0}, {1, 1}})
+	f.Properties["type"] = "Hiking"
+	assert.Equal(t, []string{"hiking"}, suggestTags(*f))
+
+	assert.Empty(t, suggestTags(*geojson.NewFeature(orb.LineString{{0, 0}, {1, 1}})))
+}
+
+func TestTagFiltersAndRename(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	newTrack := func(ts string, tags ...string) string {
+		f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}})
+		f.Properties.CoordinateProperties()["times"] = []interface{}{ts, ts}
+		id := insertTestTrack(t, r, "user_1", *f)
+		for _, tag := range tags {
+			require.NoError(t, r.AddTag(ctx, "user_1", id, tag))
+		}
+		return id
+	}
+	both := newTrack("2024-06-12T09:00:00Z", "scotland", "munro")
+	scotland := newTrack("2024-06-11T09:00:00Z", "Scotland")
+	newTrack("2024-06-10T09:00:00Z")
+
+	all, err := r.ListMyTracks(ctx, "user_1", ListOptions{
+		OrderBy:      OrderByTime,
+		Tags:         []string{"scotland", "munro"},
+		MatchAllTags: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, both, all[0].ID)
+	assert.Equal(t, []string{"munro", "scotland"}, all[0].Tags)
+
+	anyTag, err := r.ListMyTracks(ctx, "user_1", ListOptions{
+		OrderBy: OrderByTime,
+		Tags:    []string{"scotland", "munro"},
+	})
+	require.NoError(t, err)
+	require.Len(t, anyTag, 2)
+	assert.Equal(t, scotland, anyTag[1].ID)
+
+	unfiltered, err := r.ListMyTracks(ctx, "user_1", ListOptions{OrderBy: OrderByTime})
+	require.NoError(t, err)
+	require.Len(t, unfiltered, 3)
+
+	require.NoError(t, r.RenameTag(ctx, "user_1", "scotland", "munro"))
+	counts, err := r.ListMyTags(ctx, "user_1")
+	require.NoError(t, err)
+	assert.Equal(t, []TagCount{{Tag: "munro", Count: 2}}, counts)
+}
+
+func TestSplitAndMergeKeepTags(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	f := geojson.NewFeature(orb.LineString{{-4.0, 56.0}, {-4.0, 56.001}, {-4.0, 56.002}, {-4.0, 56.003}})
+	f.Properties.CoordinateProperties()["times"] = []interface{}{
+		"2024-06-12T09:00:00Z",
+		"2024-06-12T09:01:00Z",
+		"2024-06-12T09:02:00Z",
+		"2024-06-12T09:03:00Z",
+	}
+	id := insertTestTrack(t, r, "user_1", *f)
+	require.NoError(t, r.AddTag(ctx, "user_1", id, "munros"))
+	require.NoError(t, r.AddTag(ctx, "user_1", id, "winter"))
+
+	parts, err := r.SplitAtTime(ctx, id, time.Date(2024, 6, 12, 9, 1, 30, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	for _, part := range parts {
+		got, err := r.Get(ctx, part.ID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"munros", "winter"}, got.Tags)
+	}
+
+	require.NoError(t, r.AddTag(ctx, "user_1", parts[1].ID, "scrambling"))
+	merged, err := r.Merge(ctx, []string{parts[0].ID, parts[1].ID})
+	require.NoError(t, err)
+	got, err := r.Get(ctx, merged.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"munros", "scrambling", "winter"}, got.Tags)
+}
@@ -0,0 +1,435 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxUploadChunkSize is the most that can be appended to an upload at
+	// once
+	MaxUploadChunkSize = 8 * 1024 * 1024
+	// Every chunk but the last must be at least this large, so that an
+	// upload can't be split into millions of blobs
+	minUploadChunkSize = 1024 * 1024
+
+	// A user can only have this many uploads in progress, adding up to at
+	// most maxOpenUploadBytes
+	maxOpenUploads     = 5
+	maxOpenUploadBytes = 2 * MaxUploadedArchiveSize
+
+	uploadIdPrefix = "tu"
+	// Uploads that aren't finished by then are discarded
+	uploadExpiry = 24 * time.Hour
+	// Expired uploads are deleted in batches of this size
+	uploadCleanupBatchSize = 100
+)
+
+var ErrUploadNotFound = fmt.Errorf("upload not found")
+var ErrInvalidUpload = fmt.Errorf("invalid upload")
+var ErrTooManyUploads = fmt.Errorf("too many uploads in progress")
+
+// UploadOffsetError is returned when a chunk doesn't start where the upload
+// left off, for example because an earlier append was lost. The client
+// should resume from Offset.
+type UploadOffsetError struct {
+	Offset int64
+}
+
+func (e UploadOffsetError) Error() string {
+	return fmt.Sprintf("upload is at offset %d", e.Offset)
+}
+
+// Upload is a file being uploaded in chunks, so that a large file can be
+// resumed after a dropped connection.
+type Upload struct {
+	ID       string `json:"id"`
+	OwnerID  string `json:"ownerID"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	// Offset is how much has been received
+	Offset    int64     `json:"offset"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CreateUpload starts a chunked upload of a file of the given size. Archives
+// can be up to MaxUploadedArchiveSize, larger than a direct import allows.
+func (r *Repo) CreateUpload(ctx context.Context, ownerID string, filename string, size int64) (Upload, error) {
+	if filename == "" {
+		return Upload{}, fmt.Errorf("%w: missing filename", ErrInvalidUpload)
+	}
+	if size <= 0 {
+		return Upload{}, fmt.Errorf("%w: size must be positive", ErrInvalidUpload)
+	}
+	maxSize := int64(maxImportSize)
+	if IsArchive(filename) {
+		maxSize = MaxUploadedArchiveSize
+	}
+	if size > maxSize {
+		return Upload{}, ErrImportTooLarge
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Upload{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	// Locked so that concurrent creates can't both fit under the limits
+	if err := q.LockTrackUploadsOfOwner(ctx, ownerID); err != nil {
+		return Upload{}, err
+	}
+	usage, err := q.GetOpenTrackUploadUsage(ctx, ownerID)
+	if err != nil {
+		return Upload{}, err
+	}
+	if usage.Count >= maxOpenUploads {
+		return Upload{}, fmt.Errorf("%w: at most %d uploads can be in progress", ErrTooManyUploads, maxOpenUploads)
+	}
+	if usage.Size+size > maxOpenUploadBytes {
+		return Upload{}, fmt.Errorf("%w: uploads in progress would be too large", ErrTooManyUploads)
+	}
+
+	row, err := q.InsertTrackUpload(ctx, db.InsertTrackUploadParams{
+		OwnerID:   ownerID,
+		Filename:  filename,
+		Size:      size,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(uploadExpiry), Valid: true},
+	})
+	if err != nil {
+		return Upload{}, err
+	}
+	return toUpload(row), tx.Commit(ctx)
+}
+
+func (r *Repo) GetUpload(ctx context.Context, id string) (Upload, error) {
+	uploadID, err := ids.Unmarshal(uploadIdPrefix, id)
+	if err != nil {
+		return Upload{}, ErrUploadNotFound
+	}
+	row, err := r.q.GetTrackUpload(ctx, uploadID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Upload{}, ErrUploadNotFound
+		}
+		return Upload{}, err
+	}
+	return toUpload(row), nil
+}
+
+// AppendUpload adds a chunk starting at offset, which must be the offset the
+// upload is at. The chunk is kept in the blob store until the upload is
+// deleted.
+func (r *Repo) AppendUpload(ctx context.Context, id string, offset int64, chunk []byte) (Upload, error) {
+	uploadID, err := ids.Unmarshal(uploadIdPrefix, id)
+	if err != nil {
+		return Upload{}, ErrUploadNotFound
+	}
+	if len(chunk) == 0 {
+		return Upload{}, fmt.Errorf("%w: empty chunk", ErrInvalidUpload)
+	}
+	if len(chunk) > MaxUploadChunkSize {
+		return Upload{}, fmt.Errorf("%w: chunk too large", ErrInvalidUpload)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Upload{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	// Locked so that concurrent appends at the same offset can't both succeed
+	row, err := q.GetTrackUploadForUpdate(ctx, uploadID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Upload{}, ErrUploadNotFound
+		}
+		return Upload{}, err
+	}
+	if offset != row.Received {
+		return Upload{}, UploadOffsetError{Offset: row.Received}
+	}
+	received := row.Received + int64(len(chunk))
+	if received > row.Size {
+		return Upload{}, fmt.Errorf("%w: chunk extends past the end of the file", ErrInvalidUpload)
+	}
+	if len(chunk) < minUploadChunkSize && received != row.Size {
+		return Upload{}, fmt.Errorf("%w: only the last chunk can be smaller than %d bytes", ErrInvalidUpload, minUploadChunkSize)
+	}
+
+	// Written while the row is locked, so a blob left behind by a failed
+	// commit is overwritten by the retry at the same offset
+	if err := r.blobs.Put(ctx, uploadChunkBlobKey(uploadID, offset), chunk); err != nil {
+		return Upload{}, fmt.Errorf("put upload chunk: %w", err)
+	}
+	err = q.InsertTrackUploadChunk(ctx, db.InsertTrackUploadChunkParams{
+		UploadID:    uploadID,
+		StartOffset: offset,
+		Size:        int64(len(chunk)),
+	})
+	if err != nil {
+		return Upload{}, err
+	}
+	row, err = q.SetTrackUploadReceived(ctx, db.SetTrackUploadReceivedParams{
+		ID:       uploadID,
+		Received: received,
+	})
+	if err != nil {
+		return Upload{}, err
+	}
+
+	return toUpload(row), tx.Commit(ctx)
+}
+
+// ImportUpload imports a finished upload like ImportArchive or Import,
+// depending on the kind of file. If it was an archive the ID is that of the
+// batch, which takes over the upload and deletes it once unpacked. Until
+// then finishing the upload again gives the same batch. Otherwise the upload
+// is left for the caller to delete.
+func (r *Repo) ImportUpload(ctx context.Context, id string, opts ImportOptions) (string, *ImportBatch, error) {
+	upload, err := r.GetUpload(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if upload.Offset != upload.Size {
+		return "", nil, fmt.Errorf("%w: only %d of %d bytes received", ErrInvalidUpload, upload.Offset, upload.Size)
+	}
+	uploadID, err := ids.Unmarshal(uploadIdPrefix, id)
+	if err != nil {
+		return "", nil, ErrUploadNotFound
+	}
+	ra, err := r.openUpload(ctx, uploadID, upload.Size)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if IsArchive(upload.Filename) {
+		if upload.Size > MaxUploadedArchiveSize {
+			return "", nil, ErrImportTooLarge
+		}
+		if _, err := openArchive(ra, upload.Size); err != nil {
+			return "", nil, err
+		}
+		batch, err := r.queueBatch(ctx, db.InsertTrackImportBatchParams{
+			OwnerID:        upload.OwnerID,
+			Filename:       upload.Filename,
+			UploadID:       &uploadID,
+			ByteSize:       upload.Size,
+			SkipDuplicates: opts.SkipDuplicates,
+		}, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		return batch.ID, &batch, nil
+	}
+
+	if upload.Size > maxImportSize {
+		return "", nil, ErrImportTooLarge
+	}
+	data := make([]byte, upload.Size)
+	if _, err := ra.ReadAt(data, 0); err != nil {
+		return "", nil, err
+	}
+	importID, err := r.Import(ctx, upload.OwnerID, upload.Filename, data, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	return importID, nil, nil
+}
+
+// openUpload reads the chunks of an upload back from the blob store as they
+// are needed, so that a large archive is never held in memory.
+func (r *Repo) openUpload(ctx context.Context, uploadID int64, size int64) (*uploadReader, error) {
+	chunks, err := r.q.ListTrackUploadChunks(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, chunk := range chunks {
+		if chunk.StartOffset != total {
+			return nil, fmt.Errorf("upload %d is missing bytes at %d", uploadID, total)
+		}
+		total += chunk.Size
+	}
+	if total != size {
+		return nil, fmt.Errorf("upload %d has %d bytes of chunks, expected %d", uploadID, total, size)
+	}
+	return &uploadReader{ctx: ctx, store: r.blobs, chunks: chunks, cached: -1}, nil
+}
+
+// uploadReader is an io.ReaderAt over the chunks of an upload. Archives are
+// mostly read in order, so only the last chunk read is kept.
+type uploadReader struct {
+	ctx        context.Context
+	store      BlobStore
+	chunks     []db.TrackUploadChunk
+	cached     int
+	cachedData []byte
+}
+
+func (u *uploadReader) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for n < len(p) {
+		pos := off + int64(n)
+		i := sort.Search(len(u.chunks), func(i int) bool {
+			return u.chunks[i].StartOffset+u.chunks[i].Size > pos
+		})
+		if i == len(u.chunks) {
+			return n, io.EOF
+		}
+		data, err := u.chunk(i)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[pos-u.chunks[i].StartOffset:])
+	}
+	return n, nil
+}
+
+func (u *uploadReader) chunk(i int) ([]byte, error) {
+	if u.cached == i {
+		return u.cachedData, nil
+	}
+	chunk := u.chunks[i]
+	data, err := u.store.Get(u.ctx, uploadChunkBlobKey(chunk.UploadID, chunk.StartOffset))
+	if err != nil {
+		return nil, fmt.Errorf("get upload chunk: %w", err)
+	}
+	if int64(len(data)) != chunk.Size {
+		return nil, fmt.Errorf("upload chunk at %d has %d bytes, expected %d", chunk.StartOffset, len(data), chunk.Size)
+	}
+	u.cached = i
+	u.cachedData = data
+	return data, nil
+}
+
+// DeleteUpload discards an upload once it has been imported or abandoned.
+func (r *Repo) DeleteUpload(ctx context.Context, id string) error {
+	uploadID, err := ids.Unmarshal(uploadIdPrefix, id)
+	if err != nil {
+		return ErrUploadNotFound
+	}
+	return deleteUpload(ctx, r.pool, r.blobs, uploadID)
+}
+
+// deleteUpload deletes an upload along with the blobs of its chunks.
+func deleteUpload(ctx context.Context, pool *pgxpool.Pool, store BlobStore, uploadID int64) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := db.New(tx)
+
+	// Locked so that a concurrent append can't add a chunk that is missed
+	if err := q.LockTrackUpload(ctx, uploadID); err != nil {
+		return err
+	}
+	chunks, err := q.ListTrackUploadChunks(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := q.DeleteTrackUpload(ctx, uploadID); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// A failure only leaves an orphaned blob behind
+	for _, chunk := range chunks {
+		key := uploadChunkBlobKey(uploadID, chunk.StartOffset)
+		if err := store.Delete(ctx, key); err != nil {
+			slog.Warn("delete upload chunk", "key", key, "error", err)
+		}
+	}
+	return nil
+}
+
+func uploadChunkBlobKey(uploadID int64, offset int64) string {
+	return fmt.Sprintf("track-uploads/%d/%d", uploadID, offset)
+}
+
+// IsArchive reports whether the file should be imported with ImportArchive.
+func IsArchive(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".zip")
+}
+
+func toUpload(row db.TrackUpload) Upload {
+	return Upload{
+		ID:        ids.Marshal(uploadIdPrefix, row.ID),
+		OwnerID:   row.OwnerID,
+		Filename:  row.Filename,
+		Size:      row.Size,
+		Offset:    row.Received,
+		ExpiresAt: row.ExpiresAt.Time,
+	}
+}
+
+type UploadCleanupArgs struct{}
+
+func (UploadCleanupArgs) Kind() string { return "tracks_upload_cleanup" }
+
+// UploadCleanupWorker deletes expired uploads, apart from those of batches
+// still being unpacked.
+type UploadCleanupWorker struct {
+	db    *pgxpool.Pool
+	blobs BlobStore
+	river.WorkerDefaults[UploadCleanupArgs]
+}
+
+func AddUploadCleanupWorker(workers *river.Workers, db *pgxpool.Pool, blobs BlobStore) {
+	river.AddWorker[UploadCleanupArgs](workers, &UploadCleanupWorker{db: db, blobs: blobs})
+}
+
+// UploadCleanupJob runs the cleanup periodically.
+func UploadCleanupJob() *river.PeriodicJob {
+	return river.NewPeriodicJob(
+		river.PeriodicInterval(time.Hour),
+		func() (river.JobArgs, *river.InsertOpts) {
+			return UploadCleanupArgs{}, nil
+		},
+		&river.PeriodicJobOpts{RunOnStart: true},
+	)
+}
+
+func (w *UploadCleanupWorker) Work(ctx context.Context, job *river.Job[UploadCleanupArgs]) error {
+	n, err := deleteExpiredUploads(ctx, w.db, w.blobs)
+	if n > 0 {
+		slog.Info("deleted expired uploads", "count", n)
+	}
+	return err
+}
+
+func deleteExpiredUploads(ctx context.Context, pool *pgxpool.Pool, store BlobStore) (int, error) {
+	var deleted int
+	for {
+		uploadIDs, err := db.New(pool).ListExpiredTrackUploadIDs(ctx, uploadCleanupBatchSize)
+		if err != nil {
+			return deleted, err
+		}
+		if len(uploadIDs) == 0 {
+			return deleted, nil
+		}
+		for _, uploadID := range uploadIDs {
+			if err := deleteUpload(ctx, pool, store, uploadID); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+}
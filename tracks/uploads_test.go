@@ -0,0 +1,196 @@
+package tracks
+
+import (
+	"context"
+	"github.com/dzfranklin/plantopo-api/blobs"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestChunkedUpload(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+	data := make([]byte, minUploadChunkSize+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	upload, err := r.CreateUpload(ctx, "user_1", "file.gpx", int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), upload.Offset)
+	uploadID, err := ids.Unmarshal(uploadIdPrefix, upload.ID)
+	require.NoError(t, err)
+
+	_, _, err = r.ImportUpload(ctx, upload.ID, ImportOptions{})
+	assert.ErrorIs(t, err, ErrInvalidUpload)
+
+	// Only the last chunk can be small
+	_, err = r.AppendUpload(ctx, upload.ID, 0, data[:100])
+	assert.ErrorIs(t, err, ErrInvalidUpload)
+
+	upload, err = r.AppendUpload(ctx, upload.ID, 0, data[:minUploadChunkSize])
+	require.NoError(t, err)
+	assert.Equal(t, int64(minUploadChunkSize), upload.Offset)
+
+	// Resending a chunk that was already received
+	_, err = r.AppendUpload(ctx, upload.ID, 0, data[:minUploadChunkSize])
+	var offsetErr UploadOffsetError
+	require.ErrorAs(t, err, &offsetErr)
+	assert.Equal(t, int64(minUploadChunkSize), offsetErr.Offset)
+
+	_, err = r.AppendUpload(ctx, upload.ID, minUploadChunkSize, append(data[minUploadChunkSize:], 'x'))
+	assert.ErrorIs(t, err, ErrInvalidUpload)
+
+	upload, err = r.AppendUpload(ctx, upload.ID, minUploadChunkSize, data[minUploadChunkSize:])
+	require.NoError(t, err)
+	assert.Equal(t, upload.Size, upload.Offset)
+
+	ra, err := r.openUpload(ctx, uploadID, upload.Size)
+	require.NoError(t, err)
+	got, err := io.ReadAll(io.NewSectionReader(ra, 0, upload.Size))
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	_, err = r.blobs.Get(ctx, uploadChunkBlobKey(uploadID, 0))
+	require.NoError(t, err)
+
+	require.NoError(t, r.DeleteUpload(ctx, upload.ID))
+	_, err = r.GetUpload(ctx, upload.ID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	_, err = r.blobs.Get(ctx, uploadChunkBlobKey(uploadID, 0))
+	assert.ErrorIs(t, err, blobs.ErrNotFound)
+}
+
+func TestImportUpload(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	data := sampleGPX()
+	upload, err := r.CreateUpload(ctx, "user_1", "file.gpx", int64(len(data)))
+	require.NoError(t, err)
+	_, err = r.AppendUpload(ctx, upload.ID, 0, data)
+	require.NoError(t, err)
+
+	id, batch, err := r.ImportUpload(ctx, upload.ID, ImportOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, batch)
+	_, err = r.ImportStatus(ctx, id)
+	require.NoError(t, err)
+
+	data = makeZip(t, map[string][]byte{
+		"other.gpx": append(sampleGPX(), '\n'),
+	})
+	upload, err = r.CreateUpload(ctx, "user_1", "export.zip", int64(len(data)))
+	require.NoError(t, err)
+	_, err = r.AppendUpload(ctx, upload.ID, 0, data)
+	require.NoError(t, err)
+
+	id, batch, err = r.ImportUpload(ctx, upload.ID, ImportOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, batch)
+	assert.Equal(t, batch.ID, id)
+	assert.Nil(t, batch.UnpackedAt)
+
+	// Finishing again gives the same batch
+	again, _, err := r.ImportUpload(ctx, upload.ID, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	// The upload isn't cleaned up while its batch is unpacked
+	uploadID, err := ids.Unmarshal(uploadIdPrefix, upload.ID)
+	require.NoError(t, err)
+	_, err = r.pool.Exec(ctx, "UPDATE track_uploads SET expires_at = NOW() WHERE id = $1", uploadID)
+	require.NoError(t, err)
+	expired, err := r.q.ListExpiredTrackUploadIDs(ctx, uploadCleanupBatchSize)
+	require.NoError(t, err)
+	assert.NotContains(t, expired, uploadID)
+
+	require.NoError(t, unpackTestBatch(t, r, id))
+	got, err := r.GetImportBatch(ctx, id)
+	require.NoError(t, err)
+	assert.NotNil(t, got.UnpackedAt)
+	assert.Equal(t, 1, got.Progress.Total)
+
+	// The upload is deleted once unpacked
+	chunks, err := r.q.ListTrackUploadChunks(ctx, uploadID)
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+	_, err = r.blobs.Get(ctx, uploadChunkBlobKey(uploadID, 0))
+	assert.ErrorIs(t, err, blobs.ErrNotFound)
+}
+
+func TestUploadReaderAcrossChunks(t *testing.T) {
+	ctx := context.Background()
+	store := newTestBlobStore(t)
+	parts := [][]byte{[]byte("abc"), []byte("defg"), []byte("h")}
+
+	ra := &uploadReader{ctx: ctx, store: store, cached: -1}
+	var offset int64
+	for _, part := range parts {
+		require.NoError(t, store.Put(ctx, uploadChunkBlobKey(1, offset), part))
+		ra.chunks = append(ra.chunks, db.TrackUploadChunk{UploadID: 1, StartOffset: offset, Size: int64(len(part))})
+		offset += int64(len(part))
+	}
+
+	got := make([]byte, 5)
+	n, err := ra.ReadAt(got, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "cdefg", string(got))
+
+	n, err = ra.ReadAt(got, 6)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "gh", string(got[:n]))
+}
+
+func TestCreateUploadLimits(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	_, err := r.CreateUpload(ctx, "user_1", "file.gpx", maxImportSize+1)
+	assert.ErrorIs(t, err, ErrImportTooLarge)
+
+	// Larger than a direct import allows
+	_, err = r.CreateUpload(ctx, "user_1", "export.zip", MaxArchiveSize+1)
+	assert.NoError(t, err)
+
+	_, err = r.CreateUpload(ctx, "user_1", "export.zip", MaxUploadedArchiveSize+1)
+	assert.ErrorIs(t, err, ErrImportTooLarge)
+
+	_, err = r.CreateUpload(ctx, "user_1", "file.gpx", 0)
+	assert.ErrorIs(t, err, ErrInvalidUpload)
+}
+
+func TestCreateUploadLimitsPerUser(t *testing.T) {
+	ctx := context.Background()
+	r := newSubject(t)
+
+	for i := 0; i < maxOpenUploads; i++ {
+		_, err := r.CreateUpload(ctx, "user_1", "file.gpx", 1)
+		require.NoError(t, err)
+	}
+	_, err := r.CreateUpload(ctx, "user_1", "file.gpx", 1)
+	assert.ErrorIs(t, err, ErrTooManyUploads)
+
+	for i := 0; i < maxOpenUploadBytes/MaxUploadedArchiveSize; i++ {
+		_, err := r.CreateUpload(ctx, "user_2", "export.zip", MaxUploadedArchiveSize)
+		require.NoError(t, err)
+	}
+	_, err = r.CreateUpload(ctx, "user_2", "file.gpx", 1)
+	assert.ErrorIs(t, err, ErrTooManyUploads)
+
+	// Finishing an upload makes room for another
+	upload, err := r.CreateUpload(ctx, "user_3", "file.gpx", 1)
+	require.NoError(t, err)
+	for i := 1; i < maxOpenUploads; i++ {
+		_, err := r.CreateUpload(ctx, "user_3", "file.gpx", 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.DeleteUpload(ctx, upload.ID))
+	_, err = r.CreateUpload(ctx, "user_3", "file.gpx", 1)
+	assert.NoError(t, err)
+}
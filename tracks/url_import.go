@@ -0,0 +1,357 @@
+package tracks
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/publicnet"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"time"
+)
+
+const (
+	urlImportMaxAttempts  = 3
+	urlImportTimeout      = 30 * time.Second
+	urlImportMaxRedirects = 5
+	maxImportURLLength    = 2048
+)
+
+var ErrInvalidImportURL = fmt.Errorf("invalid import url")
+
+type URLImportArgs struct {
+	// ImportID is unset for jobs queued before imports from URLs were
+	// created up front
+	ImportID       int64  `json:"importID,omitempty"`
+	OwnerID        string `json:"ownerID"`
+	URL            string `json:"url"`
+	SkipDuplicates bool   `json:"skipDuplicates"`
+}
+
+func (URLImportArgs) Kind() string { return "tracks_url_import" }
+
+func (URLImportArgs) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{MaxAttempts: urlImportMaxAttempts}
+}
+
+// ImportURL creates an import of the file at the URL, which is downloaded and
+// processed in the background. As the file at a URL can change, duplicates
+// are only detected once it is downloaded, when the import fails with
+// DuplicateOfID set if the file was already imported.
+func (r *Repo) ImportURL(ctx context.Context, ownerID string, rawURL string, opts ImportOptions) (Import, error) {
+	if err := validateImportURL(rawURL); err != nil {
+		return Import{}, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Import{}, fmt.Errorf("%w: invalid url", ErrInvalidImportURL)
+	}
+	// The hash only identifies the import, so it is made unique for every
+	// request. A filename can't contain a slash, so this can't be the hash
+	// of a file.
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return Import{}, err
+	}
+	hash := hashImport(ownerID, rawURL, nonce)
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Import{}, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	importId, err := q.InsertTrackImport(ctx, db.InsertTrackImportParams{
+		OwnerID:        ownerID,
+		Filename:       urlFilename(u),
+		Hash:           hash,
+		SkipDuplicates: opts.SkipDuplicates,
+		SourceUrl:      &rawURL,
+	})
+	if err != nil {
+		return Import{}, err
+	}
+
+	if err := r.enqueueURLImport(ctx, tx, importId, ownerID, rawURL); err != nil {
+		return Import{}, err
+	}
+
+	out, err := r.importStatusWith(ctx, q, importId)
+	if err != nil {
+		return Import{}, err
+	}
+	err = notifyImportEvent(ctx, q, ownerID, ImportEvent{ImportID: out.ID, URL: rawURL, Status: ImportQueued})
+	if err != nil {
+		return Import{}, err
+	}
+
+	return out, tx.Commit(ctx)
+}
+
+// enqueueURLImport schedules the file of the import to be downloaded,
+// recording the job so that it can be cancelled.
+func (r *Repo) enqueueURLImport(ctx context.Context, tx pgx.Tx, importId int64, ownerID string, rawURL string) error {
+	res, err := r.river.InsertTx(ctx, tx, URLImportArgs{
+		ImportID: importId,
+		OwnerID:  ownerID,
+		URL:      rawURL,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return r.q.WithTx(tx).SetTrackImportJob(ctx, db.SetTrackImportJobParams{
+		ID:    importId,
+		JobID: &res.Job.ID,
+	})
+}
+
+// attachDownload stores the downloaded file of an import from a URL and
+// schedules the import to be processed. If the file was already imported,
+// whether uploaded or from a URL, it returns a DuplicateImportError and
+// records the existing import.
+func (r *Repo) attachDownload(ctx context.Context, data db.TrackImport, filename string, file []byte) error {
+	// Hashed as Import hashes an upload of the file
+	contentHash := hashImport(data.OwnerID, filename, file)
+
+	// Written first so the import never refers to a missing blob
+	blobKey := importBlobKey(data.Hash)
+	if err := r.blobs.Put(ctx, blobKey, file); err != nil {
+		return err
+	}
+
+	err := r.setDownloaded(ctx, data, filename, contentHash, blobKey, file)
+	if !isDuplicateImport(err) {
+		return err
+	}
+
+	r.deleteBlobs(ctx, &blobKey)
+	existingID, err := r.q.GetTrackImportIDByContentHash(ctx, contentHash)
+	if err != nil {
+		return err
+	}
+	err = r.q.SetTrackImportDuplicateOf(ctx, db.SetTrackImportDuplicateOfParams{
+		ID:            data.ID,
+		DuplicateOfID: &existingID,
+	})
+	if err != nil {
+		return err
+	}
+	existing, err := r.importStatus(ctx, existingID)
+	if err != nil {
+		return err
+	}
+	return DuplicateImportError{Existing: existing}
+}
+
+func (r *Repo) setDownloaded(ctx context.Context, data db.TrackImport, filename string, contentHash []byte, blobKey string, file []byte) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	// A cancelled import of the same file can be started again
+	if err := q.DeleteCancelledTrackImport(ctx, contentHash); err != nil {
+		return err
+	}
+	n, err := q.SetTrackImportDownloaded(ctx, db.SetTrackImportDownloadedParams{
+		ID:          data.ID,
+		Filename:    filename,
+		BlobKey:     &blobKey,
+		ByteSize:    int64(len(file)),
+		ContentHash: contentHash,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// Cancelled while downloading
+		r.deleteBlobs(ctx, &blobKey)
+		return nil
+	}
+
+	if err := r.enqueueImport(ctx, tx, data.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+type URLImportWorker struct {
+	db     *pgxpool.Pool
+	blobs  BlobStore
+	client *http.Client
+	river.WorkerDefaults[URLImportArgs]
+}
+
+func AddURLImportWorker(workers *river.Workers, db *pgxpool.Pool, blobs BlobStore) {
+	river.AddWorker[URLImportArgs](workers, &URLImportWorker{db: db, blobs: blobs, client: newImportHTTPClient()})
+}
+
+func (w *URLImportWorker) Timeout(*river.Job[URLImportArgs]) time.Duration {
+	return urlImportTimeout + 10*time.Second
+}
+
+func (w *URLImportWorker) Work(ctx context.Context, job *river.Job[URLImportArgs]) error {
+	l := slog.With("job", job.ID, "import", job.Args.ImportID, "url", job.Args.URL)
+	repo := NewRepo(w.db, river.ClientFromContext[pgx.Tx](ctx), w.blobs)
+
+	if job.Args.ImportID == 0 {
+		_, err := repo.ImportURL(ctx, job.Args.OwnerID, job.Args.URL, ImportOptions{
+			SkipDuplicates: job.Args.SkipDuplicates,
+		})
+		return err
+	}
+
+	data, err := db.New(w.db).GetTrackImport(ctx, job.Args.ImportID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Info("import deleted")
+			return nil
+		}
+		return err
+	}
+	if data.BlobKey != nil || data.FailedAt.Valid || data.CancelledAt.Valid {
+		l.Info("already done")
+		return nil
+	}
+
+	filename, file, err := downloadImport(ctx, w.client, job.Args.URL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		message, permanent := classifyImportError(err)
+		if !permanent && job.Attempt < job.MaxAttempts {
+			l.Warn("download import attempt failed", "attempt", job.Attempt, "error", err)
+			return err
+		}
+		l.Info("download import failed", "error", err)
+		if err := markImportFailed(ctx, w.db, data, message); err != nil {
+			return err
+		}
+		return river.JobCancel(err)
+	}
+
+	err = repo.attachDownload(ctx, data, filename, file)
+	var duplicateErr DuplicateImportError
+	if errors.As(err, &duplicateErr) {
+		l.Info("file already imported", "existing", duplicateErr.Existing.ID)
+		return markImportFailed(ctx, w.db, data, "File already imported")
+	} else if err != nil {
+		return err
+	}
+	l.Info("downloaded import", "size", len(file))
+	return nil
+}
+
+// downloadImport fetches the file at the URL, returning the name to import it
+// under.
+func downloadImport(ctx context.Context, client *http.Client, rawURL string) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return "", nil, permanentImportError{err: err}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, publicnet.ErrBlockedAddress) {
+			return "", nil, permanentImportError{err: err, message: "URL is not publicly accessible"}
+		}
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500:
+		return "", nil, fmt.Errorf("download status %d", resp.StatusCode)
+	default:
+		return "", nil, permanentImportError{
+			err:     fmt.Errorf("download status %d", resp.StatusCode),
+			message: fmt.Sprintf("Download failed with status %d", resp.StatusCode),
+		}
+	}
+
+	tooLargeErr := permanentImportError{err: ErrImportTooLarge, message: "File too large"}
+	if resp.ContentLength > maxImportSize {
+		return "", nil, tooLargeErr
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) > maxImportSize {
+		return "", nil, tooLargeErr
+	}
+
+	return downloadFilename(resp), data, nil
+}
+
+// urlFilename names an import from the URL until the file is downloaded.
+func urlFilename(u *url.URL) string {
+	if name := path.Base(u.Path); name != "." && name != "/" {
+		return name
+	}
+	return u.Hostname()
+}
+
+// downloadFilename prefers the name the server suggests, as share links often
+// don't end in the name of the file.
+func downloadFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(params["filename"]); name != "." && name != "/" {
+			return name
+		}
+	}
+	return path.Base(resp.Request.URL.Path)
+}
+
+func validateImportURL(rawURL string) error {
+	if len(rawURL) > maxImportURLLength {
+		return fmt.Errorf("%w: url too long", ErrInvalidImportURL)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid url", ErrInvalidImportURL)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("%w: url must be http or https", ErrInvalidImportURL)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: url must have a host", ErrInvalidImportURL)
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && publicnet.IsBlockedAddr(addr) {
+		return fmt.Errorf("%w: url is not publicly accessible", ErrInvalidImportURL)
+	}
+	return nil
+}
+
+// newImportHTTPClient creates a client that only connects to the public
+// internet.
+func newImportHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout:   urlImportTimeout,
+		Transport: publicnet.NewTransport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= urlImportMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "https" && req.URL.Scheme != "http" {
+				return errors.New("redirect to unsupported scheme")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package tracks
+
+import (
+	"context"
+	"github.com/riverqueue/river/rivertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateImportURL(t *testing.T) {
+	assert.NoError(t, validateImportURL("https://example.com/route.gpx"))
+
+	invalid := []string{"ftp://example.com/route.gpx", "https:///route.gpx", "file:///etc/passwd",
+		"http://127.0.0.1/route.gpx", "http://[::1]:8080/route.gpx", "https://example.com/" + strings.Repeat("a", 3000)}
+	for _, u := range invalid {
+		assert.ErrorIs(t, validateImportURL(u), ErrInvalidImportURL, u)
+	}
+}
+
+func TestDownloadImportBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(sampleGPX())
+	}))
+	defer srv.Close()
+
+	_, _, err := downloadImport(context.Background(), newImportHTTPClient(), srv.URL+"/route.gpx")
+	require.Error(t, err)
+	message, permanent := classifyImportError(err)
+	assert.True(t, permanent)
+	assert.Equal(t, "URL is not publicly accessible", message)
+}
+
+func TestDownloadImport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/route.gpx":
+			_, _ = w.Write(sampleGPX())
+		case "/share/abc":
+			w.Header().Set("Content-Disposition", `attachment; filename="Ben Nevis.gpx"`)
+			_, _ = w.Write(sampleGPX())
+		case "/large.gpx":
+			_, _ = w.Write(make([]byte, maxImportSize+1))
+		case "/unavailable.gpx":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	client := srv.Client()
+
+	filename, data, err := downloadImport(ctx, client, srv.URL+"/route.gpx")
+	require.NoError(t, err)
+	assert.Equal(t, "route.gpx", filename)
+	assert.Equal(t, sampleGPX(), data)
+
+	filename, _, err = downloadImport(ctx, client, srv.URL+"/share/abc")
+	require.NoError(t, err)
+	assert.Equal(t, "Ben Nevis.gpx", filename)
+
+	_, _, err = downloadImport(ctx, client, srv.URL+"/large.gpx")
+	message, permanent := classifyImportError(err)
+	assert.True(t, permanent)
+	assert.Equal(t, "File too large", message)
+
+	_, _, err = downloadImport(ctx, client, srv.URL+"/missing.gpx")
+	message, permanent = classifyImportError(err)
+	assert.True(t, permanent)
+	assert.Equal(t, "Download failed with status 404", message)
+
+	_, _, err = downloadImport(ctx, client, srv.URL+"/unavailable.gpx")
+	require.Error(t, err)
+	_, permanent = classifyImportError(err)
+	assert.False(t, permanent)
+}
+
+func TestImportURL(t *testing.T) {
+	ctx := context.Background()
+	driver, r := newSubjectWithDriver(t)
+
+	created, err := r.ImportURL(ctx, "user_1", "https://example.com/routes/route.gpx", ImportOptions{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, "route.gpx", created.Filename)
+
+	importId, err := r.unmarshalImportID(ctx, created.ID)
+	require.NoError(t, err)
+	rivertest.RequireInserted(ctx, t, driver, &URLImportArgs{
+		ImportID: importId,
+		OwnerID:  "user_1",
+		URL:      "https://example.com/routes/route.gpx",
+	}, nil)
+
+	status, err := r.ImportStatus(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Nil(t, status.CompletedAt)
+
+	// Not a duplicate until the file is downloaded, as it may have changed
+	again, err := r.ImportURL(ctx, "user_1", "https://example.com/routes/route.gpx", ImportOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, created.ID, again.ID)
+
+	data, err := r.q.GetTrackImport(ctx, importId)
+	require.NoError(t, err)
+	require.NoError(t, r.attachDownload(ctx, data, "Ben Nevis.gpx", sampleGPX()))
+	rivertest.RequireInserted(ctx, t, driver, &ImportWorkerArgs{Id: importId}, nil)
+
+	status, err = r.ImportStatus(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ben Nevis.gpx", status.Filename)
+	assert.Equal(t, len(sampleGPX()), status.ByteSize)
+
+	againId, err := r.unmarshalImportID(ctx, again.ID)
+	require.NoError(t, err)
+	data, err = r.q.GetTrackImport(ctx, againId)
+	require.NoError(t, err)
+	err = r.attachDownload(ctx, data, "Ben Nevis.gpx", sampleGPX())
+	var duplicateErr DuplicateImportError
+	require.ErrorAs(t, err, &duplicateErr)
+	assert.Equal(t, created.ID, duplicateErr.Existing.ID)
+	status, err = r.ImportStatus(ctx, again.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, status.DuplicateOfID)
+
+	// Uploading the downloaded file is a duplicate too
+	_, err = r.Import(ctx, "user_1", "Ben Nevis.gpx", sampleGPX(), ImportOptions{})
+	require.ErrorAs(t, err, &duplicateErr)
+	assert.Equal(t, created.ID, duplicateErr.Existing.ID)
+}
@@ -0,0 +1,56 @@
+package tracks
+
+import (
+	"context"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/dzfranklin/plantopo-api/webhooks"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/riverqueue/river"
+	"time"
+)
+
+// trackWebhookData is sent with track events. Receivers can fetch the rest
+// of the track from the API.
+type trackWebhookData struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name,omitempty"`
+	Time         *time.Time `json:"time,omitempty"`
+	ActivityType string     `json:"activityType,omitempty"`
+}
+
+type importWebhookData struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Error    string `json:"error,omitempty"`
+}
+
+func enqueueTrackCreated(
+	ctx context.Context,
+	client *river.Client[pgx.Tx],
+	tx pgx.Tx,
+	ownerID *string,
+	trackID int64,
+	name *string,
+	trackTime pgtype.Timestamptz,
+	activityType *string,
+) error {
+	if ownerID == nil {
+		return nil
+	}
+	return webhooks.Enqueue(ctx, client, tx, *ownerID, webhooks.EventTrackCreated, trackWebhookData{
+		ID:           ids.Marshal(trackIdPrefix, trackID),
+		Name:         stringFromNullable(name),
+		Time:         pgTimestamptzToNullable(trackTime),
+		ActivityType: stringFromNullable(activityType),
+	})
+}
+
+func enqueueTrackDeleted(ctx context.Context, client *river.Client[pgx.Tx], tx pgx.Tx, ownerID *string, trackID int64) error {
+	if ownerID == nil {
+		return nil
+	}
+	return webhooks.Enqueue(ctx, client, tx, *ownerID, webhooks.EventTrackDeleted, trackWebhookData{
+		ID: ids.Marshal(trackIdPrefix, trackID),
+	})
+}
@@ -0,0 +1,217 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/ids"
+	"github.com/dzfranklin/plantopo-api/publicnet"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"net/url"
+	"slices"
+	"time"
+)
+
+const (
+	EventTrackCreated = "track.created"
+	EventTrackDeleted = "track.deleted"
+	EventImportFailed = "import.failed"
+)
+
+var Events = []string{EventTrackCreated, EventTrackDeleted, EventImportFailed}
+
+const (
+	webhookIdPrefix  = "wh"
+	deliveryIdPrefix = "whd"
+	secretPrefix     = "whsec_"
+)
+
+const maxWebhooksPerUser = 20
+
+var ErrWebhookNotFound = fmt.Errorf("webhook not found")
+var ErrInvalidWebhook = fmt.Errorf("invalid webhook")
+
+type Repo struct {
+	db *pgxpool.Pool
+	q  *db.Queries
+}
+
+func NewRepo(pool *pgxpool.Pool) *Repo {
+	return &Repo{db: pool, q: db.New(pool)}
+}
+
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret signs deliveries. It is only returned when the webhook is
+	// created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Delivery is an attempt to deliver an event to a webhook.
+type Delivery struct {
+	ID          string    `json:"id"`
+	EventID     string    `json:"eventID"`
+	Event       string    `json:"event"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int       `json:"durationMs"`
+	Succeeded   bool      `json:"succeeded"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+// Create registers a webhook to receive the events, generating the secret
+// its deliveries are signed with.
+func (r *Repo) Create(ctx context.Context, ownerID string, rawURL string, events []string) (Webhook, error) {
+	if err := validateURL(ctx, rawURL); err != nil {
+		return Webhook{}, err
+	}
+	if len(events) == 0 {
+		return Webhook{}, fmt.Errorf("%w: no events", ErrInvalidWebhook)
+	}
+	for _, event := range events {
+		if !slices.Contains(Events, event) {
+			return Webhook{}, fmt.Errorf("%w: unknown event %s", ErrInvalidWebhook, event)
+		}
+	}
+
+	existing, err := r.q.ListWebhooks(ctx, ownerID)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if len(existing) >= maxWebhooksPerUser {
+		return Webhook{}, fmt.Errorf("%w: too many webhooks", ErrInvalidWebhook)
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return Webhook{}, err
+	}
+	row, err := r.q.InsertWebhook(ctx, db.InsertWebhookParams{
+		OwnerID: ownerID,
+		Url:     rawURL,
+		Secret:  secret,
+		Events:  events,
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	out := toWebhook(row)
+	out.Secret = row.Secret
+	return out, nil
+}
+
+func (r *Repo) ListMine(ctx context.Context, ownerID string) ([]Webhook, error) {
+	rows, err := r.q.ListWebhooks(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Webhook, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, toWebhook(row))
+	}
+	return out, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, ownerID string, id string) error {
+	whID, err := ids.Unmarshal(webhookIdPrefix, id)
+	if err != nil {
+		return ErrWebhookNotFound
+	}
+	n, err := r.q.DeleteWebhook(ctx, db.DeleteWebhookParams{ID: whID, OwnerID: ownerID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries lists the most recent delivery attempts to the webhook.
+func (r *Repo) ListDeliveries(ctx context.Context, ownerID string, id string) ([]Delivery, error) {
+	whID, err := ids.Unmarshal(webhookIdPrefix, id)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	webhook, err := r.q.GetWebhook(ctx, whID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	if webhook.OwnerID != ownerID {
+		return nil, ErrWebhookNotFound
+	}
+
+	rows, err := r.q.ListWebhookDeliveries(ctx, whID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Delivery, 0, len(rows))
+	for _, row := range rows {
+		d := Delivery{
+			ID:          ids.Marshal(deliveryIdPrefix, row.ID),
+			EventID:     row.EventID,
+			Event:       row.Event,
+			Attempt:     int(row.Attempt),
+			DurationMs:  int(row.DurationMs),
+			Succeeded:   row.Succeeded,
+			AttemptedAt: row.AttemptedAt.Time,
+		}
+		if row.StatusCode != nil {
+			d.StatusCode = int(*row.StatusCode)
+		}
+		if row.Error != nil {
+			d.Error = *row.Error
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// validateURL checks the url is one deliveries could be made to. Deliveries
+// also check the address they connect to, as what a host resolves to can
+// change.
+func validateURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid url", ErrInvalidWebhook)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("%w: url must be http or https", ErrInvalidWebhook)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: url must have a host", ErrInvalidWebhook)
+	}
+	if err := publicnet.CheckHost(ctx, u.Hostname()); err != nil {
+		return fmt.Errorf("%w: url is not publicly accessible", ErrInvalidWebhook)
+	}
+	return nil
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+func toWebhook(row db.Webhook) Webhook {
+	return Webhook{
+		ID:        ids.Marshal(webhookIdPrefix, row.ID),
+		URL:       row.Url,
+		Events:    row.Events,
+		CreatedAt: row.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"context"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/testsupport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestCreateListDelete(t *testing.T) {
+	ctx := context.Background()
+	r := NewRepo(testsupport.NewDB(t))
+
+	created, err := r.Create(ctx, "user_1", "https://example.com/hook", []string{EventTrackCreated})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Secret, secretPrefix))
+
+	mine, err := r.ListMine(ctx, "user_1")
+	require.NoError(t, err)
+	require.Len(t, mine, 1)
+	assert.Equal(t, created.ID, mine[0].ID)
+	assert.Empty(t, mine[0].Secret)
+
+	hooks, err := r.q.ListWebhooksForEvent(ctx, db.ListWebhooksForEventParams{OwnerID: "user_1", Event: EventTrackCreated})
+	require.NoError(t, err)
+	assert.Len(t, hooks, 1)
+	hooks, err = r.q.ListWebhooksForEvent(ctx, db.ListWebhooksForEventParams{OwnerID: "user_1", Event: EventTrackDeleted})
+	require.NoError(t, err)
+	assert.Empty(t, hooks)
+
+	_, err = r.ListDeliveries(ctx, "user_2", created.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	deliveries, err := r.ListDeliveries(ctx, "user_1", created.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	assert.ErrorIs(t, r.Delete(ctx, "user_2", created.ID), ErrWebhookNotFound)
+	require.NoError(t, r.Delete(ctx, "user_1", created.ID))
+}
+
+func TestCreateInvalid(t *testing.T) {
+	ctx := context.Background()
+	r := NewRepo(testsupport.NewDB(t))
+
+	_, err := r.Create(ctx, "user_1", "ftp://example.com", []string{EventTrackCreated})
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = r.Create(ctx, "user_1", "https://example.com", []string{"track.exploded"})
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = r.Create(ctx, "user_1", "https://example.com", nil)
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+}
+
+func TestValidateURL(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, validateURL(ctx, "https://example.com/hook"))
+
+	private := []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.5/hook",
+		"https://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+	}
+	for _, u := range private {
+		assert.ErrorIs(t, validateURL(ctx, u), ErrInvalidWebhook, u)
+	}
+}
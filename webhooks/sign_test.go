@@ -0,0 +1,21 @@
+package webhooks
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1718182800, 0)
+	body := []byte(`{"type":"track.created"}`)
+
+	header := Sign("whsec_test", now, body)
+	assert.Regexp(t, `^t=1718182800,v1=[0-9a-f]{64}$`, header)
+
+	assert.True(t, Verify("whsec_test", header, body, now.Add(time.Minute), 5*time.Minute))
+	assert.False(t, Verify("whsec_other", header, body, now, 5*time.Minute))
+	assert.False(t, Verify("whsec_test", header, []byte(`{"type":"track.deleted"}`), now, 5*time.Minute))
+	assert.False(t, Verify("whsec_test", header, body, now.Add(time.Hour), 5*time.Minute))
+	assert.False(t, Verify("whsec_test", "garbage", body, now, 5*time.Minute))
+}
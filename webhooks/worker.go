@@ -0,0 +1,208 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/publicnet"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	maxDeliveryAttempts = 12
+	deliveryTimeout     = 10 * time.Second
+	// Retries back off exponentially from minRetryDelay, which with the
+	// maximum attempts spreads them over most of a day
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = 6 * time.Hour
+	// Errors longer than this are truncated in the delivery log
+	maxLoggedErrorLength = 1000
+)
+
+// Payload is the body of every delivery.
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+type DeliverArgs struct {
+	WebhookID int64           `json:"webhookID"`
+	EventID   string          `json:"eventID"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+func (DeliverArgs) Kind() string { return "webhooks_deliver" }
+
+func (DeliverArgs) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{MaxAttempts: maxDeliveryAttempts}
+}
+
+// Enqueue schedules delivery of the event to each of the user's webhooks
+// subscribed to it. As it is part of the transaction, the event is only sent
+// if the change it describes commits.
+func Enqueue(ctx context.Context, client *river.Client[pgx.Tx], tx pgx.Tx, ownerID string, event string, data interface{}) error {
+	hooks, err := db.New(tx).ListWebhooksForEvent(ctx, db.ListWebhooksForEventParams{
+		OwnerID: ownerID,
+		Event:   event,
+	})
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	eventID, err := generateEventID()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(Payload{
+		ID:        eventID,
+		Type:      event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	params := make([]river.InsertManyParams, 0, len(hooks))
+	for _, hook := range hooks {
+		params = append(params, river.InsertManyParams{Args: DeliverArgs{
+			WebhookID: hook.ID,
+			EventID:   eventID,
+			Event:     event,
+			Payload:   payload,
+		}})
+	}
+	_, err = client.InsertManyTx(ctx, tx, params)
+	return err
+}
+
+type DeliveryWorker struct {
+	db     *pgxpool.Pool
+	client *http.Client
+	river.WorkerDefaults[DeliverArgs]
+}
+
+func AddDeliveryWorker(workers *river.Workers, db *pgxpool.Pool) {
+	river.AddWorker[DeliverArgs](workers, &DeliveryWorker{db: db, client: newDeliveryClient()})
+}
+
+// newDeliveryClient creates a client that only connects to the public
+// internet.
+func newDeliveryClient() *http.Client {
+	return &http.Client{
+		Timeout:   deliveryTimeout,
+		Transport: publicnet.NewTransport(),
+		// A redirect is treated as a failed delivery rather than followed
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (w *DeliveryWorker) Work(ctx context.Context, job *river.Job[DeliverArgs]) error {
+	l := slog.With("job", job.ID, "webhook", job.Args.WebhookID, "event", job.Args.EventID)
+	q := db.New(w.db)
+
+	hook, err := q.GetWebhook(ctx, job.Args.WebhookID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Info("webhook deleted before delivery")
+			return nil
+		}
+		return err
+	}
+
+	start := time.Now()
+	statusCode, deliveryErr := w.post(ctx, hook, job.Args)
+	duration := time.Since(start)
+
+	params := db.InsertWebhookDeliveryParams{
+		WebhookID:  hook.ID,
+		EventID:    job.Args.EventID,
+		Event:      job.Args.Event,
+		Attempt:    int32(job.Attempt),
+		DurationMs: int32(duration.Milliseconds()),
+		Succeeded:  deliveryErr == nil,
+	}
+	if statusCode != 0 {
+		code := int32(statusCode)
+		params.StatusCode = &code
+	}
+	if deliveryErr != nil {
+		msg := deliveryErr.Error()
+		if len(msg) > maxLoggedErrorLength {
+			msg = msg[:maxLoggedErrorLength]
+		}
+		params.Error = &msg
+	}
+	if err := q.InsertWebhookDelivery(ctx, params); err != nil {
+		l.Error("log webhook delivery", "error", err)
+	}
+
+	if deliveryErr != nil {
+		l.Info("webhook delivery failed", "attempt", job.Attempt, "error", deliveryErr)
+		return deliveryErr
+	}
+	return nil
+}
+
+func (w *DeliveryWorker) post(ctx context.Context, hook db.Webhook, args DeliverArgs) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(args.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "plantopo-webhooks")
+	req.Header.Set("Plantopo-Event", args.Event)
+	req.Header.Set("Plantopo-Event-ID", args.EventID)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, time.Now(), args.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (w *DeliveryWorker) NextRetry(job *river.Job[DeliverArgs]) time.Time {
+	delay := minRetryDelay
+	for i := 1; i < job.Attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return time.Now().Add(min(delay, maxRetryDelay))
+}
+
+func (w *DeliveryWorker) Timeout(*river.Job[DeliverArgs]) time.Duration {
+	return deliveryTimeout + 5*time.Second
+}
+
+func generateEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "evt_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,76 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dzfranklin/plantopo-api/db"
+	"github.com/dzfranklin/plantopo-api/publicnet"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPost(t *testing.T) {
+	var gotHeader http.Header
+	var gotBody []byte
+	status := 200
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	w := &DeliveryWorker{client: srv.Client()}
+	hook := db.Webhook{ID: 1, Url: srv.URL, Secret: "whsec_test"}
+	args := DeliverArgs{
+		WebhookID: 1,
+		EventID:   "evt_1",
+		Event:     EventTrackCreated,
+		Payload:   json.RawMessage(`{"id":"evt_1","type":"track.created"}`),
+	}
+
+	code, err := w.post(context.Background(), hook, args)
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, string(args.Payload), string(gotBody))
+	assert.Equal(t, EventTrackCreated, gotHeader.Get("Plantopo-Event"))
+	assert.True(t, Verify("whsec_test", gotHeader.Get(SignatureHeader), gotBody, time.Now(), time.Minute))
+
+	status = 500
+	code, err = w.post(context.Background(), hook, args)
+	assert.Error(t, err)
+	assert.Equal(t, 500, code)
+}
+
+func TestPostBlocksPrivateAddresses(t *testing.T) {
+	delivered := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered = true
+	}))
+	defer srv.Close()
+
+	w := &DeliveryWorker{client: newDeliveryClient()}
+	hook := db.Webhook{ID: 1, Url: srv.URL, Secret: "whsec_test"}
+	_, err := w.post(context.Background(), hook, DeliverArgs{WebhookID: 1, EventID: "evt_1", Event: EventTrackCreated})
+	assert.ErrorIs(t, err, publicnet.ErrBlockedAddress)
+	assert.False(t, delivered)
+}
+
+func TestNextRetry(t *testing.T) {
+	w := &DeliveryWorker{}
+	retryIn := func(attempt int) time.Duration {
+		job := &river.Job[DeliverArgs]{JobRow: &rivertype.JobRow{Attempt: attempt}}
+		return time.Until(w.NextRetry(job)).Round(time.Second)
+	}
+	assert.Equal(t, 30*time.Second, retryIn(1))
+	assert.Equal(t, time.Minute, retryIn(2))
+	assert.Equal(t, 4*time.Minute, retryIn(4))
+	assert.Equal(t, maxRetryDelay, retryIn(maxDeliveryAttempts))
+}